@@ -2,11 +2,14 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"go-rest-api/config"
 	"go-rest-api/config/container"
 	"go-rest-api/internal/infra/database"
 	"go-rest-api/internal/infra/http"
 	"go-rest-api/internal/infra/logger"
+	"go-rest-api/internal/infra/tracing"
 	"os"
 	"os/signal"
 	"runtime/debug"
@@ -14,9 +17,26 @@ import (
 )
 
 func main() {
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	switch flag.Arg(0) {
+	case "", "migrate":
+	case "config":
+		os.Exit(configCommand(cfg, flag.Args()[1:]))
+	default:
+		fmt.Fprintln(os.Stderr, "usage: server [-config file] [migrate | config print [--redacted]]")
+		os.Exit(2)
+	}
+
 	exitCode := 0
 	ctx, cancel := context.WithCancel(context.Background())
-	cfg := config.GetConfiguration()
 	logger.Init(cfg)
 
 	// Recover
@@ -39,16 +59,54 @@ func main() {
 		logger.Logger.Info("Sent cancel to all threads...")
 	}()
 
-	err := database.Migrate(cfg)
+	shutdownTracing, err := tracing.Init(ctx, cfg)
 	if err != nil {
-		logger.Logger.Error("Unable to apply migrations: %q\n", err)
+		logger.Logger.Errorf("Unable to initialize tracing: %s", err)
+		shutdownTracing = func(context.Context) error { return nil }
 	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Logger.Errorf("Unable to flush traces: %s", err)
+		}
+	}()
 
-	cont := container.New()
+	err = database.Migrate(ctx, cfg)
+	if err != nil {
+		logger.Logger.Errorf("Unable to apply migrations: %s", err)
+		exitCode = 1
+		return
+	}
+	if flag.Arg(0) == "migrate" {
+		return
+	}
+
+	cont := container.New(cfg)
+
+	// A failing admin server stops the API as well, so that it does not
+	// run unmonitored.
+	adminErr := make(chan error, 1)
+	if cfg.MetricsAddress != "" {
+		adminListener, err := http.ListenAdmin(cfg)
+		if err != nil {
+			logger.Logger.Errorf("admin server error: %s", err)
+			exitCode = 2
+			return
+		}
+		go func() {
+			if err := http.AdminServer(ctx, cfg, adminListener, http.CreateAdminRouter()); err != nil {
+				adminErr <- err
+				cancel()
+			}
+		}()
+	} else {
+		logger.Logger.Info("METRICS_ADDRESS is empty, metrics are not served")
+	}
 
 	err = http.Server(
 		ctx,
+		cfg,
 		http.CreateRouter(cont),
+		cont.Health,
 	)
 
 	if err != nil {
@@ -56,4 +114,28 @@ func main() {
 		exitCode = 2
 		return
 	}
+	select {
+	case err := <-adminErr:
+		logger.Logger.Errorf("admin server error: %s", err)
+		exitCode = 2
+	default:
+	}
+}
+
+// configCommand handles "config print [--redacted]".
+func configCommand(cfg config.Configuration, args []string) int {
+	fs := flag.NewFlagSet("config print", flag.ContinueOnError)
+	redacted := fs.Bool("redacted", false, "mask secret values")
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "usage: server [-config file] config print [--redacted]")
+		return 2
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if err := config.Print(os.Stdout, cfg, *redacted); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
 }
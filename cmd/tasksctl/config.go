@@ -0,0 +1,18 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"go-rest-api/config"
+)
+
+// configCheck only reports success: main has already loaded and validated
+// the configuration, failing with the validation errors if it was invalid.
+func configCheck(_ context.Context, _ config.Configuration, args []string) error {
+	if err := parseFlags(flag.NewFlagSet("config check", flag.ContinueOnError), args); err != nil {
+		return err
+	}
+	fmt.Println("configuration is valid")
+	return nil
+}
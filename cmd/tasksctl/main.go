@@ -0,0 +1,149 @@
+// Command tasksctl runs maintenance tasks against the database and the
+// configuration used by the server.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"go-rest-api/config"
+	"go-rest-api/config/container"
+	"go-rest-api/internal/domain"
+	"go-rest-api/internal/infra/logger"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+)
+
+const usage = `usage: tasksctl [-config file] <command> [arguments]
+
+commands:
+  migrate up                       apply all pending migrations
+  migrate down [-steps n]          roll back n migrations (1 by default)
+  migrate to <version>             migrate up or down to version
+  migrate force <version>          mark the schema as version and clear the dirty flag
+  migrate status                   print the current and expected schema version
+  user create -name n -email e -password p
+  user disable -user <id|email>    disable the user and revoke their sessions
+  user reset-password -user <id|email> [-password-file f]
+                                   read a new password from f or stdin and revoke the user's sessions
+  session revoke -user <id|email>  revoke every session of the user
+  storage reconcile [-min-age d] [-dry-run]
+                                   delete stored avatar and attachment files nothing refers to
+  storage scan                     scan the attachments still waiting for the malware scanner
+  seed [-email e] [-password p] [-projects n]
+  config check                     load and validate the configuration
+`
+
+// errUsage makes a command exit with status 2 after printing the usage.
+var errUsage = errors.New("invalid arguments")
+
+type command func(ctx context.Context, cfg config.Configuration, args []string) error
+
+var commands = map[string]map[string]command{
+	"migrate": {
+		"up":     migrateUp,
+		"down":   migrateDown,
+		"to":     migrateTo,
+		"force":  migrateForce,
+		"status": migrateStatus,
+	},
+	"user": {
+		"create":         userCreate,
+		"disable":        userDisable,
+		"reset-password": userResetPassword,
+	},
+	"session": {
+		"revoke": sessionRevoke,
+	},
+	"storage": {
+		"reconcile": storageReconcile,
+		"scan":      storageScan,
+	},
+	"seed": {
+		"": seed,
+	},
+	"config": {
+		"check": configCheck,
+	},
+}
+
+func main() {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file")
+	flag.Parse()
+
+	cmd, args, ok := lookup(flag.Args())
+	if !ok {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	logger.Init(cfg)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = run(ctx, cmd, cfg, args)
+	if errors.Is(err, errUsage) {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// run calls cmd, turning a panic into an error: container.New panics when
+// the database is unreachable.
+func run(ctx context.Context, cmd command, cfg config.Configuration, args []string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return cmd(ctx, cfg, args)
+}
+
+func lookup(args []string) (command, []string, bool) {
+	if len(args) == 0 {
+		return nil, nil, false
+	}
+	subcommands, ok := commands[args[0]]
+	if !ok {
+		return nil, nil, false
+	}
+	if cmd, ok := subcommands[""]; ok {
+		return cmd, args[1:], true
+	}
+	if len(args) < 2 {
+		return nil, nil, false
+	}
+	cmd, ok := subcommands[args[1]]
+	return cmd, args[2:], ok
+}
+
+// parseFlags parses args into fs, rejecting positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return errUsage
+	}
+	return nil
+}
+
+// findUser resolves a user given by numeric id or by email.
+func findUser(ctx context.Context, cont container.Container, ref string) (domain.User, error) {
+	if id, err := strconv.ParseUint(ref, 10, 64); err == nil {
+		return cont.UserService.FindById(ctx, id)
+	}
+	return cont.UserService.FindByEmail(ctx, ref)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"go-rest-api/config"
+	"go-rest-api/internal/infra/database"
+	"strconv"
+
+	"github.com/golang-migrate/migrate/v4"
+)
+
+func migrateUp(ctx context.Context, cfg config.Configuration, args []string) error {
+	if err := parseFlags(flag.NewFlagSet("migrate up", flag.ContinueOnError), args); err != nil {
+		return err
+	}
+	return runMigration(ctx, cfg, func(m *migrate.Migrate) error { return m.Up() })
+}
+
+func migrateDown(ctx context.Context, cfg config.Configuration, args []string) error {
+	fs := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+	steps := fs.Int("steps", 1, "number of migrations to roll back")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *steps < 1 {
+		return errUsage
+	}
+	return runMigration(ctx, cfg, func(m *migrate.Migrate) error { return m.Steps(-*steps) })
+}
+
+func migrateTo(ctx context.Context, cfg config.Configuration, args []string) error {
+	version, err := versionArg(args)
+	if err != nil {
+		return err
+	}
+	return runMigration(ctx, cfg, func(m *migrate.Migrate) error { return m.Migrate(version) })
+}
+
+func migrateForce(ctx context.Context, cfg config.Configuration, args []string) error {
+	version, err := versionArg(args)
+	if err != nil {
+		return err
+	}
+	return runMigration(ctx, cfg, func(m *migrate.Migrate) error { return m.Force(int(version)) })
+}
+
+func migrateStatus(ctx context.Context, cfg config.Configuration, args []string) error {
+	if err := parseFlags(flag.NewFlagSet("migrate status", flag.ContinueOnError), args); err != nil {
+		return err
+	}
+	db, err := database.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	state, err := database.MigrationStatus(ctx, db)
+	if err != nil {
+		return err
+	}
+	expected, err := database.ExpectedMigrationVersion(cfg)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("version:  %d\n", state.Version)
+	fmt.Printf("dirty:    %t\n", state.Dirty)
+	fmt.Printf("expected: %d\n", expected)
+	return nil
+}
+
+func versionArg(args []string) (uint, error) {
+	if len(args) != 1 {
+		return 0, errUsage
+	}
+	version, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid version %q", args[0])
+	}
+	return uint(version), nil
+}
+
+// runMigration applies step under the migration lock and prints the
+// resulting schema version.
+func runMigration(ctx context.Context, cfg config.Configuration, step func(m *migrate.Migrate) error) error {
+	return database.WithMigrator(ctx, cfg, func(m *migrate.Migrate) error {
+		err := step(m)
+		if errors.Is(err, migrate.ErrNoChange) {
+			fmt.Println("no change")
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+
+		version, dirty, err := m.Version()
+		if errors.Is(err, migrate.ErrNilVersion) {
+			fmt.Println("schema is empty")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("schema is at version %d (dirty: %t)\n", version, dirty)
+		return nil
+	})
+}
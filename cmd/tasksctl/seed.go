@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"go-rest-api/config"
+	"go-rest-api/config/container"
+	"go-rest-api/internal/domain"
+)
+
+// seed creates a demo user with a handful of projects. Running it again
+// reuses the user and adds more projects.
+func seed(ctx context.Context, cfg config.Configuration, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	email := fs.String("email", "demo@example.com", "email of the demo user")
+	password := fs.String("password", "demo-password", "password of the demo user")
+	projects := fs.Int("projects", 10, "number of projects to create")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *projects < 0 {
+		return errUsage
+	}
+
+	cont := container.New(cfg)
+	user, err := cont.UserService.FindByEmail(ctx, *email)
+	if errors.Is(err, sql.ErrNoRows) {
+		user, err = cont.UserService.Save(ctx, domain.User{
+			Name:     "Demo User",
+			Email:    *email,
+			Password: *password,
+		})
+		if err == nil {
+			fmt.Printf("created user %d (%s)\n", user.Id, user.Email)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := 1; i <= *projects; i++ {
+		_, err := cont.ProjectService.Save(ctx, domain.Project{
+			Title:       fmt.Sprintf("Demo project %d", i),
+			Description: fmt.Sprintf("Sample project number %d, created by tasksctl seed.", i),
+			CreatorId:   user.Id,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Printf("created %d projects for user %d\n", *projects, user.Id)
+	return nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"go-rest-api/config"
+	"go-rest-api/config/container"
+)
+
+func sessionRevoke(ctx context.Context, cfg config.Configuration, args []string) error {
+	fs := flag.NewFlagSet("session revoke", flag.ContinueOnError)
+	ref := fs.String("user", "", "user id or email")
+	if err := parseFlags(fs, args); err != nil || *ref == "" {
+		return errUsage
+	}
+
+	cont := container.New(cfg)
+	user, err := findUser(ctx, cont, *ref)
+	if err != nil {
+		return err
+	}
+	revoked, err := cont.SessionService.RevokeAll(ctx, user.Id)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("revoked %d sessions of user %d (%s)\n", revoked, user.Id, user.Email)
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"go-rest-api/config"
+	"go-rest-api/config/container"
+	"go-rest-api/internal/domain"
+	"time"
+)
+
+func storageReconcile(ctx context.Context, cfg config.Configuration, args []string) error {
+	fs := flag.NewFlagSet("storage reconcile", flag.ContinueOnError)
+	minAge := fs.Duration("min-age", time.Hour, "keep files younger than this, they may belong to an upload in progress")
+	dryRun := fs.Bool("dry-run", false, "only list the orphaned files")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cont := container.New(cfg)
+	orphans, err := cont.UserService.DeleteOrphanedAvatars(ctx, *minAge, *dryRun)
+	if err != nil {
+		return err
+	}
+	attachments, err := cont.AttachmentService.DeleteOrphanedAttachments(ctx, *minAge, *dryRun)
+	if err != nil {
+		return err
+	}
+	orphans = append(orphans, attachments...)
+	for _, key := range orphans {
+		fmt.Println(key)
+	}
+	if *dryRun {
+		fmt.Printf("found %d orphaned files\n", len(orphans))
+	} else {
+		fmt.Printf("deleted %d orphaned files\n", len(orphans))
+	}
+	return nil
+}
+
+func storageScan(ctx context.Context, cfg config.Configuration, args []string) error {
+	fs := flag.NewFlagSet("storage scan", flag.ContinueOnError)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cont := container.New(cfg)
+	attachments, err := cont.AttachmentService.ScanPending(ctx)
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, attachment := range attachments {
+		fmt.Printf("%d\t%s\t%s\n", attachment.Id, attachment.ScanStatus, attachment.Key)
+		if attachment.ScanStatus == domain.ScanPending {
+			pending++
+		}
+	}
+	fmt.Printf("scanned %d attachments, %d still pending\n", len(attachments)-pending, pending)
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"go-rest-api/config"
+	"go-rest-api/config/container"
+	"go-rest-api/internal/domain"
+	"io"
+	"os"
+	"strings"
+)
+
+func userCreate(ctx context.Context, cfg config.Configuration, args []string) error {
+	fs := flag.NewFlagSet("user create", flag.ContinueOnError)
+	name := fs.String("name", "", "display name")
+	email := fs.String("email", "", "login email")
+	password := fs.String("password", "", "initial password")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *name == "" || *email == "" || *password == "" {
+		return errUsage
+	}
+
+	cont := container.New(cfg)
+	_, err := cont.UserService.FindByEmail(ctx, *email)
+	if err == nil {
+		return fmt.Errorf("user %s already exists", *email)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	user, err := cont.UserService.Save(ctx, domain.User{
+		Name:     *name,
+		Email:    *email,
+		Password: *password,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created user %d (%s)\n", user.Id, user.Email)
+	return nil
+}
+
+func userDisable(ctx context.Context, cfg config.Configuration, args []string) error {
+	fs := flag.NewFlagSet("user disable", flag.ContinueOnError)
+	ref := fs.String("user", "", "user id or email")
+	if err := parseFlags(fs, args); err != nil || *ref == "" {
+		return errUsage
+	}
+
+	cont := container.New(cfg)
+	user, err := findUser(ctx, cont, *ref)
+	if err != nil {
+		return err
+	}
+	user, err = cont.UserService.Disable(ctx, user.Id)
+	if err != nil {
+		return err
+	}
+	revoked, err := cont.SessionService.RevokeAll(ctx, user.Id)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("disabled user %d (%s), revoked %d sessions\n", user.Id, user.Email, revoked)
+	return nil
+}
+
+// userResetPassword sets a new password and revokes every session of the
+// user, as passwords are mostly reset for compromised accounts. The password
+// is read from a file or stdin, keeping it out of shell history and ps.
+func userResetPassword(ctx context.Context, cfg config.Configuration, args []string) error {
+	fs := flag.NewFlagSet("user reset-password", flag.ContinueOnError)
+	ref := fs.String("user", "", "user id or email")
+	passwordFile := fs.String("password-file", "", "file holding the new password, stdin when empty or -")
+	if err := parseFlags(fs, args); err != nil || *ref == "" {
+		return errUsage
+	}
+	password, err := readPassword(*passwordFile)
+	if err != nil {
+		return err
+	}
+
+	cont := container.New(cfg)
+	user, err := findUser(ctx, cont, *ref)
+	if err != nil {
+		return err
+	}
+	err = cont.UserService.ResetPassword(ctx, user.Id, password)
+	if err != nil {
+		return err
+	}
+	revoked, err := cont.SessionService.RevokeAll(ctx, user.Id)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("password of user %d (%s) was reset, revoked %d sessions\n", user.Id, user.Email, revoked)
+	return nil
+}
+
+// readPassword reads the first line of path, or of stdin when path is empty
+// or "-".
+func readPassword(path string) (string, error) {
+	var source io.Reader = os.Stdin
+	if path != "" && path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		source = file
+	}
+
+	line, err := bufio.NewReader(source).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("the new password is empty")
+	}
+	return password, nil
+}
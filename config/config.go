@@ -1,53 +1,141 @@
 package config
 
 import (
-	"os"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"time"
 )
 
+// Configuration is loaded by Load from defaults, an optional YAML or TOML
+// file and environment variables, in that order. Every field names its file
+// key, environment variable and default in tags. Fields tagged secret can
+// also be read from the file named by the <ENV>_FILE variable and are
+// masked by Print.
 type Configuration struct {
-	DatabaseName        string
-	DatabaseHost        string
-	DatabasePort        string
-	DatabaseUser        string
-	DatabasePassword    string
-	JwtSecret           string
-	MigrateToVersion    string
-	MigrationLocation   string
-	LoggerLevel         string
-	SmtpHost            string
-	SmtpPort            string
-	WorkGmail           string
-	WorkGmailPassword   string
-	CloudinaryNameKey   string
-	CloudinaryApiKey    string
-	CloudinarySecretKey string
+	DatabaseName     string `yaml:"database_name" toml:"database_name" env:"DB_NAME" default:"restapi_dev" validate:"required"`
+	DatabaseHost     string `yaml:"database_host" toml:"database_host" env:"DB_HOST" default:"127.0.0.1" validate:"required"`
+	DatabasePort     int    `yaml:"database_port" toml:"database_port" env:"DB_PORT" default:"5432" validate:"min=1,max=65535"`
+	DatabaseUser     string `yaml:"database_user" toml:"database_user" env:"DB_USER" default:"postgres" validate:"required"`
+	DatabasePassword string `yaml:"database_password" toml:"database_password" env:"DB_PASSWORD" secret:"true" validate:"required"`
+
+	JwtSecret string `yaml:"jwt_secret" toml:"jwt_secret" env:"JWT_SECRET" secret:"true" validate:"required,min=32"`
+
+	MigrateToVersion     string        `yaml:"migrate" toml:"migrate" env:"MIGRATE" default:"latest" validate:"required,migration_version"`
+	MigrationLockTimeout time.Duration `yaml:"migration_lock_timeout" toml:"migration_lock_timeout" env:"MIGRATION_LOCK_TIMEOUT" default:"5m" validate:"gt=0"`
+
+	LoggerLevel string `yaml:"logger_level" toml:"logger_level" env:"LOGGER_LEVEL" default:"dev" validate:"oneof=dev development production prodaction"`
+
+	ServerPort              int           `yaml:"server_port" toml:"server_port" env:"SERVER_PORT" default:"8080" validate:"min=1,max=65535"`
+	ServerReadTimeout       time.Duration `yaml:"server_read_timeout" toml:"server_read_timeout" env:"SERVER_READ_TIMEOUT" default:"15s" validate:"gt=0"`
+	ServerWriteTimeout      time.Duration `yaml:"server_write_timeout" toml:"server_write_timeout" env:"SERVER_WRITE_TIMEOUT" default:"30s" validate:"gt=0"`
+	ServerIdleTimeout       time.Duration `yaml:"server_idle_timeout" toml:"server_idle_timeout" env:"SERVER_IDLE_TIMEOUT" default:"60s" validate:"gt=0"`
+	ServerReadHeaderTimeout time.Duration `yaml:"server_read_header_timeout" toml:"server_read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT" default:"5s" validate:"gt=0"`
+	ServerMaxHeaderBytes    int           `yaml:"server_max_header_bytes" toml:"server_max_header_bytes" env:"SERVER_MAX_HEADER_BYTES" default:"65536" validate:"min=4096"`
+	ServerShutdownTimeout   time.Duration `yaml:"server_shutdown_timeout" toml:"server_shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT" default:"120s" validate:"gt=0"`
+	ShutdownDrainDelay      time.Duration `yaml:"shutdown_drain_delay" toml:"shutdown_drain_delay" env:"SHUTDOWN_DRAIN_DELAY" default:"5s" validate:"gte=0"`
+	HealthCheckTimeout      time.Duration `yaml:"health_check_timeout" toml:"health_check_timeout" env:"HEALTH_CHECK_TIMEOUT" default:"2s" validate:"gt=0"`
+
+	RequestBodyLimit    int64 `yaml:"request_body_limit" toml:"request_body_limit" env:"REQUEST_BODY_LIMIT" default:"1048576" validate:"min=1"`
+	AvatarBodyLimit     int64 `yaml:"avatar_body_limit" toml:"avatar_body_limit" env:"AVATAR_BODY_LIMIT" default:"10485760" validate:"min=1"`
+	AttachmentBodyLimit int64 `yaml:"attachment_body_limit" toml:"attachment_body_limit" env:"ATTACHMENT_BODY_LIMIT" default:"27262976" validate:"min=1"`
+
+	AvatarMaxSize      int64 `yaml:"avatar_max_size" toml:"avatar_max_size" env:"AVATAR_MAX_SIZE" default:"5242880" validate:"min=1"`
+	AvatarMaxDimension int   `yaml:"avatar_max_dimension" toml:"avatar_max_dimension" env:"AVATAR_MAX_DIMENSION" default:"4096" validate:"min=1"`
+	AttachmentMaxSize  int64 `yaml:"attachment_max_size" toml:"attachment_max_size" env:"ATTACHMENT_MAX_SIZE" default:"26214400" validate:"min=1"`
+
+	StorageUserQuota    int64 `yaml:"storage_user_quota" toml:"storage_user_quota" env:"STORAGE_USER_QUOTA" default:"1073741824" validate:"min=0"`
+	StorageProjectQuota int64 `yaml:"storage_project_quota" toml:"storage_project_quota" env:"STORAGE_PROJECT_QUOTA" default:"536870912" validate:"min=0"`
+
+	ScannerBackend string        `yaml:"scanner_backend" toml:"scanner_backend" env:"SCANNER_BACKEND" default:"none" validate:"oneof=none clamav"`
+	ClamavAddress  string        `yaml:"clamav_address" toml:"clamav_address" env:"CLAMAV_ADDRESS" default:"tcp://127.0.0.1:3310" validate:"required_if=ScannerBackend clamav"`
+	ScannerTimeout time.Duration `yaml:"scanner_timeout" toml:"scanner_timeout" env:"SCANNER_TIMEOUT" default:"60s" validate:"gt=0"`
+
+	TlsCertFile string `yaml:"tls_cert_file" toml:"tls_cert_file" env:"TLS_CERT_FILE" validate:"required_with=TlsKeyFile,omitempty,file"`
+	TlsKeyFile  string `yaml:"tls_key_file" toml:"tls_key_file" env:"TLS_KEY_FILE" validate:"required_with=TlsCertFile,omitempty,file"`
+
+	HstsMaxAge         time.Duration `yaml:"hsts_max_age" toml:"hsts_max_age" env:"HSTS_MAX_AGE" default:"8760h" validate:"gte=0"`
+	CorsAllowedOrigins []string      `yaml:"cors_allowed_origins" toml:"cors_allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+	TrustedProxies     []string      `yaml:"trusted_proxies" toml:"trusted_proxies" env:"TRUSTED_PROXIES" validate:"dive,cidr"`
+
+	RateLimitStore        string        `yaml:"rate_limit_store" toml:"rate_limit_store" env:"RATE_LIMIT_STORE" default:"memory" validate:"oneof=none memory postgres"`
+	RateLimitAuthRequests int           `yaml:"rate_limit_auth_requests" toml:"rate_limit_auth_requests" env:"RATE_LIMIT_AUTH_REQUESTS" default:"10" validate:"min=1"`
+	RateLimitAuthPeriod   time.Duration `yaml:"rate_limit_auth_period" toml:"rate_limit_auth_period" env:"RATE_LIMIT_AUTH_PERIOD" default:"1m" validate:"gt=0,max=1h"`
+	RateLimitApiRequests  int           `yaml:"rate_limit_api_requests" toml:"rate_limit_api_requests" env:"RATE_LIMIT_API_REQUESTS" default:"300" validate:"min=1"`
+	RateLimitApiPeriod    time.Duration `yaml:"rate_limit_api_period" toml:"rate_limit_api_period" env:"RATE_LIMIT_API_PERIOD" default:"1m" validate:"gt=0,max=1h"`
+	RateLimitIpRequests   int           `yaml:"rate_limit_ip_requests" toml:"rate_limit_ip_requests" env:"RATE_LIMIT_IP_REQUESTS" default:"600" validate:"min=1"`
+	RateLimitIpPeriod     time.Duration `yaml:"rate_limit_ip_period" toml:"rate_limit_ip_period" env:"RATE_LIMIT_IP_PERIOD" default:"1m" validate:"gt=0,max=1h"`
+
+	IdempotencyKeyTtl time.Duration `yaml:"idempotency_key_ttl" toml:"idempotency_key_ttl" env:"IDEMPOTENCY_KEY_TTL" default:"24h" validate:"gt=0"`
+
+	SmtpHost          string `yaml:"smtp_host" toml:"smtp_host" env:"SMTP_HOST" default:"smtp.gmail.com"`
+	SmtpPort          int    `yaml:"smtp_port" toml:"smtp_port" env:"SMTP_PORT" default:"587" validate:"min=1,max=65535"`
+	WorkGmail         string `yaml:"work_gmail" toml:"work_gmail" env:"WORK_GMAIL" validate:"omitempty,email"`
+	WorkGmailPassword string `yaml:"work_gmail_password" toml:"work_gmail_password" env:"WORK_GMAIL_PASSWORD" secret:"true"`
+
+	StorageBackend    string        `yaml:"storage_backend" toml:"storage_backend" env:"STORAGE_BACKEND" validate:"omitempty,oneof=local s3 cloudinary"`
+	StorageLocalPath  string        `yaml:"storage_local_path" toml:"storage_local_path" env:"STORAGE_LOCAL_PATH" default:"file_storage" validate:"required"`
+	StoragePublicUrl  string        `yaml:"storage_public_url" toml:"storage_public_url" env:"STORAGE_PUBLIC_URL" default:"/files" validate:"required"`
+	StorageSigningKey string        `yaml:"storage_signing_key" toml:"storage_signing_key" env:"STORAGE_SIGNING_KEY" secret:"true" validate:"omitempty,min=32"`
+	SignedUrlTtl      time.Duration `yaml:"signed_url_ttl" toml:"signed_url_ttl" env:"SIGNED_URL_TTL" default:"5m" validate:"gt=0,lte=168h"`
+
+	S3Endpoint  string `yaml:"s3_endpoint" toml:"s3_endpoint" env:"S3_ENDPOINT" validate:"required_if=StorageBackend s3"`
+	S3Region    string `yaml:"s3_region" toml:"s3_region" env:"S3_REGION" default:"us-east-1"`
+	S3Bucket    string `yaml:"s3_bucket" toml:"s3_bucket" env:"S3_BUCKET" validate:"required_if=StorageBackend s3"`
+	S3AccessKey string `yaml:"s3_access_key" toml:"s3_access_key" env:"S3_ACCESS_KEY" secret:"true" validate:"required_if=StorageBackend s3"`
+	S3SecretKey string `yaml:"s3_secret_key" toml:"s3_secret_key" env:"S3_SECRET_KEY" secret:"true" validate:"required_if=StorageBackend s3"`
+	S3UseSsl    bool   `yaml:"s3_use_ssl" toml:"s3_use_ssl" env:"S3_USE_SSL" default:"true"`
+
+	CloudinaryNameKey   string `yaml:"cloudinary_name_key" toml:"cloudinary_name_key" env:"CLOUDINARY_NAME_KEY" validate:"required_if=StorageBackend cloudinary"`
+	CloudinaryApiKey    string `yaml:"cloudinary_api_key" toml:"cloudinary_api_key" env:"CLOUDINARY_API_KEY" secret:"true" validate:"required_with=CloudinaryNameKey"`
+	CloudinarySecretKey string `yaml:"cloudinary_secret_key" toml:"cloudinary_secret_key" env:"CLOUDINARY_SECRET_KEY" secret:"true" validate:"required_with=CloudinaryNameKey"`
+
+	SearchLanguage string `yaml:"search_language" toml:"search_language" env:"SEARCH_LANGUAGE" default:"english" validate:"required,search_language"`
+
+	MetricsAddress string `yaml:"metrics_address" toml:"metrics_address" env:"METRICS_ADDRESS" validate:"omitempty,hostname_port"`
+
+	TracingExporter    string  `yaml:"tracing_exporter" toml:"tracing_exporter" env:"TRACING_EXPORTER" validate:"omitempty,oneof=none stdout otlp"`
+	TracingEndpoint    string  `yaml:"tracing_endpoint" toml:"tracing_endpoint" env:"TRACING_ENDPOINT" validate:"omitempty,url"`
+	TracingSampleRatio float64 `yaml:"tracing_sample_ratio" toml:"tracing_sample_ratio" env:"TRACING_SAMPLE_RATIO" default:"1" validate:"min=0,max=1"`
+	TracingServiceName string  `yaml:"tracing_service_name" toml:"tracing_service_name" env:"TRACING_SERVICE_NAME" default:"tasks-app-api" validate:"required"`
 }
 
-func GetConfiguration() Configuration {
-	return Configuration{
-		DatabaseName:        getOrDefault("DB_NAME", "restapi_dev"),
-		DatabaseHost:        getOrDefault("DB_HOST", "127.0.0.1"),
-		DatabasePort:        getOrDefault("DB_PORT", "5432"),
-		DatabaseUser:        getOrDefault("DB_USER", "postgres"),
-		DatabasePassword:    getOrDefault("DB_PASSWORD", "postgres"),
-		JwtSecret:           getOrDefault("JWT_SECRET", "1234567890"),
-		MigrateToVersion:    getOrDefault("MIGRATE", "latest"),
-		MigrationLocation:   getOrDefault("MIGRATION_LOCATION", "migrations"),
-		LoggerLevel:         getOrDefault("LOGGER_LEVEL", "dev"),
-		SmtpHost:            getOrDefault("SMTP_HOST", "smtp.gmail.com"),
-		SmtpPort:            getOrDefault("SMTP_PORT", "587"),
-		WorkGmail:           getOrDefault("WORK_GMAIL", "test@gmail.com"),
-		WorkGmailPassword:   getOrDefault("WORK_GMAIL_PASSWORD", "test_password"),
-		CloudinaryNameKey:   getOrDefault("CLOUDINARY_NAME_KEY", ""),
-		CloudinaryApiKey:    getOrDefault("CLOUDINARY_API_KEY", ""),
-		CloudinarySecretKey: getOrDefault("CLOUDINARY_SECRET_KEY", ""),
+// Storage returns the storage backend to use. When none is configured it
+// is Cloudinary if its credentials are set, and the local disk otherwise.
+func (c Configuration) Storage() string {
+	if c.StorageBackend != "" {
+		return c.StorageBackend
 	}
+	if c.CloudinaryNameKey != "" {
+		return "cloudinary"
+	}
+	return "local"
+}
+
+// SigningKey returns the key of the URLs signed by the API. Without
+// STORAGE_SIGNING_KEY it is derived from the JWT secret, so rotating
+// that secret also invalidates the URLs.
+func (c Configuration) SigningKey() string {
+	if c.StorageSigningKey != "" {
+		return c.StorageSigningKey
+	}
+	mac := hmac.New(sha256.New, []byte(c.JwtSecret))
+	mac.Write([]byte("signed urls"))
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
-func getOrDefault(key, defaultVal string) string {
-	env, set := os.LookupEnv(key)
-	if !set {
-		return defaultVal
+// TrustedProxyNets parses TrustedProxies, which validation checked.
+func (c Configuration) TrustedProxyNets() []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(c.TrustedProxies))
+	for _, cidr := range c.TrustedProxies {
+		if _, ipNet, err := net.ParseCIDR(cidr); err == nil {
+			nets = append(nets, ipNet)
+		}
 	}
-	return env
+	return nets
+}
+
+func (c Configuration) IsProduction() bool {
+	return c.LoggerLevel == "production" || c.LoggerLevel == "prodaction"
 }
@@ -6,14 +6,24 @@ import (
 	"go-rest-api/internal/infra/database"
 	"go-rest-api/internal/infra/database/repositories"
 	"go-rest-api/internal/infra/filesystem"
+	"go-rest-api/internal/infra/health"
 	"go-rest-api/internal/infra/http/controllers"
 	"go-rest-api/internal/infra/http/middlewares"
+	"go-rest-api/internal/infra/idempotency"
+	"go-rest-api/internal/infra/logger"
+	"go-rest-api/internal/infra/ratelimit"
+	"go-rest-api/internal/infra/scanning"
 	"net/http"
 
 	"github.com/go-chi/jwtauth/v5"
 )
 
 type Container struct {
+	Config config.Configuration
+	Health *health.Health
+	// RateLimitStore is nil when rate limiting is disabled.
+	RateLimitStore   ratelimit.Store
+	IdempotencyStore idempotency.Store
 	Services
 	Controllers
 	Middleware
@@ -23,50 +33,128 @@ type Services struct {
 	app.UserService
 	app.SessionService
 	app.ProjectService
+	app.SearchService
+	app.AttachmentService
 }
 
 type Controllers struct {
 	controllers.UserController
 	controllers.SessionController
 	controllers.ProjectController
+	controllers.AttachmentController
+	controllers.SearchController
+	controllers.HealthController
+	controllers.FileController
 }
 
 type Middleware struct {
 	AuthMw func(http.Handler) http.Handler
 }
 
-func New() Container {
-	cfg := config.GetConfiguration()
-	tknAuth := jwtauth.New("HS256", []byte(cfg.JwtSecret), nil)
+// Dependencies are the infrastructure the services are built on. New
+// connects them to Postgres and the configured storage; tests inject their
+// own through NewWithDependencies.
+type Dependencies struct {
+	UserRepository       repositories.UserRepository
+	SessionRepository    repositories.SessionRepository
+	ProjectRepository    repositories.ProjectRepository
+	AttachmentRepository repositories.AttachmentRepository
+	SearchRepository     repositories.SearchRepository
+	BlobStore            filesystem.BlobStore
+	Scanner              scanning.Scanner
+	// RateLimitStore may be nil to disable rate limiting.
+	RateLimitStore   ratelimit.Store
+	IdempotencyStore idempotency.Store
+	HealthChecks     []health.Check
+}
+
+func New(cfg config.Configuration) Container {
 	db := database.New(cfg)
 
-	cloudinaryService := filesystem.NewCloudinaryService(cfg)
-	// imageService := filesystem.NewImageStorageService("file_storage")
+	blobStore, err := filesystem.NewBlobStore(cfg)
+	if err != nil {
+		logger.Logger.Panic(err)
+		panic(err)
+	}
 
-	userRepo := repositories.NewUserRepository(db)
-	sessionRepo := repositories.NewSessionRepository(db)
-	projectRepo := repositories.NewProjectRepository(db)
+	checks := []health.Check{
+		{Name: "database", Run: db.PingContext},
+		{Name: "migrations", Run: database.MigrationCheck(db, cfg)},
+		{Name: "storage", Run: blobStore.Ping},
+	}
 
-	userService := app.NewUserService(userRepo, cfg, cloudinaryService)
-	sessionService := app.NewSessionService(sessionRepo, userService, tknAuth)
-	projectService := app.NewProjectService(projectRepo)
+	scanner, err := scanning.New(cfg)
+	if err != nil {
+		logger.Logger.Panic(err)
+		panic(err)
+	}
+	if cfg.ScannerBackend != "none" {
+		checks = append(checks, health.Check{Name: "scanner", Run: scanner.Ping})
+	}
+
+	var rateLimitStore ratelimit.Store
+	switch cfg.RateLimitStore {
+	case "memory":
+		rateLimitStore = ratelimit.NewMemoryStore()
+	case "postgres":
+		rateLimitStore = ratelimit.NewPostgresStore(db)
+	}
+
+	return NewWithDependencies(cfg, Dependencies{
+		UserRepository:       repositories.NewUserRepository(db),
+		SessionRepository:    repositories.NewSessionRepository(db),
+		ProjectRepository:    repositories.NewProjectRepository(db),
+		AttachmentRepository: repositories.NewAttachmentRepository(db),
+		SearchRepository:     repositories.NewSearchRepository(db, cfg.SearchLanguage),
+		BlobStore:            blobStore,
+		Scanner:              scanner,
+		RateLimitStore:       rateLimitStore,
+		IdempotencyStore:     idempotency.NewPostgresStore(db, cfg.ServerWriteTimeout),
+		HealthChecks:         checks,
+	})
+}
+
+func NewWithDependencies(cfg config.Configuration, deps Dependencies) Container {
+	tknAuth := jwtauth.New("HS256", []byte(cfg.JwtSecret), nil)
+
+	userService := app.NewUserService(deps.UserRepository, cfg, deps.BlobStore)
+	sessionService := app.NewSessionService(deps.SessionRepository, userService, tknAuth)
+	projectService := app.NewProjectService(deps.ProjectRepository, deps.AttachmentRepository, deps.BlobStore)
+	attachmentService := app.NewAttachmentService(deps.AttachmentRepository, cfg, deps.BlobStore, deps.Scanner)
+	searchService := app.NewSearchService(deps.SearchRepository)
 
 	userController := controllers.NewUserController(userService)
 	sessionController := controllers.NewSessionController(sessionService, userService)
 	projectController := controllers.NewProjectController(projectService)
+	attachmentController := controllers.NewAttachmentController(attachmentService)
+	searchController := controllers.NewSearchController(searchService)
+
+	healthChecker := health.New(cfg.HealthCheckTimeout, deps.HealthChecks...)
+	healthController := controllers.NewHealthController(healthChecker)
+	fileController := controllers.NewFileController(deps.BlobStore, filesystem.NewURLSigner(cfg.SigningKey(), filesystem.SignedPath), attachmentService)
 
 	authMiddleware := middlewares.AuthMiddleware(tknAuth, sessionService, userService)
 
 	return Container{
+		Config:           cfg,
+		Health:           healthChecker,
+		RateLimitStore:   deps.RateLimitStore,
+		IdempotencyStore: deps.IdempotencyStore,
 		Services: Services{
 			userService,
 			sessionService,
 			projectService,
+			searchService,
+			attachmentService,
 		},
 		Controllers: Controllers{
 			userController,
 			sessionController,
 			projectController,
+			attachmentController,
+			searchController,
+			healthController,
+			fileController,
 		},
 		Middleware: Middleware{
 			authMiddleware,
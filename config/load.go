@@ -0,0 +1,258 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/creasty/defaults"
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v3"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// searchLanguagePattern accepts names of text search configurations, which
+// end up in the DDL of the search column and cannot be bound there.
+var searchLanguagePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// Load builds the configuration from defaults, the file at path (skipped
+// when path is empty) and the environment, then validates it. The returned
+// error lists every problem found, one per line.
+func Load(path string) (Configuration, error) {
+	cfg, err := Defaults()
+	if err != nil {
+		return Configuration{}, err
+	}
+
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return Configuration{}, err
+		}
+	}
+
+	var problems []string
+	problems = append(problems, loadEnv(&cfg)...)
+	problems = append(problems, validate(cfg)...)
+	if len(problems) > 0 {
+		return Configuration{}, fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return cfg, nil
+}
+
+// Defaults returns the configuration with only the default values set,
+// without reading a file or the environment and without validating it.
+func Defaults() (Configuration, error) {
+	var cfg Configuration
+	err := defaults.Set(&cfg)
+	return cfg, err
+}
+
+func loadFile(path string, cfg *Configuration) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+		decoder.KnownFields(true)
+		err = decoder.Decode(cfg)
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	case ".toml":
+		var meta toml.MetaData
+		meta, err = toml.Decode(string(data), cfg)
+		if err == nil && len(meta.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown keys %v", meta.Undecoded())
+		}
+	default:
+		return fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv overrides fields from their environment variables. Empty
+// variables count as unset, so compose files can pass through optional ones.
+// Lists are written comma separated.
+func loadEnv(cfg *Configuration) []string {
+	var problems []string
+	value := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		key := field.Tag.Get("env")
+		if key == "" {
+			continue
+		}
+
+		raw, ok := lookupEnv(key)
+		if field.Tag.Get("secret") == "true" {
+			secret, fileOk, err := lookupEnvFile(key + "_FILE")
+			if err != nil {
+				problems = append(problems, err.Error())
+				continue
+			}
+			if fileOk {
+				raw, ok = secret, true
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		if err := setField(value.Field(i), raw); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", key, err))
+		}
+	}
+	return problems
+}
+
+func lookupEnv(key string) (string, bool) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return "", false
+	}
+	return value, true
+}
+
+func lookupEnvFile(key string) (string, bool, error) {
+	path, ok := lookupEnv(key)
+	if !ok {
+		return "", false, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("%s: %w", key, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), true, nil
+}
+
+func setField(field reflect.Value, raw string) error {
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q, use a value like 30s or 5m", raw)
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported field type %s", field.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+func validate(cfg Configuration) []string {
+	v := validator.New()
+	_ = v.RegisterValidation("migration_version", func(fl validator.FieldLevel) bool {
+		value := fl.Field().String()
+		_, err := strconv.ParseUint(value, 10, 64)
+		return value == "latest" || err == nil
+	})
+
+	_ = v.RegisterValidation("search_language", func(fl validator.FieldLevel) bool {
+		return searchLanguagePattern.MatchString(fl.Field().String())
+	})
+
+	err := v.Struct(cfg)
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		if err != nil {
+			return []string{err.Error()}
+		}
+		return nil
+	}
+
+	problems := make([]string, 0, len(validationErrors))
+	configType := reflect.TypeOf(cfg)
+	for _, fieldErr := range validationErrors {
+		field, _ := configType.FieldByName(fieldErr.StructField())
+		problems = append(problems, fmt.Sprintf("%s (%s): %s",
+			field.Tag.Get("env"), field.Tag.Get("yaml"), describe(fieldErr)))
+	}
+	return problems
+}
+
+func describe(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "required_with":
+		return "is required when " + fieldErr.Param() + " is set"
+	case "required_if":
+		condition := strings.SplitN(fieldErr.Param(), " ", 2)
+		other, _ := reflect.TypeOf(Configuration{}).FieldByName(condition[0])
+		return fmt.Sprintf("is required when %s is %s", other.Tag.Get("env"), condition[1])
+	case "min", "gte":
+		if fieldErr.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters long", fieldErr.Param())
+		}
+		return "must be at least " + fieldErr.Param()
+	case "max":
+		return "must be at most " + fieldErr.Param()
+	case "gt":
+		return "must be greater than " + fieldErr.Param()
+	case "oneof":
+		return fmt.Sprintf("must be one of [%s], got %q", fieldErr.Param(), fmt.Sprint(fieldErr.Value()))
+	case "migration_version":
+		return fmt.Sprintf("must be \"latest\" or a migration number, got %q", fmt.Sprint(fieldErr.Value()))
+	case "search_language":
+		return fmt.Sprintf("must name a text search configuration such as english or simple, got %q", fmt.Sprint(fieldErr.Value()))
+	case "email":
+		return "must be an email address"
+	case "url":
+		return "must be a URL"
+	case "file":
+		return "must be an existing file"
+	case "hostname_port":
+		return "must be an address like :9090 or host:9090"
+	default:
+		return fmt.Sprintf("failed the %q rule", fieldErr.Tag())
+	}
+}
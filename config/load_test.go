@@ -0,0 +1,152 @@
+package config_test
+
+import (
+	"bytes"
+	"go-rest-api/config"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const jwtSecret = "config-test-secret-that-is-32-bytes-long"
+
+// clearEnv unsets every configuration variable for the test, as empty
+// variables count as unset.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	configType := reflect.TypeOf(config.Configuration{})
+	for i := 0; i < configType.NumField(); i++ {
+		if key := configType.Field(i).Tag.Get("env"); key != "" {
+			t.Setenv(key, "")
+			t.Setenv(key+"_FILE", "")
+		}
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+	path := writeFile(t, "config.yaml", `
+database_name: from_file
+database_host: db.internal
+database_password: file-password
+jwt_secret: `+jwtSecret+`
+server_port: 9000
+`)
+	t.Setenv("DB_NAME", "from_env")
+	t.Setenv("REQUEST_BODY_LIMIT", "2048")
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.168.0.0/16")
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, got := range map[string][2]any{
+		"environment over file": {cfg.DatabaseName, "from_env"},
+		"file over default":     {cfg.DatabaseHost, "db.internal"},
+		"file integer":          {cfg.ServerPort, 9000},
+		"environment integer":   {cfg.RequestBodyLimit, int64(2048)},
+		"default":               {cfg.DatabaseUser, "postgres"},
+		"default duration":      {cfg.SignedUrlTtl, 5 * time.Minute},
+		"environment list":      {strings.Join(cfg.TrustedProxies, " "), "10.0.0.0/8 192.168.0.0/16"},
+	} {
+		if got[0] != got[1] {
+			t.Errorf("%s: got %v, want %v", name, got[0], got[1])
+		}
+	}
+}
+
+func TestLoadSecretFile(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("JWT_SECRET", jwtSecret)
+	t.Setenv("DB_PASSWORD", "env-password")
+	t.Setenv("DB_PASSWORD_FILE", writeFile(t, "db_password", "file-password\n"))
+
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DatabasePassword != "file-password" {
+		t.Errorf("database password = %q, want the content of DB_PASSWORD_FILE without the newline", cfg.DatabasePassword)
+	}
+
+	t.Setenv("DB_PASSWORD_FILE", filepath.Join(t.TempDir(), "missing"))
+	if _, err = config.Load(""); err == nil || !strings.Contains(err.Error(), "DB_PASSWORD_FILE") {
+		t.Errorf("err = %v, want one naming DB_PASSWORD_FILE", err)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DB_PASSWORD", "password")
+	t.Setenv("JWT_SECRET", "short")
+	t.Setenv("SERVER_PORT", "eighty")
+	t.Setenv("STORAGE_BACKEND", "floppy")
+
+	_, err := config.Load("")
+	if err == nil {
+		t.Fatal("loaded an invalid configuration")
+	}
+	for _, want := range []string{
+		`SERVER_PORT: invalid integer "eighty"`,
+		"JWT_SECRET (jwt_secret): must be at least 32 characters long",
+		"STORAGE_BACKEND (storage_backend): must be one of",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not contain %q:\n%v", want, err)
+		}
+	}
+
+	t.Setenv("JWT_SECRET", jwtSecret)
+	t.Setenv("SERVER_PORT", "")
+	t.Setenv("STORAGE_BACKEND", "")
+	path := writeFile(t, "config.yaml", "databse_name: typo\n")
+	if _, err = config.Load(path); err == nil || !strings.Contains(err.Error(), "databse_name") {
+		t.Errorf("err = %v, want the unknown key named", err)
+	}
+}
+
+func TestPrintRedacted(t *testing.T) {
+	cfg, err := config.Defaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.DatabasePassword = "database-password"
+	cfg.JwtSecret = jwtSecret
+	cfg.DatabaseName = "tasks"
+
+	var out bytes.Buffer
+	if err = config.Print(&out, cfg, true); err != nil {
+		t.Fatal(err)
+	}
+	printed := out.String()
+	for _, secret := range []string{"database-password", jwtSecret} {
+		if strings.Contains(printed, secret) {
+			t.Errorf("redacted output contains %q:\n%s", secret, printed)
+		}
+	}
+	for _, want := range []string{"database_password: '[redacted]'", "database_name: tasks", "s3_secret_key: \"\""} {
+		if !strings.Contains(printed, want) {
+			t.Errorf("redacted output does not contain %q:\n%s", want, printed)
+		}
+	}
+
+	out.Reset()
+	if err = config.Print(&out, cfg, false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "database_password: database-password") {
+		t.Errorf("unredacted output does not contain the password:\n%s", out.String())
+	}
+}
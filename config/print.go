@@ -0,0 +1,35 @@
+package config
+
+import (
+	"io"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redactedValue = "[redacted]"
+
+// Print writes cfg as YAML in the format Load reads. With redacted set,
+// secret values that are present are replaced by a placeholder.
+func Print(w io.Writer, cfg Configuration, redacted bool) error {
+	if redacted {
+		cfg = redact(cfg)
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(cfg); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func redact(cfg Configuration) Configuration {
+	value := reflect.ValueOf(&cfg).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		if value.Type().Field(i).Tag.Get("secret") == "true" && field.String() != "" {
+			field.SetString(redactedValue)
+		}
+	}
+	return cfg
+}
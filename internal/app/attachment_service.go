@@ -0,0 +1,307 @@
+package app
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-rest-api/config"
+	"go-rest-api/internal/domain"
+	"go-rest-api/internal/infra/database/repositories"
+	"go-rest-api/internal/infra/filesystem"
+	"go-rest-api/internal/infra/logger"
+	"go-rest-api/internal/infra/scanning"
+	"go-rest-api/internal/infra/tracing"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrScanPending = errors.New("the attachment has not been scanned for malware yet")
+	ErrInfected    = errors.New("the attachment is quarantined because malware was found in it")
+)
+
+type AttachmentService interface {
+	FindById(ctx context.Context, id uint64) (domain.Attachment, error)
+	FindByKey(ctx context.Context, key string) (domain.Attachment, error)
+	FindByProjectId(ctx context.Context, projectId uint64) ([]domain.Attachment, error)
+	Upload(ctx context.Context, attachment domain.Attachment, content io.Reader) (domain.Attachment, error)
+	Open(ctx context.Context, attachment domain.Attachment) (io.ReadCloser, error)
+	SignedURL(ctx context.Context, attachment domain.Attachment) (string, time.Time, error)
+	Delete(ctx context.Context, attachment domain.Attachment) error
+	DeleteOrphanedAttachments(ctx context.Context, minAge time.Duration, dryRun bool) ([]string, error)
+	ScanPending(ctx context.Context) ([]domain.Attachment, error)
+	Usage(ctx context.Context, userId uint64) (domain.StorageUsage, error)
+}
+
+type attachmentService struct {
+	attachmentRepo repositories.AttachmentRepository
+	configuration  config.Configuration
+	blobStore      filesystem.BlobStore
+	scanner        scanning.Scanner
+}
+
+func NewAttachmentService(attachmentRepository repositories.AttachmentRepository,
+	cfg config.Configuration, blobStore filesystem.BlobStore, scanner scanning.Scanner) AttachmentService {
+
+	return attachmentService{
+		attachmentRepo: attachmentRepository,
+		configuration:  cfg,
+		blobStore:      blobStore,
+		scanner:        scanner,
+	}
+}
+
+func (a attachmentService) FindById(ctx context.Context, id uint64) (domain.Attachment, error) {
+	ctx, span := tracing.Start(ctx, "AttachmentService.FindById")
+	defer span.End()
+
+	attachment, err := a.attachmentRepo.FindById(ctx, id)
+	if err != nil {
+		return domain.Attachment{}, err
+	}
+	return attachment, nil
+}
+
+func (a attachmentService) FindByKey(ctx context.Context, key string) (domain.Attachment, error) {
+	ctx, span := tracing.Start(ctx, "AttachmentService.FindByKey")
+	defer span.End()
+
+	attachment, err := a.attachmentRepo.FindByKey(ctx, key)
+	if err != nil {
+		return domain.Attachment{}, err
+	}
+	return attachment, nil
+}
+
+func (a attachmentService) FindByProjectId(ctx context.Context, projectId uint64) ([]domain.Attachment, error) {
+	ctx, span := tracing.Start(ctx, "AttachmentService.FindByProjectId")
+	defer span.End()
+
+	attachments, err := a.attachmentRepo.FindByProjectId(ctx, projectId)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// Upload stores content as an attachment of attachment.ProjectId, uploaded
+// by attachment.UploaderId under attachment.Filename. The rest of the
+// metadata is taken from the content. Files larger than the configured
+// maximum or than a whole storage quota are rejected with ErrFileTooLarge,
+// empty ones with ErrInvalidUpload. Files that do not fit in what is left
+// of a quota fail with domain.ErrQuotaExceeded.
+//
+// The file is scanned for malware once stored. When the scanner fails the
+// attachment stays pending until ScanPending succeeds.
+func (a attachmentService) Upload(ctx context.Context, attachment domain.Attachment, content io.Reader) (domain.Attachment, error) {
+	ctx, span := tracing.Start(ctx, "AttachmentService.Upload")
+	defer span.End()
+
+	file, err := spoolUpload(content, a.configuration.AttachmentMaxSize)
+	if err != nil {
+		return domain.Attachment{}, err
+	}
+	defer file.Close()
+	if file.size == 0 {
+		return domain.Attachment{}, fmt.Errorf("%w: the file is empty", ErrInvalidUpload)
+	}
+	quota := a.quota()
+	for _, limit := range []int64{quota.User, quota.Project} {
+		if limit > 0 && file.size > limit {
+			return domain.Attachment{}, fmt.Errorf("%w: the storage quota is %d bytes", ErrFileTooLarge, limit)
+		}
+	}
+
+	attachment.Filename = cleanFilename(attachment.Filename)
+	attachment.Size = file.size
+	attachment.ContentType = file.contentType
+	attachment.Checksum = hex.EncodeToString(file.sha256)
+	attachment.ScanStatus = domain.ScanPending
+	// Names chosen by clients never end up in keys.
+	attachment.Key = fmt.Sprintf("attachments/%d/%s", attachment.ProjectId, uuid.NewString())
+
+	_, err = a.blobStore.Put(ctx, attachment.Key, file.Reader(), file.size, file.contentType)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return domain.Attachment{}, err
+	}
+
+	// The repository checks the quota again with the usage locked, so
+	// concurrent uploads cannot overrun it together.
+	savedAttachment, err := a.attachmentRepo.Save(ctx, attachment, quota)
+	if err != nil {
+		if !errors.Is(err, domain.ErrQuotaExceeded) && !errors.Is(err, domain.ErrProjectArchived) {
+			logger.FromContext(ctx).Error(err)
+		}
+		deleteBlobs(ctx, a.blobStore, []string{attachment.Key})
+		return domain.Attachment{}, err
+	}
+	return a.scan(ctx, savedAttachment, file.Reader()), nil
+}
+
+// ScanPending scans the attachments still waiting for it, such as those
+// uploaded while the scanner was unavailable, and returns them with their
+// new status.
+func (a attachmentService) ScanPending(ctx context.Context) ([]domain.Attachment, error) {
+	ctx, span := tracing.Start(ctx, "AttachmentService.ScanPending")
+	defer span.End()
+
+	attachments, err := a.attachmentRepo.FindPendingScan(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return nil, err
+	}
+	for i, attachment := range attachments {
+		content, _, err := a.blobStore.Get(ctx, attachment.Key)
+		if err != nil {
+			logger.FromContext(ctx).Error(err)
+			continue
+		}
+		attachments[i] = a.scan(ctx, attachment, content)
+		content.Close()
+	}
+	return attachments, nil
+}
+
+// scan records the verdict of the scanner on content. Failures are logged
+// and leave the attachment pending.
+func (a attachmentService) scan(ctx context.Context, attachment domain.Attachment, content io.Reader) domain.Attachment {
+	ctx, cancel := context.WithTimeout(ctx, a.configuration.ScannerTimeout)
+	defer cancel()
+
+	result, err := a.scanner.Scan(ctx, content)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return attachment
+	}
+	status := domain.ScanClean
+	if result.Infected {
+		status = domain.ScanInfected
+		logger.FromContext(ctx).Warnw("malware found in an attachment, it is quarantined",
+			"attachment_id", attachment.Id, "project_id", attachment.ProjectId, "signature", result.Signature)
+	}
+	if err = a.attachmentRepo.SetScanStatus(ctx, attachment.Id, status); err != nil {
+		logger.FromContext(ctx).Error(err)
+		return attachment
+	}
+	attachment.ScanStatus = status
+	return attachment
+}
+
+// Usage reports the storage used by a user and by their projects with the
+// configured limits.
+func (a attachmentService) Usage(ctx context.Context, userId uint64) (domain.StorageUsage, error) {
+	ctx, span := tracing.Start(ctx, "AttachmentService.Usage")
+	defer span.End()
+
+	usage, err := a.attachmentRepo.Usage(ctx, userId)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return domain.StorageUsage{}, err
+	}
+	quota := a.quota()
+	usage.Limit = quota.User
+	for i := range usage.Projects {
+		usage.Projects[i].Limit = quota.Project
+	}
+	return usage, nil
+}
+
+func (a attachmentService) quota() domain.StorageQuota {
+	return domain.StorageQuota{
+		User:    a.configuration.StorageUserQuota,
+		Project: a.configuration.StorageProjectQuota,
+	}
+}
+
+// Open reads the content of attachment. The caller closes the reader.
+// Attachments that are not known to be clean fail with ErrScanPending or
+// ErrInfected.
+func (a attachmentService) Open(ctx context.Context, attachment domain.Attachment) (io.ReadCloser, error) {
+	ctx, span := tracing.Start(ctx, "AttachmentService.Open")
+	defer span.End()
+
+	if err := downloadable(attachment); err != nil {
+		return nil, err
+	}
+	content, _, err := a.blobStore.Get(ctx, attachment.Key)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return nil, err
+	}
+	return content, nil
+}
+
+// SignedURL returns a URL from which anyone holding it can download the
+// attachment until the returned time, or until it is deleted. Like Open it
+// refuses attachments that are not known to be clean; URLs served by the
+// API are checked again on every download.
+func (a attachmentService) SignedURL(ctx context.Context, attachment domain.Attachment) (string, time.Time, error) {
+	ctx, span := tracing.Start(ctx, "AttachmentService.SignedURL")
+	defer span.End()
+
+	if err := downloadable(attachment); err != nil {
+		return "", time.Time{}, err
+	}
+	expires := time.Now().Add(a.configuration.SignedUrlTtl)
+	signedUrl, err := a.blobStore.SignedURL(ctx, attachment.Key, a.configuration.SignedUrlTtl, attachment.Filename)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return "", time.Time{}, err
+	}
+	return signedUrl, expires, nil
+}
+
+func (a attachmentService) Delete(ctx context.Context, attachment domain.Attachment) error {
+	ctx, span := tracing.Start(ctx, "AttachmentService.Delete")
+	defer span.End()
+
+	err := a.attachmentRepo.Delete(ctx, attachment.Id)
+	if err != nil {
+		if !errors.Is(err, domain.ErrProjectArchived) {
+			logger.FromContext(ctx).Error(err)
+		}
+		return err
+	}
+	deleteBlobs(ctx, a.blobStore, []string{attachment.Key})
+	return nil
+}
+
+// DeleteOrphanedAttachments deletes stored attachment files that have no
+// metadata anymore, such as the files of deleted users. Files younger than
+// minAge are kept, they may belong to an upload in progress. With dryRun
+// nothing is deleted. It returns the keys of the orphans.
+func (a attachmentService) DeleteOrphanedAttachments(ctx context.Context, minAge time.Duration, dryRun bool) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "AttachmentService.DeleteOrphanedAttachments")
+	defer span.End()
+
+	orphans, err := findOrphans(ctx, a.blobStore, "attachments", minAge, func(ctx context.Context, projectId uint64) ([]string, error) {
+		attachments, err := a.attachmentRepo.FindByProjectId(ctx, projectId)
+		if err != nil {
+			return nil, err
+		}
+		keys := make([]string, len(attachments))
+		for i, attachment := range attachments {
+			keys[i] = attachment.Key
+		}
+		return keys, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deleteOrphans(ctx, a.blobStore, orphans, dryRun)
+}
+
+func downloadable(attachment domain.Attachment) error {
+	switch attachment.ScanStatus {
+	case domain.ScanClean:
+		return nil
+	case domain.ScanInfected:
+		return ErrInfected
+	}
+	return ErrScanPending
+}
@@ -0,0 +1,93 @@
+package app
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-rest-api/internal/domain"
+	"go-rest-api/internal/infra/imaging"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	ErrInvalidImage  = errors.New("file is not a valid image")
+	ErrImageTooLarge = errors.New("image is too large")
+)
+
+// avatarTypes are the image types accepted as avatars.
+var avatarTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// avatarImages are the files stored for an avatar.
+type avatarImages struct {
+	original   imaging.Image
+	thumbnails map[int]imaging.Image
+}
+
+// checkAvatar accepts PNG, JPEG, GIF and WebP images whose width and
+// height are at most maxDimension pixels.
+func checkAvatar(avatar *upload, maxDimension int) error {
+	if !avatarTypes[avatar.contentType] {
+		return fmt.Errorf("%w %s, upload a PNG, JPEG, GIF or WebP image", ErrUnsupportedMediaType, avatar.contentType)
+	}
+
+	// Only the header is decoded, so huge images are refused before
+	// anything allocates their pixels.
+	config, _, err := image.DecodeConfig(avatar.Reader())
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidImage, err)
+	}
+	if config.Width > maxDimension || config.Height > maxDimension {
+		return fmt.Errorf("%w: %dx%d pixels, at most %dx%d are allowed",
+			ErrImageTooLarge, config.Width, config.Height, maxDimension, maxDimension)
+	}
+	return nil
+}
+
+// processAvatar turns the avatar upright and re-encodes it, which drops
+// its EXIF data, then scales its centered square to every avatar size.
+func processAvatar(avatar *upload) (avatarImages, error) {
+	img, format, err := imaging.Decode(avatar.Reader())
+	if err != nil {
+		return avatarImages{}, fmt.Errorf("%w: %w", ErrInvalidImage, err)
+	}
+
+	images := avatarImages{thumbnails: make(map[int]imaging.Image, len(domain.AvatarSizes))}
+	images.original, err = imaging.Encode(img, format)
+	if err != nil {
+		return avatarImages{}, err
+	}
+	square := imaging.CropSquare(img)
+	for _, size := range domain.AvatarSizes {
+		images.thumbnails[size], err = imaging.Encode(imaging.Resize(square, size, size), format)
+		if err != nil {
+			return avatarImages{}, err
+		}
+	}
+	return images, nil
+}
+
+// defaultAvatar draws the identicon of a user without an avatar.
+func defaultAvatar(userId uint64, size int) (imaging.Image, error) {
+	return imaging.Encode(imaging.Identicon([]byte(fmt.Sprintf("user:%d", userId)), size), "png")
+}
+
+// avatarKey names avatars after the uploaded content, so every upload gets
+// its own keys and the stored files can be cached forever. Thumbnails get
+// their size appended, size is 0 for the original.
+func avatarKey(userId uint64, avatar *upload, size int, img imaging.Image) string {
+	name := hex.EncodeToString(avatar.sha256[:8])
+	if size > 0 {
+		name = fmt.Sprintf("%s-%d", name, size)
+	}
+	return fmt.Sprintf("avatars/%d/%s%s", userId, name, img.Extension)
+}
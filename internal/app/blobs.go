@@ -0,0 +1,96 @@
+package app
+
+import (
+	"context"
+	"go-rest-api/internal/infra/filesystem"
+	"go-rest-api/internal/infra/logger"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// findOrphans returns the keys of the blobs named "<prefix>/<owner id>/..."
+// that are older than minAge and not among the keys inUse reports for
+// their owner. Blobs younger than minAge may belong to an upload that is
+// not recorded yet.
+func findOrphans(ctx context.Context, blobStore filesystem.BlobStore, prefix string, minAge time.Duration,
+	inUse func(ctx context.Context, ownerId uint64) ([]string, error)) ([]string, error) {
+
+	blobs, err := blobStore.List(ctx, prefix+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-minAge)
+	// Blobs are listed by key, so by owner, but owners are looked up once
+	// whatever the order.
+	keysInUse := map[uint64][]string{}
+	var orphans []string
+	for _, blob := range blobs {
+		if blob.ModifiedAt.After(cutoff) {
+			continue
+		}
+		ownerId, ok := keyOwner(prefix, blob.Key)
+		if !ok {
+			orphans = append(orphans, blob.Key)
+			continue
+		}
+		keys, ok := keysInUse[ownerId]
+		if !ok {
+			keys, err = inUse(ctx, ownerId)
+			if err != nil {
+				return nil, err
+			}
+			keysInUse[ownerId] = keys
+		}
+		if !slices.Contains(keys, blob.Key) {
+			orphans = append(orphans, blob.Key)
+		}
+	}
+	return orphans, nil
+}
+
+// keyOwner returns the owner id of a key named "<prefix>/<owner id>/<name>".
+func keyOwner(prefix, key string) (uint64, bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 3 || parts[0] != prefix {
+		return 0, false
+	}
+	ownerId, err := strconv.ParseUint(parts[1], 10, 64)
+	return ownerId, err == nil
+}
+
+// deleteOrphans deletes the orphans found by findOrphans, unless dryRun.
+func deleteOrphans(ctx context.Context, blobStore filesystem.BlobStore, orphans []string, dryRun bool) ([]string, error) {
+	if dryRun {
+		return orphans, nil
+	}
+	for _, key := range orphans {
+		if err := blobStore.Delete(ctx, key); err != nil {
+			return nil, err
+		}
+	}
+	return orphans, nil
+}
+
+// deleteBlobs deletes what it can and logs the rest, which the storage
+// reconcile command removes later.
+func deleteBlobs(ctx context.Context, blobStore filesystem.BlobStore, keys []string) {
+	for _, key := range keys {
+		if err := blobStore.Delete(ctx, key); err != nil {
+			logger.FromContext(ctx).Errorw("could not delete blob", "key", key, "error", err)
+		}
+	}
+}
+
+// without returns the keys that are not in exclude.
+func without(keys, exclude []string) []string {
+	var rest []string
+	for _, key := range keys {
+		if !slices.Contains(exclude, key) {
+			rest = append(rest, key)
+		}
+	}
+	return rest
+}
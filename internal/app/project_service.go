@@ -1,70 +1,139 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"go-rest-api/internal/domain"
 	"go-rest-api/internal/infra/database/repositories"
+	"go-rest-api/internal/infra/filesystem"
 	"go-rest-api/internal/infra/logger"
+	"go-rest-api/internal/infra/tracing"
 )
 
 type ProjectService interface {
-	FindById(id uint64) (domain.Project, error)
-	FindByCreatorId(creatorId uint64, page, limit int32) (domain.Projects, error)
-	Save(project domain.Project) (domain.Project, error)
-	Update(project domain.Project) (domain.Project, error)
-	Delete(id uint64) error
+	FindById(ctx context.Context, id uint64) (domain.Project, error)
+	FindByCreatorId(ctx context.Context, creatorId uint64, query domain.ProjectQuery) (domain.Projects, error)
+	Save(ctx context.Context, project domain.Project) (domain.Project, error)
+	Update(ctx context.Context, project domain.Project) (domain.Project, error)
+	Delete(ctx context.Context, id uint64) error
+	Archive(ctx context.Context, id uint64) (domain.Project, error)
+	Unarchive(ctx context.Context, id uint64) (domain.Project, error)
 }
 
 type projectService struct {
-	projectRepository repositories.ProjectRepository
+	projectRepository    repositories.ProjectRepository
+	attachmentRepository repositories.AttachmentRepository
+	blobStore            filesystem.BlobStore
 }
 
-func NewProjectService(projectRepository repositories.ProjectRepository) ProjectService {
+func NewProjectService(projectRepository repositories.ProjectRepository,
+	attachmentRepository repositories.AttachmentRepository, blobStore filesystem.BlobStore) ProjectService {
+
 	return projectService{
-		projectRepository: projectRepository,
+		projectRepository:    projectRepository,
+		attachmentRepository: attachmentRepository,
+		blobStore:            blobStore,
 	}
 }
 
-func (p projectService) FindById(id uint64) (domain.Project, error) {
-	project, err := p.projectRepository.FindById(id)
+func (p projectService) FindById(ctx context.Context, id uint64) (domain.Project, error) {
+	ctx, span := tracing.Start(ctx, "ProjectService.FindById")
+	defer span.End()
+
+	project, err := p.projectRepository.FindById(ctx, id)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return domain.Project{}, err
 	}
 	return project, err
 }
 
-func (p projectService) FindByCreatorId(creatorId uint64, page int32, limit int32) (domain.Projects, error) {
-	projects, err := p.projectRepository.FindByCreatorId(creatorId, page, limit)
+func (p projectService) FindByCreatorId(ctx context.Context, creatorId uint64, query domain.ProjectQuery) (domain.Projects, error) {
+	ctx, span := tracing.Start(ctx, "ProjectService.FindByCreatorId")
+	defer span.End()
+
+	projects, err := p.projectRepository.FindByCreatorId(ctx, creatorId, query)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return projects, err
 	}
 	return projects, nil
 }
 
-func (p projectService) Save(project domain.Project) (domain.Project, error) {
-	createdProject, err := p.projectRepository.Save(project)
+func (p projectService) Save(ctx context.Context, project domain.Project) (domain.Project, error) {
+	ctx, span := tracing.Start(ctx, "ProjectService.Save")
+	defer span.End()
+
+	createdProject, err := p.projectRepository.Save(ctx, project)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return domain.Project{}, err
 	}
 	return createdProject, nil
 }
 
-func (p projectService) Update(project domain.Project) (domain.Project, error) {
-	updatedProject, err := p.projectRepository.Update(project)
+func (p projectService) Update(ctx context.Context, project domain.Project) (domain.Project, error) {
+	ctx, span := tracing.Start(ctx, "ProjectService.Update")
+	defer span.End()
+
+	updatedProject, err := p.projectRepository.Update(ctx, project)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return domain.Project{}, err
 	}
 	return updatedProject, nil
 }
 
-func (p projectService) Delete(id uint64) error {
-	err := p.projectRepository.Delete(id)
+func (p projectService) Delete(ctx context.Context, id uint64) error {
+	ctx, span := tracing.Start(ctx, "ProjectService.Delete")
+	defer span.End()
+
+	// The attachments go with the project, their files are deleted
+	// afterwards.
+	attachments, err := p.attachmentRepository.FindByProjectId(ctx, id)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return err
 	}
+
+	err = p.projectRepository.Delete(ctx, id)
+	if err != nil {
+		if !errors.Is(err, domain.ErrProjectArchived) {
+			logger.FromContext(ctx).Error(err)
+		}
+		return err
+	}
+
+	keys := make([]string, len(attachments))
+	for i, attachment := range attachments {
+		keys[i] = attachment.Key
+	}
+	deleteBlobs(ctx, p.blobStore, keys)
 	return nil
 }
+
+// Archive hides a project from listings by default and makes it read-only
+// until it is unarchived.
+func (p projectService) Archive(ctx context.Context, id uint64) (domain.Project, error) {
+	ctx, span := tracing.Start(ctx, "ProjectService.Archive")
+	defer span.End()
+
+	archivedProject, err := p.projectRepository.Archive(ctx, id)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return domain.Project{}, err
+	}
+	return archivedProject, nil
+}
+
+func (p projectService) Unarchive(ctx context.Context, id uint64) (domain.Project, error) {
+	ctx, span := tracing.Start(ctx, "ProjectService.Unarchive")
+	defer span.End()
+
+	unarchivedProject, err := p.projectRepository.Unarchive(ctx, id)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return domain.Project{}, err
+	}
+	return unarchivedProject, nil
+}
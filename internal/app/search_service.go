@@ -0,0 +1,65 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"go-rest-api/internal/domain"
+	"go-rest-api/internal/infra/database/repositories"
+	"go-rest-api/internal/infra/logger"
+	"go-rest-api/internal/infra/tracing"
+	"strings"
+	"unicode"
+)
+
+const maxSearchTerms = 8
+
+var ErrEmptySearchQuery = errors.New("search query has no searchable words")
+
+type SearchService interface {
+	Search(ctx context.Context, userId uint64, query domain.SearchQuery) (domain.SearchResults, error)
+}
+
+type searchService struct {
+	searchRepository repositories.SearchRepository
+}
+
+func NewSearchService(searchRepository repositories.SearchRepository) SearchService {
+	return searchService{
+		searchRepository: searchRepository,
+	}
+}
+
+func (s searchService) Search(ctx context.Context, userId uint64, query domain.SearchQuery) (domain.SearchResults, error) {
+	ctx, span := tracing.Start(ctx, "SearchService.Search")
+	defer span.End()
+
+	query.Terms = searchTerms(query.Text)
+	if len(query.Terms) == 0 {
+		return domain.SearchResults{}, ErrEmptySearchQuery
+	}
+	if query.Limit < 1 || query.Limit > domain.MaxSearchLimit {
+		query.Limit = domain.DefaultSearchLimit
+	}
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+
+	results, err := s.searchRepository.SearchProjects(ctx, userId, query)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return domain.SearchResults{}, err
+	}
+	return results, nil
+}
+
+// searchTerms splits user input into lowercase words made of letters and
+// digits only, which keeps tsquery operators out of the final query.
+func searchTerms(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) > maxSearchTerms {
+		words = words[:maxSearchTerms]
+	}
+	return words
+}
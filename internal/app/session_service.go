@@ -1,11 +1,14 @@
 package app
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"go-rest-api/internal/domain"
 	"go-rest-api/internal/infra/database/repositories"
 	"go-rest-api/internal/infra/logger"
+	"go-rest-api/internal/infra/metrics"
+	"go-rest-api/internal/infra/tracing"
 	"time"
 
 	"github.com/go-chi/jwtauth/v5"
@@ -14,11 +17,12 @@ import (
 )
 
 type SessionService interface {
-	Register(user domain.User) (domain.User, string, error)
-	Login(user domain.User) (domain.User, string, error)
-	Logout(sess domain.Session) error
-	Check(sess domain.Session) error
-	GenerateToken(user domain.User) (string, error)
+	Register(ctx context.Context, user domain.User) (domain.User, string, error)
+	Login(ctx context.Context, user domain.User) (domain.User, string, error)
+	Logout(ctx context.Context, sess domain.Session) error
+	Check(ctx context.Context, sess domain.Session) error
+	RevokeAll(ctx context.Context, userId uint64) (int64, error)
+	GenerateToken(ctx context.Context, user domain.User) (string, error)
 }
 
 type sessionService struct {
@@ -35,65 +39,103 @@ func NewSessionService(sr repositories.SessionRepository, us UserService, tokenA
 	}
 }
 
-func (s sessionService) Register(user domain.User) (domain.User, string, error) {
-	_, err := s.userServ.FindByEmail(user.Email)
+func (s sessionService) Register(ctx context.Context, user domain.User) (domain.User, string, error) {
+	ctx, span := tracing.Start(ctx, "SessionService.Register")
+	defer span.End()
+
+	_, err := s.userServ.FindByEmail(ctx, user.Email)
 	if err == nil {
 		return domain.User{}, "", errors.New("invalid credentials")
 	} else if !errors.Is(err, sql.ErrNoRows) {
 		return domain.User{}, "", err
 	}
 
-	user, err = s.userServ.Save(user)
+	user, err = s.userServ.Save(ctx, user)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return domain.User{}, "", err
 	}
 
-	token, err := s.GenerateToken(user)
+	token, err := s.GenerateToken(ctx, user)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return domain.User{}, "", err
 	}
 
 	return user, token, nil
 }
 
-func (s sessionService) Login(user domain.User) (domain.User, string, error) {
-	u, err := s.userServ.FindByEmail(user.Email)
+func (s sessionService) Login(ctx context.Context, user domain.User) (domain.User, string, error) {
+	ctx, span := tracing.Start(ctx, "SessionService.Login")
+	defer span.End()
+
+	u, err := s.userServ.FindByEmail(ctx, user.Email)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			logger.Logger.Error(err)
+			logger.FromContext(ctx).Error(err)
+			metrics.LoginAttempts.WithLabelValues("unknown_user").Inc()
+		} else {
+			metrics.LoginAttempts.WithLabelValues("error").Inc()
 		}
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return domain.User{}, "", err
 	}
 	valid := s.checkPasswordHash(user.Password, u.Password)
 	if !valid {
+		metrics.LoginAttempts.WithLabelValues("invalid_password").Inc()
 		return domain.User{}, "", errors.New("invalid credentials")
 	}
+	if u.Disabled() {
+		metrics.LoginAttempts.WithLabelValues("disabled").Inc()
+		return domain.User{}, "", ErrUserDisabled
+	}
 
-	token, err := s.GenerateToken(u)
+	token, err := s.GenerateToken(ctx, u)
 	if err != nil {
-		logger.Logger.Error(err)
+		metrics.LoginAttempts.WithLabelValues("error").Inc()
+		logger.FromContext(ctx).Error(err)
 		return domain.User{}, "", err
 	}
 
+	metrics.LoginAttempts.WithLabelValues("success").Inc()
 	return u, token, err
 }
 
-func (s sessionService) Logout(session domain.Session) error {
-	return s.sessionRepo.Delete(session)
+func (s sessionService) Logout(ctx context.Context, session domain.Session) error {
+	ctx, span := tracing.Start(ctx, "SessionService.Logout")
+	defer span.End()
+
+	return s.sessionRepo.Delete(ctx, session)
 }
 
-func (s sessionService) Check(session domain.Session) error {
-	return s.sessionRepo.Exists(session)
+func (s sessionService) Check(ctx context.Context, session domain.Session) error {
+	ctx, span := tracing.Start(ctx, "SessionService.Check")
+	defer span.End()
+
+	return s.sessionRepo.Exists(ctx, session)
 }
 
-func (s sessionService) GenerateToken(user domain.User) (string, error) {
+// RevokeAll deletes every session of the user, logging them out everywhere.
+func (s sessionService) RevokeAll(ctx context.Context, userId uint64) (int64, error) {
+	ctx, span := tracing.Start(ctx, "SessionService.RevokeAll")
+	defer span.End()
+
+	revoked, err := s.sessionRepo.DeleteByUserId(ctx, userId)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return 0, err
+	}
+	return revoked, nil
+}
+
+func (s sessionService) GenerateToken(ctx context.Context, user domain.User) (string, error) {
+	ctx, span := tracing.Start(ctx, "SessionService.GenerateToken")
+	defer span.End()
+
 	sess := domain.Session{UserId: user.Id, UUID: uuid.New()}
-	err := s.sessionRepo.Save(sess)
+	err := s.sessionRepo.Save(ctx, sess)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return "", err
 	}
 
@@ -105,7 +147,7 @@ func (s sessionService) GenerateToken(user domain.User) (string, error) {
 
 	_, tokenString, err := s.tokenAuth.Encode(claims)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return "", err
 	}
 	return tokenString, nil
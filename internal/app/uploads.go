@@ -0,0 +1,99 @@
+package app
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+var (
+	ErrInvalidUpload        = errors.New("upload could not be read")
+	ErrFileTooLarge         = errors.New("file is too large")
+	ErrUnsupportedMediaType = errors.New("unsupported file type")
+)
+
+// upload is a file received from a client, spooled to a temporary file so
+// that it can be checked before it is stored and its size is known.
+type upload struct {
+	file *os.File
+	size int64
+	// contentType and extension are detected from the content, the name
+	// and type claimed by the client are not trusted.
+	contentType string
+	extension   string
+	sha256      []byte
+}
+
+// spoolUpload copies r to a temporary file. It stops with ErrFileTooLarge
+// as soon as more than maxBytes arrive. The caller closes the upload.
+func spoolUpload(r io.Reader, maxBytes int64) (*upload, error) {
+	file, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	u := &upload{file: file}
+
+	sum := sha256.New()
+	u.size, err = io.Copy(io.MultiWriter(file, sum), io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		u.Close()
+		return nil, fmt.Errorf("%w: %w", ErrInvalidUpload, err)
+	}
+	if u.size > maxBytes {
+		u.Close()
+		return nil, fmt.Errorf("%w: it must not be larger than %d bytes", ErrFileTooLarge, maxBytes)
+	}
+	u.sha256 = sum.Sum(nil)
+
+	detected, err := mimetype.DetectReader(u.Reader())
+	if err != nil {
+		u.Close()
+		return nil, err
+	}
+	u.contentType = detected.String()
+	u.extension = detected.Extension()
+	return u, nil
+}
+
+// Reader reads the upload from the start.
+func (u *upload) Reader() io.ReadSeeker {
+	_, _ = u.file.Seek(0, io.SeekStart)
+	return u.file
+}
+
+func (u *upload) Close() error {
+	u.file.Close()
+	return os.Remove(u.file.Name())
+}
+
+// maxFilenameBytes is the longest file name most file systems accept.
+const maxFilenameBytes = 255
+
+// cleanFilename turns a file name claimed by a client into one that is safe
+// to show and to offer for download: its last path element, without
+// control characters and at most maxFilenameBytes long.
+func cleanFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, name))
+	for len(name) > maxFilenameBytes {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "" || name == "." || name == ".." || name == "/" {
+		return "file"
+	}
+	return name
+}
@@ -1,13 +1,20 @@
 package app
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"go-rest-api/config"
 	"go-rest-api/internal/domain"
 	"go-rest-api/internal/infra/database/repositories"
 	"go-rest-api/internal/infra/filesystem"
+	"go-rest-api/internal/infra/imaging"
 	"go-rest-api/internal/infra/logger"
-	"strconv"
+	"go-rest-api/internal/infra/tracing"
+	"io"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -15,58 +22,77 @@ import (
 )
 
 type UserService interface {
-	FindById(id uint64) (domain.User, error)
-	FindByEmail(email string) (domain.User, error)
-	FindByEmailConfirmationToken(confToken string) (domain.User, error)
-	Save(user domain.User) (domain.User, error)
-	UpdateUserAvatar(user domain.User) (domain.User, error)
-	ConfirmUserEmail(user domain.User) error
-	Delete(id uint64) error
+	FindById(ctx context.Context, id uint64) (domain.User, error)
+	FindByEmail(ctx context.Context, email string) (domain.User, error)
+	FindByEmailConfirmationToken(ctx context.Context, confToken string) (domain.User, error)
+	Save(ctx context.Context, user domain.User) (domain.User, error)
+	UpdateUserAvatar(ctx context.Context, user domain.User, image io.Reader) (domain.User, error)
+	RemoveAvatar(ctx context.Context, user domain.User) (domain.User, error)
+	DefaultAvatar(ctx context.Context, id uint64, size int) (imaging.Image, error)
+	DeleteOrphanedAvatars(ctx context.Context, minAge time.Duration, dryRun bool) ([]string, error)
+	ConfirmUserEmail(ctx context.Context, user domain.User) error
+	ResetPassword(ctx context.Context, id uint64, password string) error
+	Disable(ctx context.Context, id uint64) (domain.User, error)
+	Delete(ctx context.Context, id uint64) error
 }
 
+var ErrUserDisabled = errors.New("user is disabled")
+
 type userService struct {
-	userRepo          repositories.UserRepository
-	configuration     config.Configuration
-	cloudinaryService *filesystem.CloudinaryService
+	userRepo      repositories.UserRepository
+	configuration config.Configuration
+	blobStore     filesystem.BlobStore
 }
 
 func NewUserService(userRepository repositories.UserRepository,
-	cfg config.Configuration, cloudinaryService *filesystem.CloudinaryService) UserService {
+	cfg config.Configuration, blobStore filesystem.BlobStore) UserService {
 
 	return userService{
-		userRepo:          userRepository,
-		configuration:     cfg,
-		cloudinaryService: cloudinaryService,
+		userRepo:      userRepository,
+		configuration: cfg,
+		blobStore:     blobStore,
 	}
 }
 
-func (u userService) FindById(id uint64) (domain.User, error) {
-	user, err := u.userRepo.FindById(id)
+func (u userService) FindById(ctx context.Context, id uint64) (domain.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.FindById")
+	defer span.End()
+
+	user, err := u.userRepo.FindById(ctx, id)
 	if err != nil {
 		return domain.User{}, err
 	}
 	return user, nil
 }
 
-func (u userService) FindByEmail(email string) (domain.User, error) {
-	user, err := u.userRepo.FindByEmail(email)
+func (u userService) FindByEmail(ctx context.Context, email string) (domain.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.FindByEmail")
+	defer span.End()
+
+	user, err := u.userRepo.FindByEmail(ctx, email)
 	if err != nil {
 		return domain.User{}, err
 	}
 	return user, nil
 }
 
-func (u userService) FindByEmailConfirmationToken(confToken string) (domain.User, error) {
-	user, err := u.userRepo.FindByEmailConfirmationToken(confToken)
+func (u userService) FindByEmailConfirmationToken(ctx context.Context, confToken string) (domain.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.FindByEmailConfirmationToken")
+	defer span.End()
+
+	user, err := u.userRepo.FindByEmailConfirmationToken(ctx, confToken)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return domain.User{}, err
 	}
 
 	return user, err
 }
 
-func (u userService) Save(user domain.User) (domain.User, error) {
+func (u userService) Save(ctx context.Context, user domain.User) (domain.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.Save")
+	defer span.End()
+
 	var err error
 	user.Password, err = generatePasswordHash(user.Password)
 	if err != nil {
@@ -77,11 +103,11 @@ func (u userService) Save(user domain.User) (domain.User, error) {
 
 	// err = u.sendEmail(user)
 	// if err != nil {
-	// 	logger.Logger.Error(err)
+	// 	logger.FromContext(ctx).Error(err)
 	// 	return domain.User{}, err
 	// }
 
-	user, err = u.userRepo.Save(user)
+	user, err = u.userRepo.Save(ctx, user)
 	if err != nil {
 		return domain.User{}, err
 	}
@@ -89,60 +115,216 @@ func (u userService) Save(user domain.User) (domain.User, error) {
 	return user, nil
 }
 
-func (u userService) UpdateUserAvatar(user domain.User) (domain.User, error) {
-	imageFileName := "file_" + strconv.FormatInt(time.Now().UnixNano(), 32)
+// UpdateUserAvatar stores the image read from image as the avatar of user,
+// together with square thumbnails of it.
+// Files that are too large or are not a supported image are rejected with
+// ErrFileTooLarge, ErrImageTooLarge, ErrUnsupportedMediaType or
+// ErrInvalidImage.
+func (u userService) UpdateUserAvatar(ctx context.Context, user domain.User, image io.Reader) (domain.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.UpdateUserAvatar")
+	defer span.End()
+
+	avatar, err := spoolUpload(image, u.configuration.AvatarMaxSize)
+	if err != nil {
+		return domain.User{}, err
+	}
+	defer avatar.Close()
+
+	err = checkAvatar(avatar, u.configuration.AvatarMaxDimension)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	images, err := processAvatar(avatar)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	// Keys depend on the content, so uploading the current avatar again
+	// stores nothing new and must not delete anything.
+	oldKeys := u.avatarKeys(user)
+	var newKeys []string
+	cleanUp := func() {
+		deleteBlobs(ctx, u.blobStore, without(newKeys, oldKeys))
+	}
 
-	imageUrl, err := u.cloudinaryService.SaveImageToCloudinary(*user.Avatar, imageFileName)
+	original, err := u.putImage(ctx, avatarKey(user.Id, avatar, 0, images.original), images.original)
 	if err != nil {
-		logger.Logger.Error(err)
 		return domain.User{}, err
 	}
+	newKeys = append(newKeys, original.Key)
+	sizes := make(map[int]string, len(images.thumbnails))
+	for size, thumbnail := range images.thumbnails {
+		blob, err := u.putImage(ctx, avatarKey(user.Id, avatar, size, thumbnail), thumbnail)
+		if err != nil {
+			cleanUp()
+			return domain.User{}, err
+		}
+		newKeys = append(newKeys, blob.Key)
+		sizes[size] = blob.URL
+	}
+	user.Avatar = &original.URL
+	user.AvatarSizes = sizes
+	user.AvatarKeys = newKeys
+
+	updatedUser, err := u.userRepo.UpdateUserAvatar(ctx, user)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		cleanUp()
+		return domain.User{}, err
+	}
+
+	// The new avatar is in place, files of the old one that are left behind
+	// when this fails are found by DeleteOrphanedAvatars.
+	deleteBlobs(ctx, u.blobStore, without(oldKeys, newKeys))
+	return updatedUser, nil
+}
+
+// RemoveAvatar deletes the avatar of user, who gets the generated one
+// again.
+func (u userService) RemoveAvatar(ctx context.Context, user domain.User) (domain.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.RemoveAvatar")
+	defer span.End()
 
-	*user.Avatar = imageUrl
+	oldKeys := u.avatarKeys(user)
+	user.Avatar = nil
+	user.AvatarSizes = nil
+	user.AvatarKeys = nil
 
-	updatedUser, err := u.userRepo.UpdateUserAvatar(user)
+	updatedUser, err := u.userRepo.UpdateUserAvatar(ctx, user)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return domain.User{}, err
 	}
+	deleteBlobs(ctx, u.blobStore, oldKeys)
 	return updatedUser, nil
 }
 
-func (u userService) ConfirmUserEmail(user domain.User) error {
-	currentUser, err := u.FindByEmailConfirmationToken(user.EmailConfirmationToken)
+// DeleteOrphanedAvatars deletes the stored avatar files that no user
+// refers to anymore, left behind by failed deletes or uploads. Files
+// younger than minAge are kept, they may belong to an upload in progress.
+// With dryRun nothing is deleted. It returns the keys of the orphans.
+func (u userService) DeleteOrphanedAvatars(ctx context.Context, minAge time.Duration, dryRun bool) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "UserService.DeleteOrphanedAvatars")
+	defer span.End()
+
+	orphans, err := findOrphans(ctx, u.blobStore, "avatars", minAge, func(ctx context.Context, userId uint64) ([]string, error) {
+		user, err := u.userRepo.FindById(ctx, userId)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return u.avatarKeys(user), nil
+	})
 	if err != nil {
-		logger.Logger.Error(err)
+		return nil, err
+	}
+	return deleteOrphans(ctx, u.blobStore, orphans, dryRun)
+}
+
+// DefaultAvatar draws the PNG identicon shown for the user instead of an
+// uploaded avatar. It depends on the id only, so it never changes.
+func (u userService) DefaultAvatar(ctx context.Context, id uint64, size int) (imaging.Image, error) {
+	ctx, span := tracing.Start(ctx, "UserService.DefaultAvatar")
+	defer span.End()
+
+	if _, err := u.userRepo.FindById(ctx, id); err != nil {
+		return imaging.Image{}, err
+	}
+	avatar, err := defaultAvatar(id, size)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return imaging.Image{}, err
+	}
+	return avatar, nil
+}
+
+func (u userService) putImage(ctx context.Context, key string, img imaging.Image) (filesystem.BlobInfo, error) {
+	blob, err := u.blobStore.Put(ctx, key, bytes.NewReader(img.Data), int64(len(img.Data)), img.ContentType)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return filesystem.BlobInfo{}, err
+	}
+	return blob, nil
+}
+
+func (u userService) ConfirmUserEmail(ctx context.Context, user domain.User) error {
+	ctx, span := tracing.Start(ctx, "UserService.ConfirmUserEmail")
+	defer span.End()
+
+	currentUser, err := u.FindByEmailConfirmationToken(ctx, user.EmailConfirmationToken)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
 		return err
 	}
 
-	err = u.userRepo.ConfirmUserEmail(currentUser)
+	err = u.userRepo.ConfirmUserEmail(ctx, currentUser)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return err
 	}
 
 	return nil
 }
 
-func (u userService) Delete(id uint64) error {
-	deletedUser, err := u.FindById(id)
+func (u userService) ResetPassword(ctx context.Context, id uint64, password string) error {
+	ctx, span := tracing.Start(ctx, "UserService.ResetPassword")
+	defer span.End()
+
+	passwordHash, err := generatePasswordHash(password)
 	if err != nil {
-		logger.Logger.Error(err)
 		return err
 	}
 
-	if deletedUser.Avatar != nil {
-		err = u.cloudinaryService.DeleteImage(*deletedUser.Avatar)
-		if err != nil {
-			logger.Logger.Error(err)
-			return err
-		}
+	err = u.userRepo.UpdatePassword(ctx, id, passwordHash)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return err
 	}
+	return nil
+}
 
-	err = u.userRepo.Delete(id)
+func (u userService) Disable(ctx context.Context, id uint64) (domain.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.Disable")
+	defer span.End()
+
+	user, err := u.userRepo.Disable(ctx, id)
 	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return domain.User{}, err
+	}
+	return user, nil
+}
+
+func (u userService) Delete(ctx context.Context, id uint64) error {
+	ctx, span := tracing.Start(ctx, "UserService.Delete")
+	defer span.End()
+
+	deletedUser, err := u.FindById(ctx, id)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
 		return err
 	}
+
+	err = u.userRepo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	deleteBlobs(ctx, u.blobStore, u.avatarKeys(deletedUser))
+	return nil
+}
+
+// avatarKeys returns the keys of the files stored for the avatar of user.
+// Avatars uploaded before the keys were kept have a single file, whose key
+// is recovered from its URL. Avatars uploaded before the blob store was
+// introduced have URLs the key cannot be recovered from, they are left in
+// place.
+func (u userService) avatarKeys(user domain.User) []string {
+	if len(user.AvatarKeys) > 0 || user.Avatar == nil {
+		return user.AvatarKeys
+	}
+	if key, ok := strings.CutPrefix(*user.Avatar, u.blobStore.URL("")); ok {
+		return []string{key}
+	}
 	return nil
 }
 
@@ -160,7 +342,7 @@ func (u userService) Delete(id uint64) error {
 // 		u.configuration.SmtpHost+":"+u.configuration.SmtpPort, auth, u.configuration.WorkGmail, []string{user.Email}, []byte(emailBody),
 // 	)
 // 	if err != nil {
-// 		logger.Logger.Error(err)
+// 		logger.FromContext(ctx).Error(err)
 // 		return err
 // 	}
 
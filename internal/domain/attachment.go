@@ -0,0 +1,28 @@
+package domain
+
+import "time"
+
+// ScanStatus tells whether an attachment was checked for malware. Only
+// clean attachments can be downloaded.
+type ScanStatus string
+
+const (
+	ScanPending  ScanStatus = "pending"
+	ScanClean    ScanStatus = "clean"
+	ScanInfected ScanStatus = "infected"
+)
+
+// Attachment is a file attached to a project. The content is kept in the
+// blob store under Key; Checksum is its hex encoded SHA-256.
+type Attachment struct {
+	Id          uint64
+	ProjectId   uint64
+	UploaderId  uint64
+	Filename    string
+	Size        int64
+	ContentType string
+	Checksum    string
+	Key         string
+	ScanStatus  ScanStatus
+	CreatedAt   time.Time
+}
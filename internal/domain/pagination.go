@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// ParseSort reads a sort expression like "title" or "-created_at".
+func ParseSort(s string) Sort {
+	if strings.HasPrefix(s, "-") {
+		return Sort{Field: s[1:], Desc: true}
+	}
+	return Sort{Field: s}
+}
+
+func (s Sort) String() string {
+	if s.Desc {
+		return "-" + s.Field
+	}
+	return s.Field
+}
+
+// Cursor points at the last row of a page. Value holds the sort column of
+// that row, Id breaks ties between rows with equal values.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v,omitempty"`
+	Id    uint64 `json:"i"`
+}
+
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err = json.Unmarshal(data, &c); err != nil || c.Sort == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
@@ -1,19 +1,63 @@
 package domain
 
+import (
+	"errors"
+	"time"
+)
+
+const (
+	DefaultProjectsLimit int32 = 20
+	MaxProjectsLimit     int32 = 100
+	DefaultProjectsSort        = "-created_at"
+)
+
+var ErrProjectArchived = errors.New("the project is archived, unarchive it to change it")
+
+// ArchivedFilter selects projects in a listing by whether they are
+// archived. The zero value excludes archived projects.
+type ArchivedFilter string
+
+const (
+	ArchivedExclude ArchivedFilter = "exclude"
+	ArchivedInclude ArchivedFilter = "include"
+	ArchivedOnly    ArchivedFilter = "only"
+)
+
+// ProjectSortFields lists the fields a project listing may be sorted by.
+var ProjectSortFields = []string{"id", "title", "created_at"}
+
 type Project struct {
 	Id          uint64
 	Title       string
 	Description string
 	CreatorId   uint64
+	CreatedAt   time.Time
+	// ArchivedAt is nil unless the project is archived, which makes it
+	// read-only.
+	ArchivedAt *time.Time
 }
 
 type Projects struct {
-	Projects    []Project
-	Total       uint64
-	CurrentPage int32
-	LastPage    int32
+	Projects   []Project
+	NextCursor *Cursor
+	Total      *uint64
+}
+
+type ProjectQuery struct {
+	Cursor        *Cursor
+	Limit         int32
+	Sort          Sort
+	TitleContains string
+	CreatedFrom   *time.Time
+	CreatedTo     *time.Time
+	WithTotal     bool
+	Archived      ArchivedFilter
 }
 
 func (p Project) GetOwnerId() uint64 {
 	return p.CreatorId
 }
+
+func (p Project) IsArchived() bool {
+	return p.ArchivedAt != nil
+}
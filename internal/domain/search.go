@@ -0,0 +1,30 @@
+package domain
+
+const (
+	DefaultSearchLimit int32 = 20
+	MaxSearchLimit     int32 = 50
+)
+
+type SearchQuery struct {
+	Text   string
+	Terms  []string
+	Limit  int32
+	Offset int32
+	// Archived selects projects like in listings, excluding archived ones
+	// by default.
+	Archived ArchivedFilter
+}
+
+type SearchResult struct {
+	Type               string
+	Id                 uint64
+	Title              string
+	TitleSnippet       string
+	DescriptionSnippet string
+	Rank               float32
+}
+
+type SearchResults struct {
+	Results []SearchResult
+	Total   uint64
+}
@@ -0,0 +1,27 @@
+package domain
+
+import "errors"
+
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// StorageQuota caps the bytes of attachments a user may upload and a
+// project may hold. Zero means no limit.
+type StorageQuota struct {
+	User    int64
+	Project int64
+}
+
+// StorageUsage is the bytes of attachments a user uploaded and those held
+// by each project they created, with the limits that apply to them.
+type StorageUsage struct {
+	Used     int64
+	Limit    int64
+	Projects []ProjectStorageUsage
+}
+
+type ProjectStorageUsage struct {
+	ProjectId uint64
+	Title     string
+	Used      int64
+	Limit     int64
+}
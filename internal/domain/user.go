@@ -1,11 +1,26 @@
 package domain
 
+import "time"
+
+// AvatarSizes are the widths in pixels of the square avatar thumbnails.
+var AvatarSizes = []int{32, 64, 256}
+
+// User is an account. AvatarSizes holds the URLs of the square thumbnails
+// of the avatar by their width, AvatarKeys the blob store keys of the
+// avatar and its thumbnails.
 type User struct {
 	Id                     uint64
 	Name                   string
 	Email                  string
 	Avatar                 *string
+	AvatarSizes            map[int]string
+	AvatarKeys             []string
 	Password               string
 	EmailConfirmed         bool
 	EmailConfirmationToken string
+	DisabledAt             *time.Time
+}
+
+func (u User) Disabled() bool {
+	return u.DisabledAt != nil
 }
@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"go-rest-api/config"
 	"go-rest-api/internal/infra/logger"
+	"go-rest-api/internal/infra/metrics"
 
+	"github.com/XSAM/otelsql"
 	_ "github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 type databaseManager struct {
@@ -14,20 +17,29 @@ type databaseManager struct {
 }
 
 func New(cfg config.Configuration) *sql.DB {
-	dbManager := databaseManager{
-		cfg: cfg,
-	}
-
-	db, err := dbManager.newDatabase()
+	db, err := Open(cfg)
 	if err != nil {
 		logger.Logger.Panic(err)
 		panic(err)
 	}
+	metrics.RegisterDB(db, cfg.DatabaseName)
 	return db
 }
 
+// Open connects to the configured database without registering pool
+// metrics, for short-lived connections such as the migration runner.
+func Open(cfg config.Configuration) (*sql.DB, error) {
+	dbManager := databaseManager{
+		cfg: cfg,
+	}
+	return dbManager.newDatabase()
+}
+
 func (dm databaseManager) newDatabase() (*sql.DB, error) {
-	db, err := sql.Open("postgres", dm.getConnectionString())
+	db, err := otelsql.Open("postgres", dm.getConnectionString(),
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{OmitConnResetSession: true, OmitRows: true}),
+	)
 	if err != nil {
 		logger.Logger.Error(err)
 		return nil, err
@@ -40,7 +52,7 @@ func (dm databaseManager) newDatabase() (*sql.DB, error) {
 }
 
 func (dm databaseManager) getConnectionString() string {
-	connectingStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+	connectingStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
 		dm.cfg.DatabaseHost, dm.cfg.DatabasePort, dm.cfg.DatabaseUser, dm.cfg.DatabasePassword, dm.cfg.DatabaseName)
 	return connectingStr
 }
@@ -1,71 +1,223 @@
 package database
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"go-rest-api/config"
 	"go-rest-api/internal/infra/logger"
+	"go-rest-api/migrations"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 )
 
-func Migrate(cfg config.Configuration) error {
-	if cfg.MigrateToVersion == "" {
-		logger.Logger.Error("Empty migration version")
-		return nil
+// migrationLockKey identifies the advisory lock held while migrating.
+const migrationLockKey int64 = 4_207_113_221
+
+const migrationLockRetry = time.Second
+
+var ErrMigrationLockTimeout = errors.New("timed out waiting for the migration lock")
+
+type MigrationState struct {
+	Version uint
+	Dirty   bool
+}
+
+// Migrate brings the schema to the version pinned by MIGRATE, or to the
+// newest embedded migration, then builds the search column with
+// SEARCH_LANGUAGE. A failed migration is returned as is, leaving the schema
+// dirty for an operator to repair; it is never forced.
+func Migrate(ctx context.Context, cfg config.Configuration) error {
+	return withMigrator(ctx, cfg, func(conn *sql.Conn, m *migrate.Migrate) error {
+		var err error
+		if version, parseErr := strconv.ParseUint(cfg.MigrateToVersion, 10, 64); parseErr == nil {
+			logger.Logger.Infof("Migrate: starting migration to version %d", version)
+			err = m.Migrate(uint(version))
+		} else {
+			logger.Logger.Info("Migrate: starting migration to the latest version")
+			err = m.Up()
+		}
+		if errors.Is(err, migrate.ErrNoChange) {
+			logger.Logger.Info("Migrate: no changes found")
+		} else if err != nil {
+			return describeMigrationError(m, err)
+		} else {
+			logger.Logger.Info("Migrate: migrations are done successfully")
+		}
+		return syncSearchLanguage(ctx, conn, cfg.SearchLanguage)
+	})
+}
+
+// WithMigrator runs fn with a migrator over the embedded migrations while
+// holding a Postgres advisory lock, so replicas starting together and
+// tasksctl runs apply migrations one at a time. Waiting for the lock gives
+// up after MigrationLockTimeout.
+func WithMigrator(ctx context.Context, cfg config.Configuration, fn func(m *migrate.Migrate) error) error {
+	return withMigrator(ctx, cfg, func(_ *sql.Conn, m *migrate.Migrate) error {
+		return fn(m)
+	})
+}
+
+// withMigrator is WithMigrator that also passes the locked connection, for
+// schema changes that have to follow the migrations.
+func withMigrator(ctx context.Context, cfg config.Configuration, fn func(conn *sql.Conn, m *migrate.Migrate) error) error {
+	db, err := Open(cfg)
+	if err != nil {
+		return err
 	}
+	defer db.Close()
 
-	migrationsPath := cfg.MigrationLocation
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
 
-	_, err := os.Stat(migrationsPath)
+	err = lockMigrations(ctx, conn, cfg.MigrationLockTimeout)
 	if err != nil {
-		logger.Logger.Error(err)
 		return err
 	}
+	defer unlockMigrations(conn)
 
-	urlString := fmt.Sprintf(
-		"postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		cfg.DatabaseUser,
-		cfg.DatabasePassword,
-		cfg.DatabaseHost,
-		cfg.DatabasePort,
-		cfg.DatabaseName,
-	)
-
-	m, err := migrate.New(
-		"file://"+migrationsPath,
-		urlString)
+	src, err := iofs.New(migrations.FS, ".")
 	if err != nil {
-		logger.Logger.Error(err)
 		return err
 	}
+	defer src.Close()
 
-	dbVersion, err := strconv.Atoi(cfg.MigrateToVersion)
-	if err == nil {
-		logger.Logger.Info("Migrate: starting migration to version %v\n", dbVersion)
-		err = m.Migrate(uint(dbVersion))
-		if err != nil {
-			logger.Logger.Error("Migrate: failed migration to version %v\n", dbVersion)
-			logger.Logger.Error("Migration table will be forcing to version %v\n You should clean your data base from wrong tables and then start server mith 'MIGRATE=latest' enviroment variable!", dbVersion)
-			err = m.Force(dbVersion)
-		}
-	} else {
-		logger.Logger.Info("Migrate: starting migration to the latest version")
-		err = m.Up()
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
+	if err != nil {
+		return err
 	}
+	m, err := migrate.NewWithInstance("iofs", src, "postgres", driver)
 	if err != nil {
-		if errors.Is(err, migrate.ErrNoChange) {
-			logger.Logger.Info("Migrate: no changes found")
+		return err
+	}
+
+	// Stop after the running migration once ctx is cancelled.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			m.GracefulStop <- true
+		case <-done:
+		}
+	}()
+
+	return fn(conn, m)
+}
+
+func lockMigrations(ctx context.Context, conn *sql.Conn, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		var locked bool
+		err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, migrationLockKey).Scan(&locked)
+		if err != nil {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return ErrMigrationLockTimeout
+			}
+			return err
+		}
+		if locked {
 			return nil
 		}
-		logger.Logger.Info("file://" + migrationsPath)
+
+		logger.Logger.Info("Migrate: waiting for another instance to finish migrating")
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return ErrMigrationLockTimeout
+			}
+			return ctx.Err()
+		case <-time.After(migrationLockRetry):
+		}
+	}
+}
+
+func unlockMigrations(conn *sql.Conn) {
+	_, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+	if err != nil {
 		logger.Logger.Error(err)
+	}
+}
+
+// describeMigrationError adds the state a failed migration left behind.
+func describeMigrationError(m *migrate.Migrate, err error) error {
+	version, dirty, versionErr := m.Version()
+	if versionErr != nil || !dirty {
 		return err
 	}
-	logger.Logger.Info("Migrate: migrations are done successfully")
-	return nil
+	return fmt.Errorf("%w: schema is dirty at version %d, repair it by hand and mark the last good version with \"tasksctl migrate force <version>\"", err, version)
+}
+
+// MigrationStatus reads the schema version golang-migrate recorded in db.
+// A database that was never migrated reports version 0.
+func MigrationStatus(ctx context.Context, db *sql.DB) (MigrationState, error) {
+	var state MigrationState
+	sqlCommand := `SELECT version, dirty FROM schema_migrations LIMIT 1`
+	err := db.QueryRowContext(ctx, sqlCommand).Scan(&state.Version, &state.Dirty)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return MigrationState{}, err
+	}
+	return state, nil
+}
+
+// ExpectedMigrationVersion is the version the schema should be at: the one
+// pinned by MIGRATE, or the newest embedded migration.
+func ExpectedMigrationVersion(cfg config.Configuration) (uint, error) {
+	if version, err := strconv.ParseUint(cfg.MigrateToVersion, 10, 64); err == nil {
+		return uint(version), nil
+	}
+
+	driver, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return 0, err
+	}
+	defer driver.Close()
+
+	version, err := driver.First()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		next, err := driver.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
+
+// MigrationCheck reports an error while the schema is dirty or not at the
+// expected version, for use as a readiness check.
+func MigrationCheck(db *sql.DB, cfg config.Configuration) func(ctx context.Context) error {
+	expected, expectedErr := ExpectedMigrationVersion(cfg)
+	return func(ctx context.Context) error {
+		if expectedErr != nil {
+			return expectedErr
+		}
+		state, err := MigrationStatus(ctx, db)
+		if err != nil {
+			return err
+		}
+		if state.Dirty {
+			return fmt.Errorf("schema is dirty at version %d after a failed migration, expected %d", state.Version, expected)
+		}
+		if state.Version != expected {
+			return fmt.Errorf("schema is at version %d, expected %d", state.Version, expected)
+		}
+		return nil
+	}
 }
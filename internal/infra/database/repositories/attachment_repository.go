@@ -0,0 +1,312 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-rest-api/internal/domain"
+	"go-rest-api/internal/infra/logger"
+	"time"
+)
+
+type AttachmentRepository interface {
+	FindById(ctx context.Context, id uint64) (domain.Attachment, error)
+	// FindByKey finds the attachment stored under a blob key.
+	FindByKey(ctx context.Context, key string) (domain.Attachment, error)
+	// FindByProjectId returns the attachments of a project, oldest first.
+	FindByProjectId(ctx context.Context, projectId uint64) ([]domain.Attachment, error)
+	// Save adds the size of the attachment to the storage used by its
+	// uploader and project in the same transaction, failing with
+	// domain.ErrQuotaExceeded when either would go over quota and with
+	// domain.ErrProjectArchived when the project is archived.
+	Save(ctx context.Context, attachment domain.Attachment, quota domain.StorageQuota) (domain.Attachment, error)
+	// Delete gives the storage of the attachment back to its uploader and
+	// project, failing with domain.ErrProjectArchived when the project is
+	// archived.
+	Delete(ctx context.Context, id uint64) error
+	// FindPendingScan returns the attachments waiting to be scanned, oldest
+	// first.
+	FindPendingScan(ctx context.Context) ([]domain.Attachment, error)
+	SetScanStatus(ctx context.Context, id uint64, status domain.ScanStatus) error
+	// Usage returns the storage used by a user and by the projects they
+	// created, without limits.
+	Usage(ctx context.Context, userId uint64) (domain.StorageUsage, error)
+}
+
+const attachmentColumns = `id, project_id, uploader_id, filename, size, content_type, checksum, storage_key, scan_status, created_at`
+
+type attachment struct {
+	Id          uint64    `db:"id, omitempty"`
+	ProjectId   uint64    `db:"project_id"`
+	UploaderId  uint64    `db:"uploader_id"`
+	Filename    string    `db:"filename"`
+	Size        int64     `db:"size"`
+	ContentType string    `db:"content_type"`
+	Checksum    string    `db:"checksum"`
+	StorageKey  string    `db:"storage_key"`
+	ScanStatus  string    `db:"scan_status"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+type attachmentRepository struct {
+	db *sql.DB
+}
+
+func NewAttachmentRepository(db *sql.DB) AttachmentRepository {
+	return attachmentRepository{
+		db: db,
+	}
+}
+
+func (ar attachmentRepository) FindById(ctx context.Context, id uint64) (domain.Attachment, error) {
+	sqlCommand := `SELECT ` + attachmentColumns + ` FROM attachments WHERE id=$1`
+	attachmentModel, err := ar.scan(ar.db.QueryRowContext(ctx, sqlCommand, id))
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return domain.Attachment{}, err
+	}
+	return ar.modelToDomain(attachmentModel), nil
+}
+
+func (ar attachmentRepository) FindByKey(ctx context.Context, key string) (domain.Attachment, error) {
+	sqlCommand := `SELECT ` + attachmentColumns + ` FROM attachments WHERE storage_key=$1`
+	attachmentModel, err := ar.scan(ar.db.QueryRowContext(ctx, sqlCommand, key))
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return domain.Attachment{}, err
+	}
+	return ar.modelToDomain(attachmentModel), nil
+}
+
+func (ar attachmentRepository) FindByProjectId(ctx context.Context, projectId uint64) ([]domain.Attachment, error) {
+	sqlCommand := `SELECT ` + attachmentColumns + ` FROM attachments WHERE project_id=$1 ORDER BY id`
+	return ar.query(ctx, sqlCommand, projectId)
+}
+
+func (ar attachmentRepository) FindPendingScan(ctx context.Context) ([]domain.Attachment, error) {
+	sqlCommand := `SELECT ` + attachmentColumns + ` FROM attachments WHERE scan_status=$1 ORDER BY id`
+	return ar.query(ctx, sqlCommand, string(domain.ScanPending))
+}
+
+func (ar attachmentRepository) query(ctx context.Context, sqlCommand string, args ...any) ([]domain.Attachment, error) {
+	rows, err := ar.db.QueryContext(ctx, sqlCommand, args...)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []domain.Attachment{}
+	for rows.Next() {
+		attachmentModel, err := ar.scan(rows)
+		if err != nil {
+			logger.FromContext(ctx).Error(err)
+			return nil, err
+		}
+		attachments = append(attachments, ar.modelToDomain(attachmentModel))
+	}
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).Error(err)
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func (ar attachmentRepository) Save(ctx context.Context, attachment domain.Attachment, quota domain.StorageQuota) (domain.Attachment, error) {
+	tx, err := ar.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return domain.Attachment{}, err
+	}
+	defer tx.Rollback()
+
+	attachmentModel := ar.domainToModel(attachment)
+	sqlCommand := `INSERT INTO attachments (project_id, uploader_id, filename, size, content_type, checksum, storage_key, scan_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at`
+	err = tx.QueryRowContext(ctx, sqlCommand,
+		attachmentModel.ProjectId,
+		attachmentModel.UploaderId,
+		attachmentModel.Filename,
+		attachmentModel.Size,
+		attachmentModel.ContentType,
+		attachmentModel.Checksum,
+		attachmentModel.StorageKey,
+		attachmentModel.ScanStatus,
+	).Scan(&attachmentModel.Id, &attachmentModel.CreatedAt)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return domain.Attachment{}, err
+	}
+
+	// The updates lock the uploader and then the project row, so concurrent
+	// uploads are counted one after the other.
+	var used int64
+	sqlCommand = `UPDATE users SET storage_used = storage_used + $2 WHERE id = $1 RETURNING storage_used`
+	err = tx.QueryRowContext(ctx, sqlCommand, attachmentModel.UploaderId, attachmentModel.Size).Scan(&used)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return domain.Attachment{}, err
+	}
+	if quota.User > 0 && used > quota.User {
+		return domain.Attachment{}, fmt.Errorf("%w: the uploader would use %d of %d bytes", domain.ErrQuotaExceeded, used, quota.User)
+	}
+	// Archiving updates the project row too, so this sees an archive that
+	// raced with the upload.
+	var archived bool
+	sqlCommand = `UPDATE projects SET storage_used = storage_used + $2 WHERE id = $1 RETURNING storage_used, archived_at IS NOT NULL`
+	err = tx.QueryRowContext(ctx, sqlCommand, attachmentModel.ProjectId, attachmentModel.Size).Scan(&used, &archived)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return domain.Attachment{}, err
+	}
+	if archived {
+		return domain.Attachment{}, domain.ErrProjectArchived
+	}
+	if quota.Project > 0 && used > quota.Project {
+		return domain.Attachment{}, fmt.Errorf("%w: the project would use %d of %d bytes", domain.ErrQuotaExceeded, used, quota.Project)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return domain.Attachment{}, err
+	}
+	return ar.modelToDomain(attachmentModel), nil
+}
+
+func (ar attachmentRepository) Delete(ctx context.Context, id uint64) error {
+	tx, err := ar.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	var (
+		projectId, uploaderId uint64
+		size                  int64
+	)
+	sqlCommand := `DELETE FROM attachments WHERE id=$1 RETURNING project_id, uploader_id, size`
+	err = tx.QueryRowContext(ctx, sqlCommand, id).Scan(&projectId, &uploaderId, &size)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return err
+	}
+
+	sqlCommand = `UPDATE users SET storage_used = storage_used - $2 WHERE id = $1`
+	_, err = tx.ExecContext(ctx, sqlCommand, uploaderId, size)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return err
+	}
+	var archived bool
+	sqlCommand = `UPDATE projects SET storage_used = storage_used - $2 WHERE id = $1 RETURNING archived_at IS NOT NULL`
+	err = tx.QueryRowContext(ctx, sqlCommand, projectId, size).Scan(&archived)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return err
+	}
+	if archived {
+		return domain.ErrProjectArchived
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return err
+	}
+	return nil
+}
+
+func (ar attachmentRepository) SetScanStatus(ctx context.Context, id uint64, status domain.ScanStatus) error {
+	sqlCommand := `UPDATE attachments SET scan_status=$1 WHERE id=$2`
+	_, err := ar.db.ExecContext(ctx, sqlCommand, string(status), id)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return err
+	}
+	return nil
+}
+
+func (ar attachmentRepository) Usage(ctx context.Context, userId uint64) (domain.StorageUsage, error) {
+	usage := domain.StorageUsage{Projects: []domain.ProjectStorageUsage{}}
+	sqlCommand := `SELECT storage_used FROM users WHERE id=$1`
+	err := ar.db.QueryRowContext(ctx, sqlCommand, userId).Scan(&usage.Used)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return domain.StorageUsage{}, err
+	}
+
+	sqlCommand = `SELECT id, title, storage_used FROM projects WHERE creator_id=$1 ORDER BY id`
+	rows, err := ar.db.QueryContext(ctx, sqlCommand, userId)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return domain.StorageUsage{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var project domain.ProjectStorageUsage
+		if err = rows.Scan(&project.ProjectId, &project.Title, &project.Used); err != nil {
+			logger.FromContext(ctx).Error(err)
+			return domain.StorageUsage{}, err
+		}
+		usage.Projects = append(usage.Projects, project)
+	}
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).Error(err)
+		return domain.StorageUsage{}, err
+	}
+	return usage, nil
+}
+
+func (ar attachmentRepository) scan(row interface{ Scan(...any) error }) (attachment, error) {
+	attachmentModel := attachment{}
+	err := row.Scan(
+		&attachmentModel.Id,
+		&attachmentModel.ProjectId,
+		&attachmentModel.UploaderId,
+		&attachmentModel.Filename,
+		&attachmentModel.Size,
+		&attachmentModel.ContentType,
+		&attachmentModel.Checksum,
+		&attachmentModel.StorageKey,
+		&attachmentModel.ScanStatus,
+		&attachmentModel.CreatedAt,
+	)
+	return attachmentModel, err
+}
+
+func (ar attachmentRepository) modelToDomain(a attachment) domain.Attachment {
+	return domain.Attachment{
+		Id:          a.Id,
+		ProjectId:   a.ProjectId,
+		UploaderId:  a.UploaderId,
+		Filename:    a.Filename,
+		Size:        a.Size,
+		ContentType: a.ContentType,
+		Checksum:    a.Checksum,
+		Key:         a.StorageKey,
+		ScanStatus:  domain.ScanStatus(a.ScanStatus),
+		CreatedAt:   a.CreatedAt,
+	}
+}
+
+func (ar attachmentRepository) domainToModel(a domain.Attachment) attachment {
+	return attachment{
+		Id:          a.Id,
+		ProjectId:   a.ProjectId,
+		UploaderId:  a.UploaderId,
+		Filename:    a.Filename,
+		Size:        a.Size,
+		ContentType: a.ContentType,
+		Checksum:    a.Checksum,
+		StorageKey:  a.Key,
+		ScanStatus:  string(a.ScanStatus),
+		CreatedAt:   a.CreatedAt,
+	}
+}
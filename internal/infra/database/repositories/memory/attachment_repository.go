@@ -0,0 +1,163 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-rest-api/internal/domain"
+	"go-rest-api/internal/infra/database/repositories"
+	"sort"
+)
+
+var (
+	errUnknownProject  = errors.New("insert or update on table \"attachments\" violates foreign key constraint \"fk_project\"")
+	errUnknownUploader = errors.New("insert or update on table \"attachments\" violates foreign key constraint \"fk_uploader\"")
+)
+
+type attachmentRepository struct {
+	db *DB
+}
+
+func NewAttachmentRepository(db *DB) repositories.AttachmentRepository {
+	return attachmentRepository{
+		db: db,
+	}
+}
+
+func (ar attachmentRepository) FindById(_ context.Context, id uint64) (domain.Attachment, error) {
+	ar.db.mu.RLock()
+	defer ar.db.mu.RUnlock()
+
+	attachment, ok := ar.db.attachments.get(id)
+	if !ok {
+		return domain.Attachment{}, sql.ErrNoRows
+	}
+	return attachment, nil
+}
+
+func (ar attachmentRepository) FindByKey(_ context.Context, key string) (domain.Attachment, error) {
+	ar.db.mu.RLock()
+	defer ar.db.mu.RUnlock()
+
+	attachments := ar.db.attachments.filter(func(a domain.Attachment) bool {
+		return a.Key == key
+	})
+	if len(attachments) == 0 {
+		return domain.Attachment{}, sql.ErrNoRows
+	}
+	return attachments[0], nil
+}
+
+func (ar attachmentRepository) FindByProjectId(_ context.Context, projectId uint64) ([]domain.Attachment, error) {
+	ar.db.mu.RLock()
+	attachments := ar.db.attachments.filter(func(a domain.Attachment) bool {
+		return a.ProjectId == projectId
+	})
+	ar.db.mu.RUnlock()
+
+	sort.Slice(attachments, func(i, j int) bool {
+		return attachments[i].Id < attachments[j].Id
+	})
+	if attachments == nil {
+		attachments = []domain.Attachment{}
+	}
+	return attachments, nil
+}
+
+func (ar attachmentRepository) FindPendingScan(_ context.Context) ([]domain.Attachment, error) {
+	ar.db.mu.RLock()
+	attachments := ar.db.attachments.filter(func(a domain.Attachment) bool {
+		return a.ScanStatus == domain.ScanPending
+	})
+	ar.db.mu.RUnlock()
+
+	sort.Slice(attachments, func(i, j int) bool {
+		return attachments[i].Id < attachments[j].Id
+	})
+	if attachments == nil {
+		attachments = []domain.Attachment{}
+	}
+	return attachments, nil
+}
+
+func (ar attachmentRepository) SetScanStatus(_ context.Context, id uint64, status domain.ScanStatus) error {
+	ar.db.mu.Lock()
+	defer ar.db.mu.Unlock()
+
+	if attachment, ok := ar.db.attachments.get(id); ok {
+		attachment.ScanStatus = status
+		ar.db.attachments.put(id, attachment)
+	}
+	return nil
+}
+
+func (ar attachmentRepository) Save(_ context.Context, attachment domain.Attachment, quota domain.StorageQuota) (domain.Attachment, error) {
+	ar.db.mu.Lock()
+	defer ar.db.mu.Unlock()
+
+	project, ok := ar.db.projects.get(attachment.ProjectId)
+	if !ok {
+		return domain.Attachment{}, errUnknownProject
+	}
+	if _, ok := ar.db.users.get(attachment.UploaderId); !ok {
+		return domain.Attachment{}, errUnknownUploader
+	}
+	used := ar.db.storageUsed(func(a domain.Attachment) bool { return a.UploaderId == attachment.UploaderId }) + attachment.Size
+	if quota.User > 0 && used > quota.User {
+		return domain.Attachment{}, fmt.Errorf("%w: the uploader would use %d of %d bytes", domain.ErrQuotaExceeded, used, quota.User)
+	}
+	used = ar.db.storageUsed(func(a domain.Attachment) bool { return a.ProjectId == attachment.ProjectId }) + attachment.Size
+	if quota.Project > 0 && used > quota.Project {
+		return domain.Attachment{}, fmt.Errorf("%w: the project would use %d of %d bytes", domain.ErrQuotaExceeded, used, quota.Project)
+	}
+	if project.ArchivedAt != nil {
+		return domain.Attachment{}, domain.ErrProjectArchived
+	}
+	attachment.Id = ar.db.attachments.nextId()
+	attachment.CreatedAt = ar.db.now()
+	ar.db.attachments.put(attachment.Id, attachment)
+	return attachment, nil
+}
+
+func (ar attachmentRepository) Delete(_ context.Context, id uint64) error {
+	ar.db.mu.Lock()
+	defer ar.db.mu.Unlock()
+
+	attachment, ok := ar.db.attachments.get(id)
+	if !ok {
+		return nil
+	}
+	if project, ok := ar.db.projects.get(attachment.ProjectId); ok && project.ArchivedAt != nil {
+		return domain.ErrProjectArchived
+	}
+	ar.db.attachments.delete(id)
+	return nil
+}
+
+func (ar attachmentRepository) Usage(_ context.Context, userId uint64) (domain.StorageUsage, error) {
+	ar.db.mu.RLock()
+	defer ar.db.mu.RUnlock()
+
+	if _, ok := ar.db.users.get(userId); !ok {
+		return domain.StorageUsage{}, sql.ErrNoRows
+	}
+	usage := domain.StorageUsage{
+		Used:     ar.db.storageUsed(func(a domain.Attachment) bool { return a.UploaderId == userId }),
+		Projects: []domain.ProjectStorageUsage{},
+	}
+	projects := ar.db.projects.filter(func(p domain.Project) bool {
+		return p.CreatorId == userId
+	})
+	sort.Slice(projects, func(i, j int) bool {
+		return projects[i].Id < projects[j].Id
+	})
+	for _, project := range projects {
+		usage.Projects = append(usage.Projects, domain.ProjectStorageUsage{
+			ProjectId: project.Id,
+			Title:     project.Title,
+			Used:      ar.db.storageUsed(func(a domain.Attachment) bool { return a.ProjectId == project.Id }),
+		})
+	}
+	return usage, nil
+}
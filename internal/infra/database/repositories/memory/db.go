@@ -0,0 +1,125 @@
+// Package memory implements the repositories in process memory with the
+// semantics of their Postgres counterparts, for tests that should not need
+// a database. Search only approximates Postgres full text search, see
+// NewSearchRepository.
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"go-rest-api/internal/domain"
+)
+
+// DB is the in-memory counterpart of the Postgres database: repositories
+// created from the same DB see each other's rows, so deleting a user
+// cascades to their projects and attachments as the foreign keys do.
+type DB struct {
+	mu          sync.RWMutex
+	users       table[domain.User]
+	projects    table[domain.Project]
+	attachments table[domain.Attachment]
+	sessions    map[domain.Session]struct{}
+
+	// Now stamps created rows, like now() in Postgres.
+	Now     func() time.Time
+	lastNow time.Time
+}
+
+func NewDB() *DB {
+	return &DB{
+		users:       newTable[domain.User](),
+		projects:    newTable[domain.Project](),
+		attachments: newTable[domain.Attachment](),
+		sessions:    make(map[domain.Session]struct{}),
+		Now:         time.Now,
+	}
+}
+
+// now returns the current time at the microsecond precision of timestamptz.
+// Like separate Postgres transactions, successive calls never return the
+// same time, even with a fixed clock. Callers hold the write lock.
+func (db *DB) now() time.Time {
+	now := db.Now().Truncate(time.Microsecond)
+	if !now.After(db.lastNow) && !db.lastNow.IsZero() {
+		now = db.lastNow.Add(time.Microsecond)
+	}
+	db.lastNow = now
+	return now
+}
+
+// table holds rows by id and hands out ids like a bigserial column.
+type table[T any] struct {
+	rows   map[uint64]T
+	lastId uint64
+}
+
+func newTable[T any]() table[T] {
+	return table[T]{rows: make(map[uint64]T)}
+}
+
+func (t *table[T]) nextId() uint64 {
+	t.lastId++
+	return t.lastId
+}
+
+func (t *table[T]) get(id uint64) (T, bool) {
+	row, ok := t.rows[id]
+	return row, ok
+}
+
+func (t *table[T]) put(id uint64, row T) {
+	t.rows[id] = row
+}
+
+func (t *table[T]) delete(id uint64) {
+	delete(t.rows, id)
+}
+
+// find returns the row with the lowest id matching match.
+func (t *table[T]) find(match func(T) bool) (T, bool) {
+	var (
+		found   T
+		foundId uint64
+		ok      bool
+	)
+	for id, row := range t.rows {
+		if match(row) && (!ok || id < foundId) {
+			found, foundId, ok = row, id, true
+		}
+	}
+	return found, ok
+}
+
+func (t *table[T]) filter(match func(T) bool) []T {
+	var rows []T
+	for _, row := range t.rows {
+		if match(row) {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// deleteProject deletes a project and, like the foreign key, its
+// attachments. Callers hold the write lock.
+func (db *DB) deleteProject(id uint64) {
+	db.projects.delete(id)
+	for attachmentId, attachment := range db.attachments.rows {
+		if attachment.ProjectId == id {
+			db.attachments.delete(attachmentId)
+		}
+	}
+}
+
+// storageUsed sums the size of the matching attachments, which Postgres
+// keeps as counters next to users and projects. Callers hold a lock.
+func (db *DB) storageUsed(match func(domain.Attachment) bool) int64 {
+	var used int64
+	for _, attachment := range db.attachments.rows {
+		if match(attachment) {
+			used += attachment.Size
+		}
+	}
+	return used
+}
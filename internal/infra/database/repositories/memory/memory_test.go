@@ -0,0 +1,19 @@
+package memory
+
+import (
+	"go-rest-api/internal/infra/database/repositories/repotest"
+	"testing"
+)
+
+func TestRepositoriesContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repositories {
+		db := NewDB()
+		return repotest.Repositories{
+			Users:       NewUserRepository(db),
+			Sessions:    NewSessionRepository(db),
+			Projects:    NewProjectRepository(db),
+			Attachments: NewAttachmentRepository(db),
+			Search:      NewSearchRepository(db),
+		}
+	})
+}
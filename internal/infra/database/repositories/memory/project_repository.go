@@ -0,0 +1,248 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-rest-api/internal/domain"
+	"go-rest-api/internal/infra/database/repositories"
+	"sort"
+	"strings"
+	"time"
+)
+
+var errUnknownCreator = errors.New("insert or update on table \"projects\" violates foreign key constraint \"fk_creator\"")
+
+type projectRepository struct {
+	db *DB
+}
+
+func NewProjectRepository(db *DB) repositories.ProjectRepository {
+	return projectRepository{
+		db: db,
+	}
+}
+
+func (pr projectRepository) FindById(_ context.Context, id uint64) (domain.Project, error) {
+	pr.db.mu.RLock()
+	defer pr.db.mu.RUnlock()
+
+	project, ok := pr.db.projects.get(id)
+	if !ok {
+		return domain.Project{}, sql.ErrNoRows
+	}
+	return project, nil
+}
+
+// FindByCreatorId pages through projects like the Postgres repository.
+// Titles are compared byte by byte, which matches the C collation.
+func (pr projectRepository) FindByCreatorId(_ context.Context, creatorId uint64, query domain.ProjectQuery) (domain.Projects, error) {
+	compare, ok := projectComparators[query.Sort.Field]
+	if !ok {
+		return domain.Projects{}, fmt.Errorf("unknown sort field %q", query.Sort.Field)
+	}
+	if query.Limit < 1 || query.Limit > domain.MaxProjectsLimit {
+		query.Limit = domain.DefaultProjectsLimit
+	}
+
+	var after *domain.Project
+	if query.Cursor != nil {
+		cursorProject, err := cursorProject(query.Sort.Field, *query.Cursor)
+		if err != nil {
+			return domain.Projects{}, err
+		}
+		after = &cursorProject
+	}
+
+	pr.db.mu.RLock()
+	projects := pr.db.projects.filter(func(p domain.Project) bool {
+		return p.CreatorId == creatorId && matchesFilters(p, query)
+	})
+	pr.db.mu.RUnlock()
+
+	// compare orders by the sort field, then by id.
+	less := func(a, b domain.Project) bool {
+		if query.Sort.Desc {
+			return compare(b, a)
+		}
+		return compare(a, b)
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		return less(projects[i], projects[j])
+	})
+
+	var total *uint64
+	if query.WithTotal {
+		count := uint64(len(projects))
+		total = &count
+	}
+
+	if after != nil {
+		start := sort.Search(len(projects), func(i int) bool {
+			return less(*after, projects[i])
+		})
+		projects = projects[start:]
+	}
+
+	page := []domain.Project{}
+	var nextCursor *domain.Cursor
+	for _, p := range projects {
+		if len(page) == int(query.Limit) {
+			last := page[len(page)-1]
+			nextCursor = &domain.Cursor{
+				Sort:  query.Sort.String(),
+				Value: projectSortValue(query.Sort.Field, last),
+				Id:    last.Id,
+			}
+			break
+		}
+		page = append(page, p)
+	}
+
+	return domain.Projects{
+		Projects:   page,
+		NextCursor: nextCursor,
+		Total:      total,
+	}, nil
+}
+
+var projectComparators = map[string]func(a, b domain.Project) bool{
+	"id": func(a, b domain.Project) bool {
+		return a.Id < b.Id
+	},
+	"title": func(a, b domain.Project) bool {
+		if a.Title != b.Title {
+			return a.Title < b.Title
+		}
+		return a.Id < b.Id
+	},
+	"created_at": func(a, b domain.Project) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.Id < b.Id
+	},
+}
+
+func matchesFilters(p domain.Project, query domain.ProjectQuery) bool {
+	if query.TitleContains != "" && !strings.Contains(strings.ToLower(p.Title), strings.ToLower(query.TitleContains)) {
+		return false
+	}
+	if query.CreatedFrom != nil && p.CreatedAt.Before(*query.CreatedFrom) {
+		return false
+	}
+	if query.CreatedTo != nil && p.CreatedAt.After(*query.CreatedTo) {
+		return false
+	}
+	return matchesArchived(p, query.Archived)
+}
+
+func matchesArchived(p domain.Project, filter domain.ArchivedFilter) bool {
+	switch filter {
+	case domain.ArchivedInclude:
+		return true
+	case domain.ArchivedOnly:
+		return p.IsArchived()
+	default:
+		return !p.IsArchived()
+	}
+}
+
+func projectSortValue(field string, p domain.Project) string {
+	switch field {
+	case "title":
+		return p.Title
+	case "created_at":
+		return p.CreatedAt.UTC().Format(time.RFC3339Nano)
+	default:
+		return ""
+	}
+}
+
+// cursorProject is a project holding the position the cursor points at.
+func cursorProject(field string, cursor domain.Cursor) (domain.Project, error) {
+	p := domain.Project{Id: cursor.Id}
+	switch field {
+	case "title":
+		p.Title = cursor.Value
+	case "created_at":
+		createdAt, err := time.Parse(time.RFC3339Nano, cursor.Value)
+		if err != nil {
+			return domain.Project{}, domain.ErrInvalidCursor
+		}
+		p.CreatedAt = createdAt
+	}
+	return p, nil
+}
+
+func (pr projectRepository) Save(_ context.Context, project domain.Project) (domain.Project, error) {
+	pr.db.mu.Lock()
+	defer pr.db.mu.Unlock()
+
+	if _, ok := pr.db.users.get(project.CreatorId); !ok {
+		return domain.Project{}, errUnknownCreator
+	}
+	project.Id = pr.db.projects.nextId()
+	project.CreatedAt = pr.db.now()
+	project.ArchivedAt = nil
+	pr.db.projects.put(project.Id, project)
+	return project, nil
+}
+
+func (pr projectRepository) Update(_ context.Context, project domain.Project) (domain.Project, error) {
+	pr.db.mu.Lock()
+	defer pr.db.mu.Unlock()
+
+	stored, ok := pr.db.projects.get(project.Id)
+	if !ok {
+		return domain.Project{}, sql.ErrNoRows
+	}
+	if stored.IsArchived() {
+		return domain.Project{}, domain.ErrProjectArchived
+	}
+	stored.Title = project.Title
+	stored.Description = project.Description
+	pr.db.projects.put(stored.Id, stored)
+	return stored, nil
+}
+
+func (pr projectRepository) Delete(_ context.Context, id uint64) error {
+	pr.db.mu.Lock()
+	defer pr.db.mu.Unlock()
+
+	if stored, ok := pr.db.projects.get(id); ok && stored.ArchivedAt != nil {
+		return domain.ErrProjectArchived
+	}
+	pr.db.deleteProject(id)
+	return nil
+}
+
+func (pr projectRepository) Archive(_ context.Context, id uint64) (domain.Project, error) {
+	pr.db.mu.Lock()
+	defer pr.db.mu.Unlock()
+
+	stored, ok := pr.db.projects.get(id)
+	if !ok {
+		return domain.Project{}, sql.ErrNoRows
+	}
+	if stored.ArchivedAt == nil {
+		now := pr.db.now()
+		stored.ArchivedAt = &now
+		pr.db.projects.put(stored.Id, stored)
+	}
+	return stored, nil
+}
+
+func (pr projectRepository) Unarchive(_ context.Context, id uint64) (domain.Project, error) {
+	pr.db.mu.Lock()
+	defer pr.db.mu.Unlock()
+
+	stored, ok := pr.db.projects.get(id)
+	if !ok {
+		return domain.Project{}, sql.ErrNoRows
+	}
+	stored.ArchivedAt = nil
+	pr.db.projects.put(stored.Id, stored)
+	return stored, nil
+}
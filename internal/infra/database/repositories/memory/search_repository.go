@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"context"
+	"go-rest-api/internal/domain"
+	"go-rest-api/internal/infra/database/repositories"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type searchRepository struct {
+	db *DB
+}
+
+// NewSearchRepository approximates the Postgres full text search: every
+// term must be the prefix of a word of the title or description, without
+// stemming or stop words. The text is HTML escaped, matching words are
+// wrapped in <mark> and the rank grows with the number of matching words,
+// titles counting double.
+func NewSearchRepository(db *DB) repositories.SearchRepository {
+	return searchRepository{
+		db: db,
+	}
+}
+
+func (sr searchRepository) SearchProjects(_ context.Context, userId uint64, query domain.SearchQuery) (domain.SearchResults, error) {
+	sr.db.mu.RLock()
+	projects := sr.db.projects.filter(func(p domain.Project) bool {
+		return p.CreatorId == userId && matchesArchived(p, query.Archived)
+	})
+	sr.db.mu.RUnlock()
+
+	results := []domain.SearchResult{}
+	for _, p := range projects {
+		titleSnippet, titleMatches, titleTerms := highlight(p.Title, query.Terms)
+		descriptionSnippet, descriptionMatches, descriptionTerms := highlight(p.Description, query.Terms)
+		if !allTermsMatched(query.Terms, titleTerms, descriptionTerms) {
+			continue
+		}
+		results = append(results, domain.SearchResult{
+			Type:               "project",
+			Id:                 p.Id,
+			Title:              p.Title,
+			TitleSnippet:       titleSnippet,
+			DescriptionSnippet: descriptionSnippet,
+			Rank:               float32(2*titleMatches+descriptionMatches) / 10,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].Id > results[j].Id
+	})
+
+	total := uint64(len(results))
+	offset := min(int(query.Offset), len(results))
+	end := min(offset+int(query.Limit), len(results))
+
+	return domain.SearchResults{
+		Results: results[offset:end],
+		Total:   total,
+	}, nil
+}
+
+// highlight escapes text as HTML, marks the words starting with one of
+// terms and reports how many words matched and which terms did.
+func highlight(text string, terms []string) (string, int, map[string]bool) {
+	var (
+		b       strings.Builder
+		matches int
+		matched = map[string]bool{}
+	)
+	isSeparator := func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}
+
+	rest := text
+	for rest != "" {
+		start := strings.IndexFunc(rest, func(r rune) bool { return !isSeparator(r) })
+		if start < 0 {
+			b.WriteString(html.EscapeString(rest))
+			break
+		}
+		b.WriteString(html.EscapeString(rest[:start]))
+		rest = rest[start:]
+
+		end := strings.IndexFunc(rest, isSeparator)
+		if end < 0 {
+			end = len(rest)
+		}
+		word := rest[:end]
+		rest = rest[end:]
+
+		wordMatched := false
+		for _, term := range terms {
+			if strings.HasPrefix(strings.ToLower(word), term) {
+				matched[term] = true
+				wordMatched = true
+			}
+		}
+		if wordMatched {
+			matches++
+			b.WriteString("<mark>" + word + "</mark>")
+		} else {
+			b.WriteString(word)
+		}
+	}
+	return b.String(), matches, matched
+}
+
+func allTermsMatched(terms []string, matchedSets ...map[string]bool) bool {
+	for _, term := range terms {
+		found := false
+		for _, matched := range matchedSets {
+			found = found || matched[term]
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
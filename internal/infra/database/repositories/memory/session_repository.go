@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"go-rest-api/internal/domain"
+	"go-rest-api/internal/infra/database/repositories"
+)
+
+type sessionRepository struct {
+	db *DB
+}
+
+func NewSessionRepository(db *DB) repositories.SessionRepository {
+	return sessionRepository{
+		db: db,
+	}
+}
+
+func (sr sessionRepository) Save(_ context.Context, sess domain.Session) error {
+	sr.db.mu.Lock()
+	defer sr.db.mu.Unlock()
+
+	if _, ok := sr.db.sessions[sess]; ok {
+		return errors.New("duplicate session")
+	}
+	sr.db.sessions[sess] = struct{}{}
+	return nil
+}
+
+func (sr sessionRepository) Exists(_ context.Context, sess domain.Session) error {
+	sr.db.mu.RLock()
+	defer sr.db.mu.RUnlock()
+
+	if _, ok := sr.db.sessions[sess]; !ok {
+		return errors.New("session does not exist")
+	}
+	return nil
+}
+
+func (sr sessionRepository) Delete(_ context.Context, sess domain.Session) error {
+	sr.db.mu.Lock()
+	defer sr.db.mu.Unlock()
+
+	delete(sr.db.sessions, sess)
+	return nil
+}
+
+func (sr sessionRepository) DeleteByUserId(_ context.Context, userId uint64) (int64, error) {
+	sr.db.mu.Lock()
+	defer sr.db.mu.Unlock()
+
+	var deleted int64
+	for sess := range sr.db.sessions {
+		if sess.UserId == userId {
+			delete(sr.db.sessions, sess)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,165 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"go-rest-api/internal/domain"
+	"go-rest-api/internal/infra/database/repositories"
+	"maps"
+	"slices"
+)
+
+type userRepository struct {
+	db *DB
+}
+
+func NewUserRepository(db *DB) repositories.UserRepository {
+	return userRepository{
+		db: db,
+	}
+}
+
+func (ur userRepository) FindById(_ context.Context, id uint64) (domain.User, error) {
+	ur.db.mu.RLock()
+	defer ur.db.mu.RUnlock()
+
+	user, ok := ur.db.users.get(id)
+	if !ok {
+		return domain.User{}, sql.ErrNoRows
+	}
+	return copyUser(user), nil
+}
+
+func (ur userRepository) FindByEmail(_ context.Context, email string) (domain.User, error) {
+	ur.db.mu.RLock()
+	defer ur.db.mu.RUnlock()
+
+	user, ok := ur.db.users.find(func(u domain.User) bool {
+		return u.Email == email
+	})
+	if !ok {
+		return domain.User{}, sql.ErrNoRows
+	}
+	return copyUser(user), nil
+}
+
+func (ur userRepository) FindByEmailConfirmationToken(_ context.Context, confToken string) (domain.User, error) {
+	ur.db.mu.RLock()
+	defer ur.db.mu.RUnlock()
+
+	user, ok := ur.db.users.find(func(u domain.User) bool {
+		return u.EmailConfirmationToken == confToken && !u.EmailConfirmed
+	})
+	if !ok {
+		return domain.User{}, sql.ErrNoRows
+	}
+	return copyUser(user), nil
+}
+
+// Save stores the columns the Postgres INSERT sets and, like it, returns
+// the given user with only the id filled in.
+func (ur userRepository) Save(_ context.Context, user domain.User) (domain.User, error) {
+	ur.db.mu.Lock()
+	defer ur.db.mu.Unlock()
+
+	user.Id = ur.db.users.nextId()
+	ur.db.users.put(user.Id, domain.User{
+		Id:                     user.Id,
+		Name:                   user.Name,
+		Email:                  user.Email,
+		Password:               user.Password,
+		EmailConfirmationToken: user.EmailConfirmationToken,
+	})
+	return user, nil
+}
+
+func (ur userRepository) UpdateUserAvatar(_ context.Context, user domain.User) (domain.User, error) {
+	ur.db.mu.Lock()
+	defer ur.db.mu.Unlock()
+
+	if stored, ok := ur.db.users.get(user.Id); ok {
+		stored.Avatar = copyString(user.Avatar)
+		stored.AvatarSizes = maps.Clone(user.AvatarSizes)
+		stored.AvatarKeys = slices.Clone(user.AvatarKeys)
+		ur.db.users.put(user.Id, stored)
+	}
+	return user, nil
+}
+
+func (ur userRepository) ConfirmUserEmail(_ context.Context, user domain.User) error {
+	ur.db.mu.Lock()
+	defer ur.db.mu.Unlock()
+
+	if stored, ok := ur.db.users.get(user.Id); ok && stored.EmailConfirmationToken == user.EmailConfirmationToken {
+		stored.EmailConfirmed = true
+		ur.db.users.put(user.Id, stored)
+	}
+	return nil
+}
+
+func (ur userRepository) UpdatePassword(_ context.Context, id uint64, passwordHash string) error {
+	ur.db.mu.Lock()
+	defer ur.db.mu.Unlock()
+
+	stored, ok := ur.db.users.get(id)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	stored.Password = passwordHash
+	ur.db.users.put(id, stored)
+	return nil
+}
+
+func (ur userRepository) Disable(_ context.Context, id uint64) (domain.User, error) {
+	ur.db.mu.Lock()
+	defer ur.db.mu.Unlock()
+
+	stored, ok := ur.db.users.get(id)
+	if !ok {
+		return domain.User{}, sql.ErrNoRows
+	}
+	if stored.DisabledAt == nil {
+		now := ur.db.now()
+		stored.DisabledAt = &now
+		ur.db.users.put(id, stored)
+	}
+	return copyUser(stored), nil
+}
+
+func (ur userRepository) Delete(_ context.Context, id uint64) error {
+	ur.db.mu.Lock()
+	defer ur.db.mu.Unlock()
+
+	ur.db.users.delete(id)
+	for projectId, project := range ur.db.projects.rows {
+		if project.CreatorId == id {
+			ur.db.deleteProject(projectId)
+		}
+	}
+	for attachmentId, attachment := range ur.db.attachments.rows {
+		if attachment.UploaderId == id {
+			ur.db.attachments.delete(attachmentId)
+		}
+	}
+	return nil
+}
+
+// copyUser keeps callers from modifying stored rows through pointers.
+func copyUser(u domain.User) domain.User {
+	u.Avatar = copyString(u.Avatar)
+	u.AvatarSizes = maps.Clone(u.AvatarSizes)
+	u.AvatarKeys = slices.Clone(u.AvatarKeys)
+	if u.DisabledAt != nil {
+		disabledAt := *u.DisabledAt
+		u.DisabledAt = &disabledAt
+	}
+	return u
+}
+
+func copyString(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	return &c
+}
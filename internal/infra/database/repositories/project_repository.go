@@ -1,24 +1,41 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"go-rest-api/internal/domain"
 	"go-rest-api/internal/infra/logger"
+	"strings"
+	"time"
 )
 
 type ProjectRepository interface {
-	FindById(id uint64) (domain.Project, error)
-	FindByCreatorId(creatorId uint64, page, limit int32) (domain.Projects, error)
-	Save(project domain.Project) (domain.Project, error)
-	Update(project domain.Project) (domain.Project, error)
-	Delete(id uint64) error
+	FindById(ctx context.Context, id uint64) (domain.Project, error)
+	FindByCreatorId(ctx context.Context, creatorId uint64, query domain.ProjectQuery) (domain.Projects, error)
+	Save(ctx context.Context, project domain.Project) (domain.Project, error)
+	Update(ctx context.Context, project domain.Project) (domain.Project, error)
+	Delete(ctx context.Context, id uint64) error
+	Archive(ctx context.Context, id uint64) (domain.Project, error)
+	Unarchive(ctx context.Context, id uint64) (domain.Project, error)
+}
+
+const projectColumns = `id, title, description, creator_id, created_at, archived_at`
+
+var projectSortColumns = map[string]string{
+	"id":         "id",
+	"title":      "title",
+	"created_at": "created_at",
 }
 
 type project struct {
-	Id          uint64 `db:"id, omitempty"`
-	Title       string `db:"title"`
-	Description string `db:"description"`
-	CreatorId   uint64 `db:"creator_id"`
+	Id          uint64     `db:"id, omitempty"`
+	Title       string     `db:"title"`
+	Description string     `db:"description"`
+	CreatorId   uint64     `db:"creator_id"`
+	CreatedAt   time.Time  `db:"created_at"`
+	ArchivedAt  *time.Time `db:"archived_at"`
 }
 
 type projectRepository struct {
@@ -31,38 +48,85 @@ func NewProjectRepository(db *sql.DB) ProjectRepository {
 	}
 }
 
-func (pr projectRepository) FindById(id uint64) (domain.Project, error) {
-	sqlCommand := `SELECT * FROM projects WHERE id=$1`
+func (pr projectRepository) FindById(ctx context.Context, id uint64) (domain.Project, error) {
+	sqlCommand := `SELECT ` + projectColumns + ` FROM projects WHERE id=$1`
 	projectModel := project{}
-	err := pr.db.QueryRow(sqlCommand, id).Scan(
+	err := pr.db.QueryRowContext(ctx, sqlCommand, id).Scan(
 		&projectModel.Id,
 		&projectModel.Title,
 		&projectModel.Description,
 		&projectModel.CreatorId,
+		&projectModel.CreatedAt,
+		&projectModel.ArchivedAt,
 	)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return domain.Project{}, err
 	}
 
 	return pr.modelToDomain(projectModel), nil
 }
 
-func (pr projectRepository) FindByCreatorId(creatorId uint64, page, limit int32) (domain.Projects, error) {
-	if page < 1 {
-		page = 1
+func (pr projectRepository) FindByCreatorId(ctx context.Context, creatorId uint64, query domain.ProjectQuery) (domain.Projects, error) {
+	column, ok := projectSortColumns[query.Sort.Field]
+	if !ok {
+		err := fmt.Errorf("unknown sort field %q", query.Sort.Field)
+		logger.FromContext(ctx).Error(err)
+		return domain.Projects{}, err
 	}
-	if limit < 1 {
-		limit = 10
+	if query.Limit < 1 || query.Limit > domain.MaxProjectsLimit {
+		query.Limit = domain.DefaultProjectsLimit
 	}
 
-	offset := (page - 1) * limit
+	where, args := pr.filterConditions(creatorId, query)
+
+	var total *uint64
+	if query.WithTotal {
+		var count uint64
+		totalSqlCommand := `SELECT COUNT(*) FROM projects WHERE ` + strings.Join(where, " AND ")
+		err := pr.db.QueryRowContext(ctx, totalSqlCommand, args...).Scan(&count)
+		if err != nil {
+			logger.FromContext(ctx).Error(err)
+			return domain.Projects{}, err
+		}
+		total = &count
+	}
 
-	sqlCommand := `SELECT * FROM projects WHERE creator_id=$1 LIMIT $2 OFFSET $3`
+	direction, comparison := "ASC", ">"
+	if query.Sort.Desc {
+		direction, comparison = "DESC", "<"
+	}
 
-	rows, err := pr.db.Query(sqlCommand, creatorId, limit, offset)
+	if query.Cursor != nil {
+		if column == "id" {
+			args = append(args, query.Cursor.Id)
+			where = append(where, fmt.Sprintf("id %s $%d", comparison, len(args)))
+		} else {
+			value, err := pr.cursorValue(column, query.Cursor.Value)
+			if err != nil {
+				logger.FromContext(ctx).Error(err)
+				return domain.Projects{}, err
+			}
+			args = append(args, value, query.Cursor.Id)
+			where = append(where, fmt.Sprintf("(%s, id) %s ($%d, $%d)", column, comparison, len(args)-1, len(args)))
+		}
+	}
+
+	orderBy := fmt.Sprintf("%s %s, id %s", column, direction, direction)
+	if column == "id" {
+		orderBy = "id " + direction
+	}
+
+	// One extra row tells whether there is a next page.
+	args = append(args, query.Limit+1)
+	sqlCommand := fmt.Sprintf(
+		`SELECT %s FROM projects WHERE %s ORDER BY %s LIMIT $%d`,
+		projectColumns, strings.Join(where, " AND "), orderBy, len(args),
+	)
+
+	rows, err := pr.db.QueryContext(ctx, sqlCommand, args...)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return domain.Projects{}, err
 	}
 	defer rows.Close()
@@ -75,82 +139,218 @@ func (pr projectRepository) FindByCreatorId(creatorId uint64, page, limit int32)
 			&projectModel.Title,
 			&projectModel.Description,
 			&projectModel.CreatorId,
+			&projectModel.CreatedAt,
+			&projectModel.ArchivedAt,
 		)
 		if err != nil {
-			logger.Logger.Error(err)
+			logger.FromContext(ctx).Error(err)
 			return domain.Projects{}, err
 		}
 		projects = append(projects, pr.modelToDomain(projectModel))
 	}
-
-	var total uint64
-	totalSqlCommand := `SELECT COUNT(*) FROM projects WHERE creator_id = $1;`
-	err = pr.db.QueryRow(totalSqlCommand, creatorId).Scan(&total)
-	if err != nil {
-		logger.Logger.Error(err)
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).Error(err)
 		return domain.Projects{}, err
 	}
 
-	var pages int32
-	if total > 0 {
-		pages = (int32(total) + limit - 1) / limit
+	var nextCursor *domain.Cursor
+	if len(projects) > int(query.Limit) {
+		projects = projects[:query.Limit]
+		last := projects[len(projects)-1]
+		nextCursor = &domain.Cursor{
+			Sort:  query.Sort.String(),
+			Value: pr.sortValue(column, last),
+			Id:    last.Id,
+		}
 	}
 
 	return domain.Projects{
-		Projects:    projects,
-		Total:       total,
-		CurrentPage: page,
-		LastPage:    pages,
+		Projects:   projects,
+		NextCursor: nextCursor,
+		Total:      total,
 	}, nil
 }
 
-func (pr projectRepository) Save(project domain.Project) (domain.Project, error) {
+func (pr projectRepository) filterConditions(creatorId uint64, query domain.ProjectQuery) ([]string, []interface{}) {
+	where := []string{"creator_id = $1"}
+	args := []interface{}{creatorId}
+
+	if query.TitleContains != "" {
+		args = append(args, "%"+escapeLike(query.TitleContains)+"%")
+		where = append(where, fmt.Sprintf("title ILIKE $%d", len(args)))
+	}
+	if query.CreatedFrom != nil {
+		args = append(args, *query.CreatedFrom)
+		where = append(where, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if query.CreatedTo != nil {
+		args = append(args, *query.CreatedTo)
+		where = append(where, fmt.Sprintf("created_at <= $%d", len(args)))
+	}
+	where = append(where, archivedCondition(query.Archived))
+	return where, args
+}
+
+// archivedCondition selects projects by whether they are archived.
+func archivedCondition(filter domain.ArchivedFilter) string {
+	switch filter {
+	case domain.ArchivedInclude:
+		return "true"
+	case domain.ArchivedOnly:
+		return "archived_at IS NOT NULL"
+	default:
+		return "archived_at IS NULL"
+	}
+}
+
+func (pr projectRepository) sortValue(column string, p domain.Project) string {
+	switch column {
+	case "title":
+		return p.Title
+	case "created_at":
+		return p.CreatedAt.UTC().Format(time.RFC3339Nano)
+	default:
+		return ""
+	}
+}
+
+func (pr projectRepository) cursorValue(column, value string) (interface{}, error) {
+	if column == "created_at" {
+		createdAt, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, domain.ErrInvalidCursor
+		}
+		return createdAt, nil
+	}
+	return value, nil
+}
+
+func (pr projectRepository) Save(ctx context.Context, project domain.Project) (domain.Project, error) {
 	projectModel := pr.domainToModel(project)
-	sqlCommand := `INSERT INTO projects (title, description, creator_id) VALUES ($1, $2, $3) RETURNING id`
+	sqlCommand := `INSERT INTO projects (title, description, creator_id) VALUES ($1, $2, $3) RETURNING id, created_at`
 
-	err := pr.db.QueryRow(sqlCommand, project.Title, project.Description, project.CreatorId).Scan(&projectModel.Id)
+	err := pr.db.QueryRowContext(ctx, sqlCommand, project.Title, project.Description, project.CreatorId).Scan(&projectModel.Id, &projectModel.CreatedAt)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return domain.Project{}, err
 	}
 	return pr.modelToDomain(projectModel), nil
 }
 
-func (pr projectRepository) Update(project domain.Project) (domain.Project, error) {
+// Update changes the title and description of a project, failing with
+// domain.ErrProjectArchived when it is archived.
+func (pr projectRepository) Update(ctx context.Context, project domain.Project) (domain.Project, error) {
 	projectModel := pr.domainToModel(project)
-	sqlCommand := `UPDATE projects SET title=$1, description=$2 WHERE id=$3`
+	sqlCommand := `UPDATE projects SET title=$1, description=$2 WHERE id=$3 AND archived_at IS NULL`
 
-	_, err := pr.db.Exec(sqlCommand, projectModel.Title, projectModel.Description, projectModel.Id)
+	result, err := pr.db.ExecContext(ctx, sqlCommand, projectModel.Title, projectModel.Description, projectModel.Id)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return domain.Project{}, err
+	}
+	updated, err := result.RowsAffected()
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return domain.Project{}, err
 	}
 
-	updatedProject, err := pr.FindById(projectModel.Id)
+	updatedProject, err := pr.FindById(ctx, projectModel.Id)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return domain.Project{}, err
 	}
+	if updated == 0 {
+		return domain.Project{}, domain.ErrProjectArchived
+	}
 	return updatedProject, nil
 }
 
-func (pr projectRepository) Delete(id uint64) error {
-	sqlCommand := `DELETE FROM projects WHERE id=$1`
+// Delete deletes a project and, through the foreign key, its attachments,
+// whose size it takes off the storage used by their uploaders.
+func (pr projectRepository) Delete(ctx context.Context, id uint64) error {
+	tx, err := pr.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	// Locking the project keeps attachments from being added and the
+	// project from being archived meanwhile.
+	var archived bool
+	sqlCommand := `SELECT archived_at IS NOT NULL FROM projects WHERE id=$1 FOR UPDATE`
+	err = tx.QueryRowContext(ctx, sqlCommand, id).Scan(&archived)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return err
+	}
+	if archived {
+		return domain.ErrProjectArchived
+	}
+
+	sqlCommand = `UPDATE users SET storage_used = users.storage_used - s.total
+		FROM (SELECT uploader_id, sum(size) AS total FROM attachments WHERE project_id = $1 GROUP BY uploader_id) s
+		WHERE users.id = s.uploader_id`
+	_, err = tx.ExecContext(ctx, sqlCommand, id)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return err
+	}
+
+	sqlCommand = `DELETE FROM projects WHERE id=$1`
+	_, err = tx.ExecContext(ctx, sqlCommand, id)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return err
+	}
 
-	_, err := pr.db.Exec(sqlCommand, id)
+	err = tx.Commit()
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return err
 	}
 	return nil
 }
 
+// Archive makes a project read-only. Archiving it again keeps the time it
+// was first archived.
+func (pr projectRepository) Archive(ctx context.Context, id uint64) (domain.Project, error) {
+	return pr.setArchivedAt(ctx, id, `COALESCE(archived_at, now())`)
+}
+
+func (pr projectRepository) Unarchive(ctx context.Context, id uint64) (domain.Project, error) {
+	return pr.setArchivedAt(ctx, id, `NULL`)
+}
+
+func (pr projectRepository) setArchivedAt(ctx context.Context, id uint64, value string) (domain.Project, error) {
+	sqlCommand := `UPDATE projects SET archived_at=` + value + ` WHERE id=$1 RETURNING ` + projectColumns
+	projectModel := project{}
+	err := pr.db.QueryRowContext(ctx, sqlCommand, id).Scan(
+		&projectModel.Id,
+		&projectModel.Title,
+		&projectModel.Description,
+		&projectModel.CreatorId,
+		&projectModel.CreatedAt,
+		&projectModel.ArchivedAt,
+	)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return domain.Project{}, err
+	}
+	return pr.modelToDomain(projectModel), nil
+}
+
 func (pr projectRepository) modelToDomain(p project) domain.Project {
 	return domain.Project{
 		Id:          p.Id,
 		Title:       p.Title,
 		Description: p.Description,
 		CreatorId:   p.CreatorId,
+		CreatedAt:   p.CreatedAt,
+		ArchivedAt:  p.ArchivedAt,
 	}
 }
 
@@ -160,5 +360,11 @@ func (pr projectRepository) domainToModel(p domain.Project) project {
 		Title:       p.Title,
 		Description: p.Description,
 		CreatorId:   p.CreatorId,
+		CreatedAt:   p.CreatedAt,
+		ArchivedAt:  p.ArchivedAt,
 	}
 }
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
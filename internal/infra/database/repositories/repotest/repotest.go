@@ -0,0 +1,1073 @@
+// Package repotest is the contract every repository implementation must
+// satisfy. The Postgres and in-memory repositories both run it, which
+// keeps the in-memory ones a faithful stand-in in tests.
+package repotest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-rest-api/internal/domain"
+	"go-rest-api/internal/infra/database/repositories"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Repositories struct {
+	Users       repositories.UserRepository
+	Sessions    repositories.SessionRepository
+	Projects    repositories.ProjectRepository
+	Attachments repositories.AttachmentRepository
+	Search      repositories.SearchRepository
+}
+
+// Run runs the contract against the repositories returned by
+// newRepositories, which must start empty on every call.
+func Run(t *testing.T, newRepositories func(t *testing.T) Repositories) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, repos Repositories)
+	}{
+		{"Users/FindMissing", testUsersFindMissing},
+		{"Users/SaveAndFind", testUsersSaveAndFind},
+		{"Users/ConfirmEmail", testUsersConfirmEmail},
+		{"Users/UpdateAvatar", testUsersUpdateAvatar},
+		{"Users/UpdatePassword", testUsersUpdatePassword},
+		{"Users/Disable", testUsersDisable},
+		{"Users/DeleteCascadesToProjects", testUsersDelete},
+		{"Sessions/Lifecycle", testSessions},
+		{"Sessions/DeleteByUserId", testSessionsDeleteByUserId},
+		{"Projects/FindMissing", testProjectsFindMissing},
+		{"Projects/SaveAndFind", testProjectsSaveAndFind},
+		{"Projects/SaveUnknownCreator", testProjectsSaveUnknownCreator},
+		{"Projects/Update", testProjectsUpdate},
+		{"Projects/Delete", testProjectsDelete},
+		{"Projects/Pagination", testProjectsPagination},
+		{"Projects/Filters", testProjectsFilters},
+		{"Projects/InvalidQuery", testProjectsInvalidQuery},
+		{"Projects/Archive", testProjectsArchive},
+		{"Projects/ArchivedReadOnly", testProjectsArchivedReadOnly},
+		{"Attachments/FindMissing", testAttachmentsFindMissing},
+		{"Attachments/SaveAndFind", testAttachmentsSaveAndFind},
+		{"Attachments/SaveUnknownProject", testAttachmentsSaveUnknownProject},
+		{"Attachments/Delete", testAttachmentsDelete},
+		{"Attachments/DeleteCascades", testAttachmentsDeleteCascades},
+		{"Attachments/ScanStatus", testAttachmentsScanStatus},
+		{"Attachments/Usage", testAttachmentsUsage},
+		{"Attachments/Quota", testAttachmentsQuota},
+		{"Attachments/UsageAfterCascades", testAttachmentsUsageAfterCascades},
+		{"Search/EscapesMarkup", testSearchEscapesMarkup},
+		{"Search/Archived", testSearchArchived},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newRepositories(t))
+		})
+	}
+}
+
+func saveUser(t *testing.T, repos Repositories, email string) domain.User {
+	t.Helper()
+	user, err := repos.Users.Save(context.Background(), domain.User{
+		Name:                   "User " + email,
+		Email:                  email,
+		Password:               "hash-" + email,
+		EmailConfirmationToken: "token-" + email,
+	})
+	if err != nil {
+		t.Fatalf("save user: %v", err)
+	}
+	return user
+}
+
+func saveProject(t *testing.T, repos Repositories, creatorId uint64, title string) domain.Project {
+	t.Helper()
+	project, err := repos.Projects.Save(context.Background(), domain.Project{
+		Title:       title,
+		Description: "About " + title,
+		CreatorId:   creatorId,
+	})
+	if err != nil {
+		t.Fatalf("save project: %v", err)
+	}
+	return project
+}
+
+func wantNoRows(t *testing.T, err error) {
+	t.Helper()
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("err = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testUsersFindMissing(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	_, err := repos.Users.FindById(ctx, 1)
+	wantNoRows(t, err)
+	_, err = repos.Users.FindByEmail(ctx, "nobody@example.com")
+	wantNoRows(t, err)
+	_, err = repos.Users.FindByEmailConfirmationToken(ctx, "token")
+	wantNoRows(t, err)
+}
+
+func testUsersSaveAndFind(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	saved := saveUser(t, repos, "ann@example.com")
+	if saved.Id == 0 {
+		t.Fatal("saved user has no id")
+	}
+
+	want := domain.User{
+		Id:                     saved.Id,
+		Name:                   "User ann@example.com",
+		Email:                  "ann@example.com",
+		Password:               "hash-ann@example.com",
+		EmailConfirmationToken: "token-ann@example.com",
+	}
+	byId, err := repos.Users.FindById(ctx, saved.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertUser(t, byId, want)
+
+	byEmail, err := repos.Users.FindByEmail(ctx, "ann@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertUser(t, byEmail, want)
+
+	other := saveUser(t, repos, "bob@example.com")
+	if other.Id == saved.Id {
+		t.Fatalf("both users got id %d", saved.Id)
+	}
+}
+
+func assertUser(t *testing.T, got, want domain.User) {
+	t.Helper()
+	if got.Id != want.Id || got.Name != want.Name || got.Email != want.Email ||
+		got.Password != want.Password || got.EmailConfirmed != want.EmailConfirmed ||
+		got.EmailConfirmationToken != want.EmailConfirmationToken {
+		t.Fatalf("user = %+v, want %+v", got, want)
+	}
+	if (got.Avatar == nil) != (want.Avatar == nil) || (got.Avatar != nil && *got.Avatar != *want.Avatar) {
+		t.Fatalf("avatar = %v, want %v", got.Avatar, want.Avatar)
+	}
+	if got.Disabled() != want.Disabled() {
+		t.Fatalf("disabled = %t, want %t", got.Disabled(), want.Disabled())
+	}
+}
+
+func testUsersConfirmEmail(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	saved := saveUser(t, repos, "ann@example.com")
+
+	found, err := repos.Users.FindByEmailConfirmationToken(ctx, saved.EmailConfirmationToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Id != saved.Id {
+		t.Fatalf("found user %d, want %d", found.Id, saved.Id)
+	}
+
+	if err = repos.Users.ConfirmUserEmail(ctx, found); err != nil {
+		t.Fatal(err)
+	}
+	confirmed, err := repos.Users.FindById(ctx, saved.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !confirmed.EmailConfirmed {
+		t.Fatal("email is not confirmed")
+	}
+	_, err = repos.Users.FindByEmailConfirmationToken(ctx, saved.EmailConfirmationToken)
+	wantNoRows(t, err)
+}
+
+func testUsersUpdateAvatar(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	saved := saveUser(t, repos, "ann@example.com")
+
+	avatar := "https://example.com/ann.png"
+	saved.Avatar = &avatar
+	saved.AvatarSizes = map[int]string{32: "https://example.com/ann-32.png"}
+	saved.AvatarKeys = []string{"avatars/1/ann.png", "avatars/1/ann-32.png"}
+	if _, err := repos.Users.UpdateUserAvatar(ctx, saved); err != nil {
+		t.Fatal(err)
+	}
+	avatar = "changed after the update"
+	saved.AvatarSizes[32] = "changed after the update"
+	saved.AvatarKeys[0] = "changed after the update"
+
+	found, err := repos.Users.FindById(ctx, saved.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Avatar == nil || *found.Avatar != "https://example.com/ann.png" {
+		t.Fatalf("avatar = %v, want https://example.com/ann.png", found.Avatar)
+	}
+	if found.AvatarSizes[32] != "https://example.com/ann-32.png" || len(found.AvatarSizes) != 1 {
+		t.Fatalf("avatar sizes = %v, want 32: https://example.com/ann-32.png", found.AvatarSizes)
+	}
+	if !slices.Equal(found.AvatarKeys, []string{"avatars/1/ann.png", "avatars/1/ann-32.png"}) {
+		t.Fatalf("avatar keys = %v, want avatars/1/ann.png and avatars/1/ann-32.png", found.AvatarKeys)
+	}
+
+	saved.Avatar, saved.AvatarSizes, saved.AvatarKeys = nil, nil, nil
+	if _, err := repos.Users.UpdateUserAvatar(ctx, saved); err != nil {
+		t.Fatal(err)
+	}
+	found, err = repos.Users.FindById(ctx, saved.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Avatar != nil || found.AvatarSizes != nil || found.AvatarKeys != nil {
+		t.Fatalf("avatar = %v %v %v after removing it", found.Avatar, found.AvatarSizes, found.AvatarKeys)
+	}
+}
+
+func testUsersUpdatePassword(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	saved := saveUser(t, repos, "ann@example.com")
+
+	if err := repos.Users.UpdatePassword(ctx, saved.Id, "new-hash"); err != nil {
+		t.Fatal(err)
+	}
+	found, err := repos.Users.FindById(ctx, saved.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Password != "new-hash" {
+		t.Fatalf("password = %q, want new-hash", found.Password)
+	}
+
+	wantNoRows(t, repos.Users.UpdatePassword(ctx, saved.Id+1, "new-hash"))
+}
+
+func testUsersDisable(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	saved := saveUser(t, repos, "ann@example.com")
+
+	disabled, err := repos.Users.Disable(ctx, saved.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !disabled.Disabled() {
+		t.Fatal("user is not disabled")
+	}
+
+	again, err := repos.Users.Disable(ctx, saved.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.DisabledAt.Equal(*disabled.DisabledAt) {
+		t.Fatalf("disabling again moved disabled_at from %v to %v", disabled.DisabledAt, again.DisabledAt)
+	}
+
+	found, err := repos.Users.FindById(ctx, saved.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.DisabledAt == nil || !found.DisabledAt.Equal(*disabled.DisabledAt) {
+		t.Fatalf("disabled_at = %v, want %v", found.DisabledAt, disabled.DisabledAt)
+	}
+
+	_, err = repos.Users.Disable(ctx, saved.Id+1)
+	wantNoRows(t, err)
+}
+
+func testUsersDelete(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	ann := saveUser(t, repos, "ann@example.com")
+	bob := saveUser(t, repos, "bob@example.com")
+	annProject := saveProject(t, repos, ann.Id, "alpha")
+	bobProject := saveProject(t, repos, bob.Id, "bravo")
+
+	if err := repos.Users.Delete(ctx, ann.Id); err != nil {
+		t.Fatal(err)
+	}
+	_, err := repos.Users.FindById(ctx, ann.Id)
+	wantNoRows(t, err)
+	_, err = repos.Projects.FindById(ctx, annProject.Id)
+	wantNoRows(t, err)
+
+	if _, err = repos.Projects.FindById(ctx, bobProject.Id); err != nil {
+		t.Fatalf("project of another user was deleted: %v", err)
+	}
+	if err = repos.Users.Delete(ctx, ann.Id); err != nil {
+		t.Fatalf("deleting a missing user: %v", err)
+	}
+}
+
+func testSessions(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	sess := domain.Session{UserId: 1, UUID: uuid.New()}
+
+	if err := repos.Sessions.Exists(ctx, sess); err == nil {
+		t.Fatal("missing session exists")
+	}
+	if err := repos.Sessions.Save(ctx, sess); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Sessions.Exists(ctx, sess); err != nil {
+		t.Fatalf("saved session does not exist: %v", err)
+	}
+	if err := repos.Sessions.Exists(ctx, domain.Session{UserId: 2, UUID: sess.UUID}); err == nil {
+		t.Fatal("session exists for another user")
+	}
+	if err := repos.Sessions.Save(ctx, sess); err == nil {
+		t.Fatal("saving a session twice succeeded")
+	}
+
+	if err := repos.Sessions.Delete(ctx, sess); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Sessions.Exists(ctx, sess); err == nil {
+		t.Fatal("deleted session exists")
+	}
+	if err := repos.Sessions.Delete(ctx, sess); err != nil {
+		t.Fatalf("deleting a missing session: %v", err)
+	}
+}
+
+func testSessionsDeleteByUserId(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	sessions := []domain.Session{
+		{UserId: 1, UUID: uuid.New()},
+		{UserId: 1, UUID: uuid.New()},
+		{UserId: 2, UUID: uuid.New()},
+	}
+	for _, sess := range sessions {
+		if err := repos.Sessions.Save(ctx, sess); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deleted, err := repos.Sessions.DeleteByUserId(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Fatalf("deleted %d sessions, want 2", deleted)
+	}
+	for _, sess := range sessions[:2] {
+		if repos.Sessions.Exists(ctx, sess) == nil {
+			t.Fatal("session of user 1 still exists")
+		}
+	}
+	if err = repos.Sessions.Exists(ctx, sessions[2]); err != nil {
+		t.Fatalf("session of user 2 was deleted: %v", err)
+	}
+
+	deleted, err = repos.Sessions.DeleteByUserId(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 0 {
+		t.Fatalf("deleted %d sessions, want 0", deleted)
+	}
+}
+
+func testProjectsFindMissing(t *testing.T, repos Repositories) {
+	_, err := repos.Projects.FindById(context.Background(), 1)
+	wantNoRows(t, err)
+}
+
+func testProjectsSaveAndFind(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	user := saveUser(t, repos, "ann@example.com")
+
+	before := time.Now().Add(-time.Minute)
+	saved := saveProject(t, repos, user.Id, "alpha")
+	if saved.Id == 0 {
+		t.Fatal("saved project has no id")
+	}
+	if saved.CreatedAt.Before(before) {
+		t.Fatalf("created_at = %v, want about now", saved.CreatedAt)
+	}
+
+	found, err := repos.Projects.FindById(ctx, saved.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertProject(t, found, saved)
+}
+
+func assertProject(t *testing.T, got, want domain.Project) {
+	t.Helper()
+	if got.Id != want.Id || got.Title != want.Title || got.Description != want.Description ||
+		got.CreatorId != want.CreatorId || !got.CreatedAt.Equal(want.CreatedAt) ||
+		(got.ArchivedAt == nil) != (want.ArchivedAt == nil) ||
+		got.ArchivedAt != nil && !got.ArchivedAt.Equal(*want.ArchivedAt) {
+		t.Fatalf("project = %+v, want %+v", got, want)
+	}
+}
+
+func testProjectsSaveUnknownCreator(t *testing.T, repos Repositories) {
+	_, err := repos.Projects.Save(context.Background(), domain.Project{Title: "alpha", CreatorId: 42})
+	if err == nil {
+		t.Fatal("saved a project of a missing user")
+	}
+}
+
+func testProjectsUpdate(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	user := saveUser(t, repos, "ann@example.com")
+	saved := saveProject(t, repos, user.Id, "alpha")
+
+	saved.Title, saved.Description = "bravo", "Renamed"
+	updated, err := repos.Projects.Update(ctx, saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertProject(t, updated, saved)
+
+	found, err := repos.Projects.FindById(ctx, saved.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertProject(t, found, saved)
+
+	saved.Id++
+	_, err = repos.Projects.Update(ctx, saved)
+	wantNoRows(t, err)
+}
+
+func testProjectsDelete(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	user := saveUser(t, repos, "ann@example.com")
+	saved := saveProject(t, repos, user.Id, "alpha")
+
+	if err := repos.Projects.Delete(ctx, saved.Id); err != nil {
+		t.Fatal(err)
+	}
+	_, err := repos.Projects.FindById(ctx, saved.Id)
+	wantNoRows(t, err)
+	if err = repos.Projects.Delete(ctx, saved.Id); err != nil {
+		t.Fatalf("deleting a missing project: %v", err)
+	}
+}
+
+// Titles are lowercase ASCII so that every collation orders them alike.
+var paginationTitles = []string{"delta", "alpha", "golf", "charlie", "echo", "bravo", "foxtrot"}
+
+func testProjectsPagination(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	ann := saveUser(t, repos, "ann@example.com")
+	bob := saveUser(t, repos, "bob@example.com")
+
+	var projects []domain.Project
+	for i, title := range paginationTitles {
+		projects = append(projects, saveProject(t, repos, ann.Id, title))
+		if i%3 == 0 {
+			saveProject(t, repos, bob.Id, title)
+		}
+	}
+
+	for _, field := range domain.ProjectSortFields {
+		for _, desc := range []bool{false, true} {
+			s := domain.Sort{Field: field, Desc: desc}
+			t.Run(s.String(), func(t *testing.T) {
+				want := sortedIds(projects, s)
+				var got []uint64
+				query := domain.ProjectQuery{Limit: 3, Sort: s, WithTotal: true}
+				for page := 0; ; page++ {
+					if page > len(projects) {
+						t.Fatal("pagination does not end")
+					}
+					result, err := repos.Projects.FindByCreatorId(ctx, ann.Id, query)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if result.Total == nil || *result.Total != uint64(len(projects)) {
+						t.Fatalf("total = %v, want %d", result.Total, len(projects))
+					}
+					for _, p := range result.Projects {
+						got = append(got, p.Id)
+					}
+					if result.NextCursor == nil {
+						break
+					}
+					if len(result.Projects) != 3 {
+						t.Fatalf("page %d has %d projects and a next cursor", page, len(result.Projects))
+					}
+					// Cursors travel through clients encoded.
+					cursor, err := domain.DecodeCursor(result.NextCursor.Encode())
+					if err != nil {
+						t.Fatal(err)
+					}
+					query.Cursor = &cursor
+				}
+				if fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Fatalf("ids = %v, want %v", got, want)
+				}
+			})
+		}
+	}
+
+	result, err := repos.Projects.FindByCreatorId(ctx, ann.Id, domain.ProjectQuery{Limit: int32(len(projects)), Sort: domain.Sort{Field: "id"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.NextCursor != nil {
+		t.Fatal("a page holding every project has a next cursor")
+	}
+	if result.Total != nil {
+		t.Fatal("total is set without WithTotal")
+	}
+}
+
+func sortedIds(projects []domain.Project, s domain.Sort) []uint64 {
+	sorted := append([]domain.Project(nil), projects...)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if s.Desc {
+			a, b = b, a
+		}
+		switch {
+		case s.Field == "title" && a.Title != b.Title:
+			return a.Title < b.Title
+		case s.Field == "created_at" && !a.CreatedAt.Equal(b.CreatedAt):
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.Id < b.Id
+	})
+	ids := make([]uint64, len(sorted))
+	for i, p := range sorted {
+		ids[i] = p.Id
+	}
+	return ids
+}
+
+func testProjectsFilters(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	user := saveUser(t, repos, "ann@example.com")
+	first := saveProject(t, repos, user.Id, "Quarterly report")
+	second := saveProject(t, repos, user.Id, "100% done")
+	third := saveProject(t, repos, user.Id, "report_draft")
+
+	tests := []struct {
+		name  string
+		query domain.ProjectQuery
+		want  []uint64
+	}{
+		{"title ignores case", domain.ProjectQuery{TitleContains: "REPORT"}, []uint64{first.Id, third.Id}},
+		{"title matches percent literally", domain.ProjectQuery{TitleContains: "0%"}, []uint64{second.Id}},
+		{"title matches underscore literally", domain.ProjectQuery{TitleContains: "t_d"}, []uint64{third.Id}},
+		{"created from", domain.ProjectQuery{CreatedFrom: &second.CreatedAt}, []uint64{second.Id, third.Id}},
+		{"created to", domain.ProjectQuery{CreatedTo: &second.CreatedAt}, []uint64{first.Id, second.Id}},
+		{"other creator", domain.ProjectQuery{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creatorId := user.Id
+			if tt.want == nil {
+				creatorId = user.Id + 1
+			}
+			tt.query.Sort = domain.Sort{Field: "id"}
+			tt.query.WithTotal = true
+			result, err := repos.Projects.FindByCreatorId(ctx, creatorId, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []uint64
+			for _, p := range result.Projects {
+				got = append(got, p.Id)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("ids = %v, want %v", got, tt.want)
+			}
+			if *result.Total != uint64(len(tt.want)) {
+				t.Fatalf("total = %d, want %d", *result.Total, len(tt.want))
+			}
+			if result.Projects == nil {
+				t.Fatal("projects is nil, want an empty list")
+			}
+		})
+	}
+}
+
+func testProjectsArchive(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	user := saveUser(t, repos, "ann@example.com")
+	active := saveProject(t, repos, user.Id, "alpha")
+	saved := saveProject(t, repos, user.Id, "bravo")
+
+	archived, err := repos.Projects.Archive(ctx, saved.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archived.ArchivedAt == nil || archived.ArchivedAt.Before(saved.CreatedAt) {
+		t.Fatalf("archived_at = %v, want about now", archived.ArchivedAt)
+	}
+	again, err := repos.Projects.Archive(ctx, saved.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertProject(t, again, archived)
+	found, err := repos.Projects.FindById(ctx, saved.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertProject(t, found, archived)
+
+	renamed := archived
+	renamed.Title = "charlie"
+	if _, err = repos.Projects.Update(ctx, renamed); !errors.Is(err, domain.ErrProjectArchived) {
+		t.Fatalf("updating an archived project: err = %v, want domain.ErrProjectArchived", err)
+	}
+	found, err = repos.Projects.FindById(ctx, saved.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertProject(t, found, archived)
+
+	for filter, want := range map[domain.ArchivedFilter][]uint64{
+		"":                     {active.Id},
+		domain.ArchivedExclude: {active.Id},
+		domain.ArchivedInclude: {active.Id, saved.Id},
+		domain.ArchivedOnly:    {saved.Id},
+	} {
+		query := domain.ProjectQuery{Sort: domain.Sort{Field: "id"}, WithTotal: true, Archived: filter}
+		result, err := repos.Projects.FindByCreatorId(ctx, user.Id, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []uint64
+		for _, p := range result.Projects {
+			got = append(got, p.Id)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) || *result.Total != uint64(len(want)) {
+			t.Fatalf("archived=%q: ids = %v, total = %d, want %v", filter, got, *result.Total, want)
+		}
+	}
+
+	unarchived, err := repos.Projects.Unarchive(ctx, saved.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertProject(t, unarchived, saved)
+
+	_, err = repos.Projects.Archive(ctx, saved.Id+1)
+	wantNoRows(t, err)
+	_, err = repos.Projects.Unarchive(ctx, saved.Id+1)
+	wantNoRows(t, err)
+}
+
+func testProjectsInvalidQuery(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	user := saveUser(t, repos, "ann@example.com")
+	for i := 0; i < int(domain.DefaultProjectsLimit)+1; i++ {
+		saveProject(t, repos, user.Id, fmt.Sprintf("project %02d", i))
+	}
+
+	result, err := repos.Projects.FindByCreatorId(ctx, user.Id, domain.ProjectQuery{Limit: 0, Sort: domain.Sort{Field: "id"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Projects) != int(domain.DefaultProjectsLimit) {
+		t.Fatalf("limit 0 returned %d projects, want the default %d", len(result.Projects), domain.DefaultProjectsLimit)
+	}
+
+	_, err = repos.Projects.FindByCreatorId(ctx, user.Id, domain.ProjectQuery{Sort: domain.Sort{Field: "description"}})
+	if err == nil {
+		t.Fatal("sorting by an unknown field succeeded")
+	}
+
+	cursor := domain.Cursor{Sort: "created_at", Value: "yesterday", Id: 1}
+	_, err = repos.Projects.FindByCreatorId(ctx, user.Id, domain.ProjectQuery{Sort: domain.Sort{Field: "created_at"}, Cursor: &cursor})
+	if !errors.Is(err, domain.ErrInvalidCursor) {
+		t.Fatalf("err = %v, want domain.ErrInvalidCursor", err)
+	}
+}
+
+func testProjectsArchivedReadOnly(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	user := saveUser(t, repos, "ann@example.com")
+	project := saveProject(t, repos, user.Id, "alpha")
+	spec := saveAttachment(t, repos, project, "spec.txt")
+	if _, err := repos.Projects.Archive(ctx, project.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repos.Projects.Delete(ctx, project.Id); !errors.Is(err, domain.ErrProjectArchived) {
+		t.Fatalf("deleting an archived project: err = %v, want domain.ErrProjectArchived", err)
+	}
+	if _, err := repos.Projects.FindById(ctx, project.Id); err != nil {
+		t.Fatalf("archived project is gone: %v", err)
+	}
+	_, err := repos.Attachments.Save(ctx, domain.Attachment{
+		ProjectId:  project.Id,
+		UploaderId: user.Id,
+		Filename:   "notes.txt",
+		Size:       5,
+		Key:        fmt.Sprintf("attachments/%d/notes.txt", project.Id),
+		ScanStatus: domain.ScanPending,
+	}, domain.StorageQuota{})
+	if !errors.Is(err, domain.ErrProjectArchived) {
+		t.Fatalf("attaching to an archived project: err = %v, want domain.ErrProjectArchived", err)
+	}
+	if err = repos.Attachments.Delete(ctx, spec.Id); !errors.Is(err, domain.ErrProjectArchived) {
+		t.Fatalf("deleting an attachment of an archived project: err = %v, want domain.ErrProjectArchived", err)
+	}
+	if _, err = repos.Attachments.FindById(ctx, spec.Id); err != nil {
+		t.Fatalf("attachment of the archived project is gone: %v", err)
+	}
+	usage, err := repos.Attachments.Usage(ctx, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Used != spec.Size {
+		t.Fatalf("used = %d, want the %d bytes of spec.txt", usage.Used, spec.Size)
+	}
+}
+
+func saveAttachment(t *testing.T, repos Repositories, project domain.Project, filename string) domain.Attachment {
+	t.Helper()
+	attachment, err := repos.Attachments.Save(context.Background(), domain.Attachment{
+		ProjectId:   project.Id,
+		UploaderId:  project.CreatorId,
+		Filename:    filename,
+		Size:        int64(len(filename)),
+		ContentType: "text/plain; charset=utf-8",
+		Checksum:    fmt.Sprintf("checksum of %s", filename),
+		Key:         fmt.Sprintf("attachments/%d/%s", project.Id, filename),
+		ScanStatus:  domain.ScanPending,
+	}, domain.StorageQuota{})
+	if err != nil {
+		t.Fatalf("save attachment: %v", err)
+	}
+	return attachment
+}
+
+func testAttachmentsFindMissing(t *testing.T, repos Repositories) {
+	_, err := repos.Attachments.FindById(context.Background(), 42)
+	wantNoRows(t, err)
+	_, err = repos.Attachments.FindByKey(context.Background(), "attachments/42/spec.txt")
+	wantNoRows(t, err)
+}
+
+func testAttachmentsSaveAndFind(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	ann := saveUser(t, repos, "ann@example.com")
+	alpha := saveProject(t, repos, ann.Id, "alpha")
+	bravo := saveProject(t, repos, ann.Id, "bravo")
+
+	spec := saveAttachment(t, repos, alpha, "spec.txt")
+	if spec.Id == 0 || spec.CreatedAt.IsZero() {
+		t.Fatalf("saved attachment has no id or creation time: %+v", spec)
+	}
+	screenshot := saveAttachment(t, repos, alpha, "screenshot.png")
+	saveAttachment(t, repos, bravo, "notes.txt")
+
+	found, err := repos.Attachments.FindById(ctx, spec.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found.CreatedAt.Equal(spec.CreatedAt) {
+		t.Fatalf("created at = %v, want %v", found.CreatedAt, spec.CreatedAt)
+	}
+	found.CreatedAt = spec.CreatedAt
+	if found != spec {
+		t.Fatalf("found %+v, want %+v", found, spec)
+	}
+	found, err = repos.Attachments.FindByKey(ctx, screenshot.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Id != screenshot.Id {
+		t.Fatalf("found %+v by key, want screenshot.png", found)
+	}
+
+	attachments, err := repos.Attachments.FindByProjectId(ctx, alpha.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attachments) != 2 || attachments[0].Id != spec.Id || attachments[1].Id != screenshot.Id {
+		t.Fatalf("attachments of alpha = %+v, want spec.txt and screenshot.png", attachments)
+	}
+	attachments, err = repos.Attachments.FindByProjectId(ctx, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attachments == nil || len(attachments) != 0 {
+		t.Fatalf("attachments of a missing project = %#v, want an empty slice", attachments)
+	}
+}
+
+func testAttachmentsSaveUnknownProject(t *testing.T, repos Repositories) {
+	ann := saveUser(t, repos, "ann@example.com")
+	_, err := repos.Attachments.Save(context.Background(), domain.Attachment{
+		ProjectId:  42,
+		UploaderId: ann.Id,
+		Filename:   "spec.txt",
+		Key:        "attachments/42/spec.txt",
+	}, domain.StorageQuota{})
+	if err == nil {
+		t.Fatal("saved an attachment of a missing project")
+	}
+}
+
+func testAttachmentsDelete(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	ann := saveUser(t, repos, "ann@example.com")
+	saved := saveAttachment(t, repos, saveProject(t, repos, ann.Id, "alpha"), "spec.txt")
+
+	if err := repos.Attachments.Delete(ctx, saved.Id); err != nil {
+		t.Fatal(err)
+	}
+	_, err := repos.Attachments.FindById(ctx, saved.Id)
+	wantNoRows(t, err)
+	if err = repos.Attachments.Delete(ctx, saved.Id); err != nil {
+		t.Fatalf("deleting a missing attachment: %v", err)
+	}
+}
+
+func testAttachmentsDeleteCascades(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	ann := saveUser(t, repos, "ann@example.com")
+	bob := saveUser(t, repos, "bob@example.com")
+	alpha := saveProject(t, repos, ann.Id, "alpha")
+	bravo := saveProject(t, repos, bob.Id, "bravo")
+	spec := saveAttachment(t, repos, alpha, "spec.txt")
+	notes := saveAttachment(t, repos, bravo, "notes.txt")
+
+	if err := repos.Projects.Delete(ctx, alpha.Id); err != nil {
+		t.Fatal(err)
+	}
+	_, err := repos.Attachments.FindById(ctx, spec.Id)
+	wantNoRows(t, err)
+
+	if err = repos.Users.Delete(ctx, bob.Id); err != nil {
+		t.Fatal(err)
+	}
+	_, err = repos.Attachments.FindById(ctx, notes.Id)
+	wantNoRows(t, err)
+}
+
+func testAttachmentsScanStatus(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	ann := saveUser(t, repos, "ann@example.com")
+	alpha := saveProject(t, repos, ann.Id, "alpha")
+	spec := saveAttachment(t, repos, alpha, "spec.txt")
+	notes := saveAttachment(t, repos, alpha, "notes.txt")
+	eicar := saveAttachment(t, repos, alpha, "eicar.com")
+
+	pending, err := repos.Attachments.FindPendingScan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 3 || pending[0].Id != spec.Id || pending[0].ScanStatus != domain.ScanPending {
+		t.Fatalf("pending = %+v, want all 3 attachments", pending)
+	}
+
+	if err = repos.Attachments.SetScanStatus(ctx, spec.Id, domain.ScanClean); err != nil {
+		t.Fatal(err)
+	}
+	if err = repos.Attachments.SetScanStatus(ctx, eicar.Id, domain.ScanInfected); err != nil {
+		t.Fatal(err)
+	}
+	found, err := repos.Attachments.FindById(ctx, eicar.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.ScanStatus != domain.ScanInfected {
+		t.Fatalf("scan status = %q, want infected", found.ScanStatus)
+	}
+	pending, err = repos.Attachments.FindPendingScan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].Id != notes.Id {
+		t.Fatalf("pending = %+v, want notes.txt", pending)
+	}
+}
+
+// wantUsage checks the storage used by a user and by the projects they
+// created, in order.
+func wantUsage(t *testing.T, repos Repositories, user domain.User, used int64, projects ...int64) {
+	t.Helper()
+	usage, err := repos.Attachments.Usage(context.Background(), user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]int64, len(usage.Projects))
+	for i, project := range usage.Projects {
+		got[i] = project.Used
+	}
+	if usage.Used != used || !slices.Equal(got, projects) {
+		t.Fatalf("usage of %s = %d %v, want %d %v", user.Email, usage.Used, got, used, projects)
+	}
+}
+
+func testAttachmentsUsage(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	ann := saveUser(t, repos, "ann@example.com")
+	wantUsage(t, repos, ann, 0)
+
+	alpha := saveProject(t, repos, ann.Id, "alpha")
+	bravo := saveProject(t, repos, ann.Id, "bravo")
+	spec := saveAttachment(t, repos, alpha, "spec.txt")
+	saveAttachment(t, repos, alpha, "screenshot.png")
+	saveAttachment(t, repos, bravo, "notes.txt")
+	wantUsage(t, repos, ann, 8+14+9, 8+14, 9)
+
+	usage, err := repos.Attachments.Usage(ctx, ann.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Projects[0].ProjectId != alpha.Id || usage.Projects[1].Title != "bravo" {
+		t.Fatalf("projects = %+v, want alpha and bravo", usage.Projects)
+	}
+
+	if err = repos.Attachments.Delete(ctx, spec.Id); err != nil {
+		t.Fatal(err)
+	}
+	// Deleting again gives nothing back twice.
+	if err = repos.Attachments.Delete(ctx, spec.Id); err != nil {
+		t.Fatal(err)
+	}
+	wantUsage(t, repos, ann, 14+9, 14, 9)
+
+	_, err = repos.Attachments.Usage(ctx, 42)
+	wantNoRows(t, err)
+}
+
+func testAttachmentsQuota(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	ann := saveUser(t, repos, "ann@example.com")
+	alpha := saveProject(t, repos, ann.Id, "alpha")
+	bravo := saveProject(t, repos, ann.Id, "bravo")
+	saveAttachment(t, repos, alpha, "spec.txt")
+
+	save := func(project domain.Project, size int64, quota domain.StorageQuota) error {
+		_, err := repos.Attachments.Save(ctx, domain.Attachment{
+			ProjectId:   project.Id,
+			UploaderId:  ann.Id,
+			Filename:    "big.bin",
+			Size:        size,
+			ContentType: "application/octet-stream",
+			Key:         fmt.Sprintf("attachments/%d/big-%d", project.Id, size),
+		}, quota)
+		return err
+	}
+	if err := save(bravo, 13, domain.StorageQuota{User: 20}); !errors.Is(err, domain.ErrQuotaExceeded) {
+		t.Fatalf("err = %v, want domain.ErrQuotaExceeded from the user quota", err)
+	}
+	if err := save(alpha, 3, domain.StorageQuota{Project: 10}); !errors.Is(err, domain.ErrQuotaExceeded) {
+		t.Fatalf("err = %v, want domain.ErrQuotaExceeded from the project quota", err)
+	}
+	wantUsage(t, repos, ann, 8, 8, 0)
+	attachments, err := repos.Attachments.FindByProjectId(ctx, bravo.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attachments) != 0 {
+		t.Fatalf("attachments over quota were saved: %+v", attachments)
+	}
+
+	// Filling a quota exactly is allowed.
+	if err = save(bravo, 12, domain.StorageQuota{User: 20, Project: 12}); err != nil {
+		t.Fatal(err)
+	}
+	wantUsage(t, repos, ann, 20, 8, 12)
+}
+
+func testAttachmentsUsageAfterCascades(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	ann := saveUser(t, repos, "ann@example.com")
+	bob := saveUser(t, repos, "bob@example.com")
+	alpha := saveProject(t, repos, ann.Id, "alpha")
+	bravo := saveProject(t, repos, bob.Id, "bravo")
+	saveAttachment(t, repos, alpha, "spec.txt")
+	saveAttachment(t, repos, bravo, "notes.txt")
+
+	// Files uploaded to the projects of someone else.
+	alpha.CreatorId = bob.Id
+	saveAttachment(t, repos, alpha, "bob.txt")
+	bravo.CreatorId = ann.Id
+	saveAttachment(t, repos, bravo, "ann.txt")
+	wantUsage(t, repos, ann, 8+7, 8+7)
+	wantUsage(t, repos, bob, 9+7, 9+7)
+
+	if err := repos.Projects.Delete(ctx, alpha.Id); err != nil {
+		t.Fatal(err)
+	}
+	wantUsage(t, repos, ann, 7)
+	wantUsage(t, repos, bob, 9, 9+7)
+
+	if err := repos.Users.Delete(ctx, ann.Id); err != nil {
+		t.Fatal(err)
+	}
+	wantUsage(t, repos, bob, 9, 9)
+}
+
+func testSearchEscapesMarkup(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	user := saveUser(t, repos, "ann@example.com")
+	saved, err := repos.Projects.Save(ctx, domain.Project{
+		Title:       `<img src=x onerror="alert(1)"> Garden`,
+		Description: `Plant <b>tomatoes</b> & 'beans' in the garden`,
+		CreatorId:   user.Id,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := repos.Search.SearchProjects(ctx, user.Id, domain.SearchQuery{Terms: []string{"garden"}, Limit: domain.DefaultSearchLimit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Results) != 1 || results.Results[0].Id != saved.Id {
+		t.Fatalf("results = %+v, want the saved project", results.Results)
+	}
+	result := results.Results[0]
+	if result.Title != saved.Title {
+		t.Fatalf("title = %q, want it unchanged", result.Title)
+	}
+	for _, snippet := range []string{result.TitleSnippet, result.DescriptionSnippet} {
+		if !strings.Contains(snippet, "<mark>") {
+			t.Fatalf("snippet %q highlights nothing", snippet)
+		}
+		text := strings.NewReplacer("<mark>", "", "</mark>", "").Replace(snippet)
+		if strings.ContainsAny(text, `<>"'`) || !strings.Contains(text, "&lt;") {
+			t.Fatalf("snippet %q is not HTML escaped", snippet)
+		}
+	}
+}
+
+func testSearchArchived(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	user := saveUser(t, repos, "ann@example.com")
+	active := saveProject(t, repos, user.Id, "garden beds")
+	archived := saveProject(t, repos, user.Id, "garden shed")
+	if _, err := repos.Projects.Archive(ctx, archived.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	for filter, want := range map[domain.ArchivedFilter][]uint64{
+		"":                     {active.Id},
+		domain.ArchivedExclude: {active.Id},
+		domain.ArchivedInclude: {active.Id, archived.Id},
+		domain.ArchivedOnly:    {archived.Id},
+	} {
+		query := domain.SearchQuery{Terms: []string{"garden"}, Limit: domain.DefaultSearchLimit, Archived: filter}
+		results, err := repos.Search.SearchProjects(ctx, user.Id, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []uint64
+		for _, result := range results.Results {
+			got = append(got, result.Id)
+		}
+		slices.Sort(got)
+		if fmt.Sprint(got) != fmt.Sprint(want) || results.Total != uint64(len(want)) {
+			t.Fatalf("archived=%q: ids = %v, total = %d, want %v", filter, got, results.Total, want)
+		}
+	}
+}
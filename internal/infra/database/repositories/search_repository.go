@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"go-rest-api/internal/domain"
+	"go-rest-api/internal/infra/logger"
+	"strings"
+)
+
+type SearchRepository interface {
+	SearchProjects(ctx context.Context, userId uint64, query domain.SearchQuery) (domain.SearchResults, error)
+}
+
+type searchResult struct {
+	Id                 uint64  `db:"id"`
+	Title              string  `db:"title"`
+	TitleSnippet       string  `db:"title_snippet"`
+	DescriptionSnippet string  `db:"description_snippet"`
+	Rank               float32 `db:"rank"`
+}
+
+type searchRepository struct {
+	db       *sql.DB
+	language string
+}
+
+// NewSearchRepository searches the search_vector column with the text
+// search configuration language. database.Migrate builds the column with
+// the same one, as queries only match terms stemmed alike.
+func NewSearchRepository(db *sql.DB, language string) SearchRepository {
+	return searchRepository{
+		db:       db,
+		language: language,
+	}
+}
+
+func (sr searchRepository) SearchProjects(ctx context.Context, userId uint64, query domain.SearchQuery) (domain.SearchResults, error) {
+	tsQuery := sr.prefixQuery(query.Terms)
+
+	sqlCommand := `
+		WITH q AS (SELECT to_tsquery($1::regconfig, $2) AS query)
+		SELECT
+			p.id,
+			p.title,
+			ts_headline($1::regconfig, ` + htmlEscaped("p.title") + `, q.query, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+			ts_headline($1::regconfig, ` + htmlEscaped("coalesce(p.description, '')") + `, q.query, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5'),
+			ts_rank_cd(p.search_vector, q.query) AS rank
+		FROM projects p, q
+		WHERE p.creator_id = $3 AND p.search_vector @@ q.query AND ` + archivedCondition(query.Archived) + `
+		ORDER BY rank DESC, p.id DESC
+		LIMIT $4 OFFSET $5`
+
+	rows, err := sr.db.QueryContext(ctx, sqlCommand, sr.language, tsQuery, userId, query.Limit, query.Offset)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return domain.SearchResults{}, err
+	}
+	defer rows.Close()
+
+	results := []domain.SearchResult{}
+	for rows.Next() {
+		resultModel := searchResult{}
+		err = rows.Scan(
+			&resultModel.Id,
+			&resultModel.Title,
+			&resultModel.TitleSnippet,
+			&resultModel.DescriptionSnippet,
+			&resultModel.Rank,
+		)
+		if err != nil {
+			logger.FromContext(ctx).Error(err)
+			return domain.SearchResults{}, err
+		}
+		results = append(results, sr.modelToDomain(resultModel))
+	}
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).Error(err)
+		return domain.SearchResults{}, err
+	}
+
+	var total uint64
+	totalSqlCommand := `SELECT COUNT(*) FROM projects
+		WHERE creator_id = $3 AND search_vector @@ to_tsquery($1::regconfig, $2) AND ` + archivedCondition(query.Archived)
+	err = sr.db.QueryRowContext(ctx, totalSqlCommand, sr.language, tsQuery, userId).Scan(&total)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return domain.SearchResults{}, err
+	}
+
+	return domain.SearchResults{
+		Results: results,
+		Total:   total,
+	}, nil
+}
+
+// htmlEscaped escapes the text of expr like html.EscapeString, so that the
+// <mark> tags of ts_headline are the only markup of a snippet.
+func htmlEscaped(expr string) string {
+	return `replace(replace(replace(replace(replace(` + expr +
+		`, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'), '''', '&#39;')`
+}
+
+// prefixQuery turns sanitized terms into a tsquery that matches every term
+// as a prefix, so "proj dead" finds "project deadline".
+func (sr searchRepository) prefixQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = term + ":*"
+	}
+	return strings.Join(parts, " & ")
+}
+
+func (sr searchRepository) modelToDomain(r searchResult) domain.SearchResult {
+	return domain.SearchResult{
+		Type:               "project",
+		Id:                 r.Id,
+		Title:              r.Title,
+		TitleSnippet:       r.TitleSnippet,
+		DescriptionSnippet: r.DescriptionSnippet,
+		Rank:               r.Rank,
+	}
+}
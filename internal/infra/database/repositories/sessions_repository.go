@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"go-rest-api/internal/domain"
@@ -10,9 +11,10 @@ import (
 )
 
 type SessionRepository interface {
-	Save(sess domain.Session) error
-	Exists(sess domain.Session) error
-	Delete(sess domain.Session) error
+	Save(ctx context.Context, sess domain.Session) error
+	Exists(ctx context.Context, sess domain.Session) error
+	Delete(ctx context.Context, sess domain.Session) error
+	DeleteByUserId(ctx context.Context, userId uint64) (int64, error)
 }
 
 type session struct {
@@ -30,44 +32,54 @@ func NewSessionRepository(db *sql.DB) SessionRepository {
 	}
 }
 
-func (sr sessionRepository) Save(sess domain.Session) error {
+func (sr sessionRepository) Save(ctx context.Context, sess domain.Session) error {
 	s := sr.domainToModel(sess)
 	sqlCommand := `INSERT INTO sessions (uuid, user_id) VALUES ($1, $2)`
-	_, err := sr.db.Exec(sqlCommand, s.UUID, s.UserId)
+	_, err := sr.db.ExecContext(ctx, sqlCommand, s.UUID, s.UserId)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return err
 	}
 	return nil
 }
 
-func (sr sessionRepository) Exists(sess domain.Session) error {
+func (sr sessionRepository) Exists(ctx context.Context, sess domain.Session) error {
 	s := sr.domainToModel(sess)
 	sqlCommand := `SELECT * FROM sessions WHERE uuid = $1 AND user_id = $2`
-	rows, err := sr.db.Query(sqlCommand, s.UUID, sess.UserId)
+	rows, err := sr.db.QueryContext(ctx, sqlCommand, s.UUID, sess.UserId)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return err
 	}
 	defer rows.Close()
 	if !rows.Next() {
-		logger.Logger.Error("session does not exist")
+		logger.FromContext(ctx).Error("session does not exist")
 		return errors.New("session does not exist")
 	}
 	return nil
 }
 
-func (sr sessionRepository) Delete(sess domain.Session) error {
+func (sr sessionRepository) Delete(ctx context.Context, sess domain.Session) error {
 	s := sr.domainToModel(sess)
 	sqlCommand := `DELETE FROM sessions WHERE uuid = $1 AND user_id = $2`
-	_, err := sr.db.Exec(sqlCommand, s.UUID, sess.UserId)
+	_, err := sr.db.ExecContext(ctx, sqlCommand, s.UUID, sess.UserId)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return err
 	}
 	return nil
 }
 
+func (sr sessionRepository) DeleteByUserId(ctx context.Context, userId uint64) (int64, error) {
+	sqlCommand := `DELETE FROM sessions WHERE user_id = $1`
+	res, err := sr.db.ExecContext(ctx, sqlCommand, userId)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
 func (sr sessionRepository) domainToModel(sess domain.Session) session {
 	return session{
 		UserId: sess.UserId,
@@ -1,29 +1,44 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"go-rest-api/internal/domain"
 	"go-rest-api/internal/infra/logger"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 type UserRepository interface {
-	FindById(id uint64) (domain.User, error)
-	FindByEmail(email string) (domain.User, error)
-	FindByEmailConfirmationToken(confToken string) (domain.User, error)
-	Save(user domain.User) (domain.User, error)
-	UpdateUserAvatar(user domain.User) (domain.User, error)
-	ConfirmUserEmail(user domain.User) error
-	Delete(id uint64) error
+	FindById(ctx context.Context, id uint64) (domain.User, error)
+	FindByEmail(ctx context.Context, email string) (domain.User, error)
+	FindByEmailConfirmationToken(ctx context.Context, confToken string) (domain.User, error)
+	Save(ctx context.Context, user domain.User) (domain.User, error)
+	UpdateUserAvatar(ctx context.Context, user domain.User) (domain.User, error)
+	ConfirmUserEmail(ctx context.Context, user domain.User) error
+	UpdatePassword(ctx context.Context, id uint64, passwordHash string) error
+	Disable(ctx context.Context, id uint64) (domain.User, error)
+	Delete(ctx context.Context, id uint64) error
 }
 
+const userColumns = `id, name, email, avatar, avatar_sizes, avatar_keys, password, email_confirmed, email_confirmation_token, disabled_at`
+
 type user struct {
-	Id                     uint64  `db:"id, omitempty"`
-	Name                   string  `db:"name"`
-	Email                  string  `db:"email"`
-	Avatar                 *string `db:"avatar"`
-	Password               string  `db:"password"`
-	EmailConfirmed         bool    `db:"email_confirmed"`
-	EmailConfirmationToken string  `db:"email_confirmation_token"`
+	Id                     uint64         `db:"id, omitempty"`
+	Name                   string         `db:"name"`
+	Email                  string         `db:"email"`
+	Avatar                 *string        `db:"avatar"`
+	AvatarSizes            avatarSizes    `db:"avatar_sizes"`
+	AvatarKeys             pq.StringArray `db:"avatar_keys"`
+	Password               string         `db:"password"`
+	EmailConfirmed         bool           `db:"email_confirmed"`
+	EmailConfirmationToken string         `db:"email_confirmation_token"`
+	DisabledAt             *time.Time     `db:"disabled_at"`
 }
 
 type userRepository struct {
@@ -36,110 +51,202 @@ func NewUserRepository(db *sql.DB) UserRepository {
 	}
 }
 
-func (ur userRepository) FindById(id uint64) (domain.User, error) {
+func (ur userRepository) FindById(ctx context.Context, id uint64) (domain.User, error) {
 	userModel := user{}
-	sqlCommand := `SELECT * FROM users WHERE id=$1`
-	err := ur.db.QueryRow(sqlCommand, id).Scan(
+	sqlCommand := `SELECT ` + userColumns + ` FROM users WHERE id=$1`
+	err := ur.db.QueryRowContext(ctx, sqlCommand, id).Scan(
 		&userModel.Id,
 		&userModel.Name,
 		&userModel.Email,
 		&userModel.Avatar,
+		&userModel.AvatarSizes,
+		&userModel.AvatarKeys,
 		&userModel.Password,
 		&userModel.EmailConfirmed,
 		&userModel.EmailConfirmationToken,
+		&userModel.DisabledAt,
 	)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return domain.User{}, err
 	}
 
 	return ur.modelToDomain(userModel), nil
 }
 
-func (ur userRepository) FindByEmail(email string) (domain.User, error) {
+func (ur userRepository) FindByEmail(ctx context.Context, email string) (domain.User, error) {
 	userModel := user{}
-	sqlCommand := `SELECT * FROM users WHERE email=$1`
+	sqlCommand := `SELECT ` + userColumns + ` FROM users WHERE email=$1`
 
-	err := ur.db.QueryRow(sqlCommand, email).Scan(
+	err := ur.db.QueryRowContext(ctx, sqlCommand, email).Scan(
 		&userModel.Id,
 		&userModel.Name,
 		&userModel.Email,
 		&userModel.Avatar,
+		&userModel.AvatarSizes,
+		&userModel.AvatarKeys,
 		&userModel.Password,
 		&userModel.EmailConfirmed,
 		&userModel.EmailConfirmationToken,
+		&userModel.DisabledAt,
 	)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return domain.User{}, err
 	}
 
 	return ur.modelToDomain(userModel), nil
 }
 
-func (ur userRepository) FindByEmailConfirmationToken(confToken string) (domain.User, error) {
-	sqlCommand := `SELECT * FROM users WHERE email_confirmation_token=$1 AND email_confirmed=false`
+func (ur userRepository) FindByEmailConfirmationToken(ctx context.Context, confToken string) (domain.User, error) {
+	sqlCommand := `SELECT ` + userColumns + ` FROM users WHERE email_confirmation_token=$1 AND email_confirmed=false`
 	userModel := user{}
 
-	err := ur.db.QueryRow(sqlCommand, confToken).Scan(
+	err := ur.db.QueryRowContext(ctx, sqlCommand, confToken).Scan(
 		&userModel.Id,
 		&userModel.Name,
 		&userModel.Email,
 		&userModel.Avatar,
+		&userModel.AvatarSizes,
+		&userModel.AvatarKeys,
 		&userModel.Password,
 		&userModel.EmailConfirmed,
 		&userModel.EmailConfirmationToken,
+		&userModel.DisabledAt,
 	)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return domain.User{}, err
 	}
 
 	return ur.modelToDomain(userModel), nil
 }
 
-func (ur userRepository) Save(user domain.User) (domain.User, error) {
+func (ur userRepository) Save(ctx context.Context, user domain.User) (domain.User, error) {
 	userModel := ur.domainToModel(user)
 	sqlCommand := `INSERT INTO users (name, email, password, email_confirmation_token) VALUES ($1, $2, $3, $4) RETURNING id`
 
-	err := ur.db.QueryRow(sqlCommand, userModel.Name, userModel.Email, userModel.Password, userModel.EmailConfirmationToken).Scan(&userModel.Id)
+	err := ur.db.QueryRowContext(ctx, sqlCommand, userModel.Name, userModel.Email, userModel.Password, userModel.EmailConfirmationToken).Scan(&userModel.Id)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return domain.User{}, err
 	}
 	return ur.modelToDomain(userModel), nil
 }
 
-func (ur userRepository) UpdateUserAvatar(user domain.User) (domain.User, error) {
+func (ur userRepository) UpdateUserAvatar(ctx context.Context, user domain.User) (domain.User, error) {
 	userModel := ur.domainToModel(user)
-	sqlCommand := `UPDATE users SET avatar=$1 WHERE id=$2`
+	sqlCommand := `UPDATE users SET avatar=$1, avatar_sizes=$2, avatar_keys=$3 WHERE id=$4`
 
-	_, err := ur.db.Exec(sqlCommand, userModel.Avatar, userModel.Id)
+	_, err := ur.db.ExecContext(ctx, sqlCommand, userModel.Avatar, userModel.AvatarSizes, userModel.AvatarKeys, userModel.Id)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return domain.User{}, err
 	}
 
 	return ur.modelToDomain(userModel), nil
 }
 
-func (ur userRepository) ConfirmUserEmail(user domain.User) error {
+func (ur userRepository) ConfirmUserEmail(ctx context.Context, user domain.User) error {
 	userModel := ur.domainToModel(user)
 	sqlCommand := `UPDATE users SET email_confirmed=true WHERE id=$1 AND email_confirmation_token=$2`
 
-	_, err := ur.db.Exec(sqlCommand, userModel.Id, userModel.EmailConfirmationToken)
+	_, err := ur.db.ExecContext(ctx, sqlCommand, userModel.Id, userModel.EmailConfirmationToken)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
 		return err
 	}
 	return nil
 }
 
-func (ur userRepository) Delete(id uint64) error {
-	sqlCommand := `DELETE FROM users WHERE id=$1`
-	_, err := ur.db.Exec(sqlCommand, id)
+func (ur userRepository) UpdatePassword(ctx context.Context, id uint64, passwordHash string) error {
+	sqlCommand := `UPDATE users SET password=$1 WHERE id=$2`
+	res, err := ur.db.ExecContext(ctx, sqlCommand, passwordHash, id)
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(ctx).Error(err)
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (ur userRepository) Disable(ctx context.Context, id uint64) (domain.User, error) {
+	userModel := user{}
+	sqlCommand := `UPDATE users SET disabled_at=COALESCE(disabled_at, now()) WHERE id=$1 RETURNING ` + userColumns
+
+	err := ur.db.QueryRowContext(ctx, sqlCommand, id).Scan(
+		&userModel.Id,
+		&userModel.Name,
+		&userModel.Email,
+		&userModel.Avatar,
+		&userModel.AvatarSizes,
+		&userModel.AvatarKeys,
+		&userModel.Password,
+		&userModel.EmailConfirmed,
+		&userModel.EmailConfirmationToken,
+		&userModel.DisabledAt,
+	)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return domain.User{}, err
+	}
+
+	return ur.modelToDomain(userModel), nil
+}
+
+// Delete deletes a user and, through the foreign keys, their projects and
+// the attachments they uploaded or that were attached to their projects.
+// The size of those attachments is taken off the storage used by the
+// projects and users that remain.
+func (ur userRepository) Delete(ctx context.Context, id uint64) error {
+	tx, err := ur.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	// Locking the user keeps them from uploading meanwhile.
+	sqlCommand := `SELECT id FROM users WHERE id=$1 FOR UPDATE`
+	err = tx.QueryRowContext(ctx, sqlCommand, id).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return err
+	}
+
+	sqlCommand = `UPDATE projects SET storage_used = projects.storage_used - s.total
+		FROM (SELECT project_id, sum(size) AS total FROM attachments WHERE uploader_id = $1 GROUP BY project_id) s
+		WHERE projects.id = s.project_id AND projects.creator_id <> $1`
+	_, err = tx.ExecContext(ctx, sqlCommand, id)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return err
+	}
+	sqlCommand = `UPDATE users SET storage_used = users.storage_used - s.total
+		FROM (SELECT a.uploader_id, sum(a.size) AS total FROM attachments a JOIN projects p ON p.id = a.project_id
+			WHERE p.creator_id = $1 AND a.uploader_id <> $1 GROUP BY a.uploader_id) s
+		WHERE users.id = s.uploader_id`
+	_, err = tx.ExecContext(ctx, sqlCommand, id)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return err
+	}
+
+	sqlCommand = `DELETE FROM users WHERE id=$1`
+	_, err = tx.ExecContext(ctx, sqlCommand, id)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
 		return err
 	}
 	return nil
@@ -151,9 +258,12 @@ func (ur userRepository) modelToDomain(u user) domain.User {
 		Name:                   u.Name,
 		Email:                  u.Email,
 		Avatar:                 u.Avatar,
+		AvatarSizes:            u.AvatarSizes,
+		AvatarKeys:             u.AvatarKeys,
 		Password:               u.Password,
 		EmailConfirmed:         u.EmailConfirmed,
 		EmailConfirmationToken: u.EmailConfirmationToken,
+		DisabledAt:             u.DisabledAt,
 	}
 }
 
@@ -163,8 +273,34 @@ func (ur userRepository) domainToModel(u domain.User) user {
 		Name:                   u.Name,
 		Email:                  u.Email,
 		Avatar:                 u.Avatar,
+		AvatarSizes:            u.AvatarSizes,
+		AvatarKeys:             u.AvatarKeys,
 		Password:               u.Password,
 		EmailConfirmed:         u.EmailConfirmed,
 		EmailConfirmationToken: u.EmailConfirmationToken,
+		DisabledAt:             u.DisabledAt,
+	}
+}
+
+// avatarSizes is stored as a JSON object of URLs keyed by size.
+type avatarSizes map[int]string
+
+func (a *avatarSizes) Scan(src any) error {
+	*a = nil
+	switch src := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(src, a)
+	case string:
+		return json.Unmarshal([]byte(src), a)
+	}
+	return fmt.Errorf("cannot scan %T into avatar sizes", src)
+}
+
+func (a avatarSizes) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
 	}
+	return json.Marshal(a)
 }
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-rest-api/internal/infra/logger"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// syncSearchLanguage rebuilds the generated search column of projects when
+// it was built with another text search configuration than language, the
+// one queries are parsed with. Migrations build it with english; SQL files
+// cannot read the configuration, so the rebuild runs after them, under the
+// same lock.
+func syncSearchLanguage(ctx context.Context, conn *sql.Conn, language string) error {
+	var expression string
+	sqlCommand := `SELECT generation_expression FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'projects' AND column_name = 'search_vector'`
+	err := conn.QueryRowContext(ctx, sqlCommand).Scan(&expression)
+	if errors.Is(err, sql.ErrNoRows) {
+		// MIGRATE pins a version from before search.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var configuration string
+	err = conn.QueryRowContext(ctx, `SELECT $1::regconfig::text`, language).Scan(&configuration)
+	if err != nil {
+		return fmt.Errorf("search language %q: %w", language, err)
+	}
+	if strings.Contains(expression, "to_tsvector("+pq.QuoteLiteral(configuration)+"::regconfig") {
+		return nil
+	}
+
+	logger.Logger.Infof("Migrate: rebuilding the project search index with the %s configuration", configuration)
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	quoted := pq.QuoteLiteral(configuration)
+	for _, sqlCommand := range []string{
+		`DROP INDEX IF EXISTS projects_search_vector_idx`,
+		`ALTER TABLE projects DROP COLUMN search_vector`,
+		`ALTER TABLE projects ADD COLUMN search_vector tsvector
+			GENERATED ALWAYS AS (
+				setweight(to_tsvector(` + quoted + `, coalesce(title, '')), 'A') ||
+				setweight(to_tsvector(` + quoted + `, coalesce(description, '')), 'B')
+			) STORED`,
+		`CREATE INDEX projects_search_vector_idx ON projects USING GIN (search_vector)`,
+	} {
+		if _, err = tx.ExecContext(ctx, sqlCommand); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
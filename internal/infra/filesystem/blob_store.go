@@ -0,0 +1,88 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-rest-api/config"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	ErrBlobNotFound   = errors.New("blob not found")
+	ErrInvalidBlobKey = errors.New("invalid blob key")
+)
+
+// BlobInfo describes a stored blob.
+type BlobInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModifiedAt  time.Time
+	// URL is where the blob is publicly served from.
+	URL string
+}
+
+// BlobStore keeps binary objects under slash separated keys such as
+// "avatars/42/1f2e3d.png".
+type BlobStore interface {
+	// Put stores the content of r under key, replacing any blob with the
+	// same key. size is -1 when unknown.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (BlobInfo, error)
+	// Get opens the blob stored under key. The caller closes the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, BlobInfo, error)
+	// Delete removes the blob stored under key. Deleting a missing blob is
+	// not an error.
+	Delete(ctx context.Context, key string) error
+	Stat(ctx context.Context, key string) (BlobInfo, error)
+	// List returns the blobs whose keys start with prefix, ordered by key.
+	List(ctx context.Context, prefix string) ([]BlobInfo, error)
+	// URL returns where the blob stored under key is publicly served from.
+	URL(key string) string
+	// SignedURL returns a URL giving read access to the blob for ttl, which
+	// stops working once the blob is deleted. Browsers save the blob as
+	// filename when it is not empty.
+	SignedURL(ctx context.Context, key string, ttl time.Duration, filename string) (string, error)
+	Ping(ctx context.Context) error
+}
+
+// NewBlobStore creates the blob store selected by the configuration.
+func NewBlobStore(cfg config.Configuration) (BlobStore, error) {
+	switch cfg.Storage() {
+	case "local":
+		return NewLocalBlobStore(cfg.StorageLocalPath, cfg.StoragePublicUrl, NewURLSigner(cfg.SigningKey(), SignedPath))
+	case "s3":
+		return NewS3BlobStore(cfg)
+	case "cloudinary":
+		return NewCloudinaryBlobStore(cfg)
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", cfg.Storage())
+}
+
+// checkKey rejects keys that are empty, absolute or could escape a
+// directory.
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.HasSuffix(key, "/") ||
+		path.Clean(key) != key || key == ".." || strings.HasPrefix(key, "../") ||
+		strings.ContainsAny(key, "\\\x00") {
+		return fmt.Errorf("%w: %q", ErrInvalidBlobKey, key)
+	}
+	return nil
+}
+
+// contentTypeByKey guesses the content type of a blob from its extension,
+// for backends that do not store it.
+func contentTypeByKey(key string) string {
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+func joinURL(base, key string) string {
+	return strings.TrimSuffix(base, "/") + "/" + key
+}
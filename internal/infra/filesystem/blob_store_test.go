@@ -0,0 +1,316 @@
+package filesystem_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go-rest-api/config"
+	"go-rest-api/internal/infra/filesystem"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalBlobStore(t *testing.T) {
+	runBlobStoreContract(t, func(t *testing.T) filesystem.BlobStore {
+		store, err := filesystem.NewLocalBlobStore(t.TempDir(), "/files", filesystem.NewURLSigner("secret", filesystem.SignedPath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
+
+// TestS3BlobStore runs against an in-process S3 stand-in, or against the
+// MinIO or S3 bucket named by TEST_S3_ENDPOINT, TEST_S3_BUCKET,
+// TEST_S3_ACCESS_KEY, TEST_S3_SECRET_KEY and TEST_S3_USE_SSL. That bucket
+// is emptied.
+func TestS3BlobStore(t *testing.T) {
+	runBlobStoreContract(t, func(t *testing.T) filesystem.BlobStore {
+		cfg := config.Configuration{
+			S3Region:         "us-east-1",
+			StoragePublicUrl: "https://cdn.example.com/files",
+		}
+		if endpoint := os.Getenv("TEST_S3_ENDPOINT"); endpoint != "" {
+			cfg.S3Endpoint = endpoint
+			cfg.S3Bucket = os.Getenv("TEST_S3_BUCKET")
+			cfg.S3AccessKey = os.Getenv("TEST_S3_ACCESS_KEY")
+			cfg.S3SecretKey = os.Getenv("TEST_S3_SECRET_KEY")
+			cfg.S3UseSsl = os.Getenv("TEST_S3_USE_SSL") != "false"
+		} else {
+			server := newS3StandIn(t, "blobs")
+			cfg.S3Endpoint = server.Listener.Addr().String()
+			cfg.S3Bucket = "blobs"
+			cfg.S3AccessKey = "access"
+			cfg.S3SecretKey = "secret-key"
+		}
+
+		store, err := filesystem.NewS3BlobStore(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		blobs, err := store.List(context.Background(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, blob := range blobs {
+			if err = store.Delete(context.Background(), blob.Key); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return store
+	})
+}
+
+func runBlobStoreContract(t *testing.T, newStore func(t *testing.T) filesystem.BlobStore) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, store filesystem.BlobStore)
+	}{
+		{"PutAndGet", testBlobPutAndGet},
+		{"Overwrite", testBlobOverwrite},
+		{"Missing", testBlobMissing},
+		{"Delete", testBlobDelete},
+		{"List", testBlobList},
+		{"InvalidKeys", testBlobInvalidKeys},
+		{"Ping", testBlobPing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newStore(t))
+		})
+	}
+}
+
+func putBlob(t *testing.T, store filesystem.BlobStore, key, content string) filesystem.BlobInfo {
+	t.Helper()
+	info, err := store.Put(context.Background(), key, strings.NewReader(content), int64(len(content)), "")
+	if err != nil {
+		t.Fatalf("put %s: %v", key, err)
+	}
+	return info
+}
+
+func readBlob(t *testing.T, store filesystem.BlobStore, key string) (string, filesystem.BlobInfo) {
+	t.Helper()
+	blob, info, err := store.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("get %s: %v", key, err)
+	}
+	defer blob.Close()
+	content, err := io.ReadAll(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content), info
+}
+
+func testBlobPutAndGet(t *testing.T, store filesystem.BlobStore) {
+	info := putBlob(t, store, "avatars/1/abc.png", "png bytes")
+	if info.Key != "avatars/1/abc.png" || info.Size != 9 {
+		t.Errorf("put info = %+v", info)
+	}
+	if info.URL != store.URL("avatars/1/abc.png") || !strings.HasSuffix(info.URL, "/avatars/1/abc.png") {
+		t.Errorf("URL = %q, want it to end with the key", info.URL)
+	}
+
+	content, info := readBlob(t, store, "avatars/1/abc.png")
+	if content != "png bytes" {
+		t.Errorf("content = %q", content)
+	}
+	if info.Size != 9 || info.ContentType != "image/png" || info.ModifiedAt.IsZero() {
+		t.Errorf("get info = %+v", info)
+	}
+
+	stat, err := store.Stat(context.Background(), "avatars/1/abc.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Key != "avatars/1/abc.png" || stat.Size != 9 || stat.ContentType != "image/png" {
+		t.Errorf("stat = %+v", stat)
+	}
+
+	// The size may be unknown.
+	large := bytes.Repeat([]byte("0123456789"), 10_000)
+	if _, err = store.Put(context.Background(), "attachments/large.bin", bytes.NewReader(large), -1, "application/octet-stream"); err != nil {
+		t.Fatal(err)
+	}
+	content, _ = readBlob(t, store, "attachments/large.bin")
+	if content != string(large) {
+		t.Errorf("large blob has %d bytes, want %d", len(content), len(large))
+	}
+}
+
+func testBlobOverwrite(t *testing.T, store filesystem.BlobStore) {
+	putBlob(t, store, "notes/a.txt", "first")
+	putBlob(t, store, "notes/a.txt", "second version")
+
+	content, info := readBlob(t, store, "notes/a.txt")
+	if content != "second version" || info.Size != int64(len("second version")) {
+		t.Errorf("content = %q, size %d", content, info.Size)
+	}
+}
+
+func testBlobMissing(t *testing.T, store filesystem.BlobStore) {
+	if _, _, err := store.Get(context.Background(), "missing/key.png"); !errors.Is(err, filesystem.ErrBlobNotFound) {
+		t.Errorf("get error = %v, want ErrBlobNotFound", err)
+	}
+	if _, err := store.Stat(context.Background(), "missing/key.png"); !errors.Is(err, filesystem.ErrBlobNotFound) {
+		t.Errorf("stat error = %v, want ErrBlobNotFound", err)
+	}
+}
+
+func testBlobDelete(t *testing.T, store filesystem.BlobStore) {
+	putBlob(t, store, "notes/a.txt", "a")
+	putBlob(t, store, "notes/b.txt", "b")
+
+	if err := store.Delete(context.Background(), "notes/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Stat(context.Background(), "notes/a.txt"); !errors.Is(err, filesystem.ErrBlobNotFound) {
+		t.Errorf("stat after delete = %v, want ErrBlobNotFound", err)
+	}
+	if content, _ := readBlob(t, store, "notes/b.txt"); content != "b" {
+		t.Errorf("other blob = %q", content)
+	}
+	if err := store.Delete(context.Background(), "notes/a.txt"); err != nil {
+		t.Errorf("deleting a missing blob: %v", err)
+	}
+}
+
+func testBlobList(t *testing.T, store filesystem.BlobStore) {
+	for _, key := range []string{"avatars/2/b.png", "avatars/10/a.png", "avatars/1/c.png", "attachments/1/doc.pdf"} {
+		putBlob(t, store, key, key)
+	}
+
+	blobs, err := store.List(context.Background(), "avatars/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, blob := range blobs {
+		keys = append(keys, blob.Key)
+		if blob.Size != int64(len(blob.Key)) {
+			t.Errorf("%s: size = %d", blob.Key, blob.Size)
+		}
+	}
+	if strings.Join(keys, ",") != "avatars/1/c.png,avatars/10/a.png" {
+		t.Errorf("keys = %v", keys)
+	}
+
+	blobs, err = store.List(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 4 || blobs[0].Key != "attachments/1/doc.pdf" {
+		t.Errorf("all blobs = %+v", blobs)
+	}
+
+	blobs, err = store.List(context.Background(), "nothing/")
+	if err != nil || len(blobs) != 0 {
+		t.Errorf("unknown prefix = %v, %v", blobs, err)
+	}
+}
+
+func testBlobInvalidKeys(t *testing.T, store filesystem.BlobStore) {
+	for _, key := range []string{"", "/etc/passwd", "../outside", "a/../../b", "a//b", "dir/", "a\\b"} {
+		_, err := store.Put(context.Background(), key, strings.NewReader("x"), 1, "")
+		if !errors.Is(err, filesystem.ErrInvalidBlobKey) {
+			t.Errorf("put %q: error = %v, want ErrInvalidBlobKey", key, err)
+		}
+	}
+}
+
+func testBlobPing(t *testing.T, store filesystem.BlobStore) {
+	if err := store.Ping(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestS3SignedURL(t *testing.T) {
+	server := newS3StandIn(t, "blobs")
+	store, err := filesystem.NewS3BlobStore(config.Configuration{
+		S3Endpoint:  server.Listener.Addr().String(),
+		S3Region:    "us-east-1",
+		S3Bucket:    "blobs",
+		S3AccessKey: "access",
+		S3SecretKey: "secret-key",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	putBlob(t, store, "attachments/1/spec.pdf", "%PDF")
+
+	signed, err := store.SignedURL(context.Background(), "attachments/1/spec.pdf", 5*time.Minute, "spec.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	if parsed.Path != "/blobs/attachments/1/spec.pdf" || query.Get("X-Amz-Expires") != "300" || query.Get("X-Amz-Signature") == "" ||
+		query.Get("response-content-disposition") != `attachment; filename=spec.pdf` {
+		t.Errorf("signed URL = %s", signed)
+	}
+
+	resp, err := http.Get(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "%PDF" {
+		t.Errorf("GET signed URL = %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestLocalSignedURL(t *testing.T) {
+	signer := filesystem.NewURLSigner("secret", filesystem.SignedPath)
+	store, err := filesystem.NewLocalBlobStore(t.TempDir(), "/files", signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	signed, err := store.SignedURL(ctx, "attachments/1/spec.pdf", time.Minute, "spec.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Path != "/files/signed/attachments/1/spec.pdf" || parsed.Query().Get("filename") != "spec.pdf" {
+		t.Fatalf("signed URL = %s", signed)
+	}
+	now := time.Now()
+	if err = signer.Verify("attachments/1/spec.pdf", parsed.Query(), now); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if err = signer.Verify("attachments/2/spec.pdf", parsed.Query(), now); !errors.Is(err, filesystem.ErrInvalidSignature) {
+		t.Errorf("verifying another key: %v, want ErrInvalidSignature", err)
+	}
+	if err = filesystem.NewURLSigner("other", filesystem.SignedPath).Verify("attachments/1/spec.pdf", parsed.Query(), now); !errors.Is(err, filesystem.ErrInvalidSignature) {
+		t.Errorf("verifying with another secret: %v, want ErrInvalidSignature", err)
+	}
+	for name, value := range map[string]string{"filename": "spec.html", "expires": "4102444800", "signature": "zz"} {
+		query := parsed.Query()
+		query.Set(name, value)
+		if err = signer.Verify("attachments/1/spec.pdf", query, now); !errors.Is(err, filesystem.ErrInvalidSignature) {
+			t.Errorf("verifying with %s changed: %v, want ErrInvalidSignature", name, err)
+		}
+	}
+	if err = signer.Verify("attachments/1/spec.pdf", parsed.Query(), now.Add(time.Minute)); !errors.Is(err, filesystem.ErrSignedURLExpired) {
+		t.Errorf("verifying after a minute: %v, want ErrSignedURLExpired", err)
+	}
+
+	if _, err = store.SignedURL(ctx, "../spec.pdf", time.Minute, ""); !errors.Is(err, filesystem.ErrInvalidBlobKey) {
+		t.Errorf("signing an invalid key: %v, want ErrInvalidBlobKey", err)
+	}
+}
@@ -0,0 +1,245 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-rest-api/config"
+	"go-rest-api/internal/infra/logger"
+	"go-rest-api/internal/infra/metrics"
+	"go-rest-api/internal/infra/tracing"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudinary/cloudinary-go/v2"
+	"github.com/cloudinary/cloudinary-go/v2/api"
+	"github.com/cloudinary/cloudinary-go/v2/api/admin"
+	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Blobs are uploaded as raw files, so that Cloudinary serves them unchanged
+// and their public id is exactly the key.
+const cloudinaryResourceType = "raw"
+
+type cloudinaryBlobStore struct {
+	cloudinaryObject *cloudinary.Cloudinary
+	httpClient       *http.Client
+	signer           URLSigner
+}
+
+func NewCloudinaryBlobStore(cfg config.Configuration) (BlobStore, error) {
+	cloudinaryObject, err := cloudinary.NewFromParams(cfg.CloudinaryNameKey, cfg.CloudinaryApiKey, cfg.CloudinarySecretKey)
+	if err != nil {
+		return nil, err
+	}
+	return cloudinaryBlobStore{
+		cloudinaryObject: cloudinaryObject,
+		httpClient:       &http.Client{Timeout: time.Minute},
+		signer:           NewURLSigner(cfg.SigningKey(), SignedPath),
+	}, nil
+}
+
+func (c cloudinaryBlobStore) Put(ctx context.Context, key string, r io.Reader, _ int64, _ string) (BlobInfo, error) {
+	if err := checkKey(key); err != nil {
+		return BlobInfo{}, err
+	}
+
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "cloudinary.Upload", trace.WithSpanKind(trace.SpanKindClient))
+	overwrite := true
+	result, err := c.cloudinaryObject.Upload.Upload(ctx, r, uploader.UploadParams{
+		PublicID:     key,
+		ResourceType: cloudinaryResourceType,
+		Overwrite:    &overwrite,
+	})
+	if err == nil {
+		err = cloudinaryError(result.Error)
+	}
+	tracing.End(span, err)
+	metrics.ObserveUpload("cloudinary", start, err)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return BlobInfo{}, err
+	}
+
+	return BlobInfo{
+		Key:         key,
+		Size:        int64(result.Bytes),
+		ContentType: contentTypeByKey(key),
+		ModifiedAt:  result.CreatedAt,
+		URL:         c.URL(key),
+	}, nil
+}
+
+func (c cloudinaryBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, BlobInfo, error) {
+	info, err := c.Stat(ctx, key)
+	if err != nil {
+		return nil, BlobInfo{}, err
+	}
+
+	ctx, span := tracing.Start(ctx, "cloudinary.Download", trace.WithSpanKind(trace.SpanKindClient))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, info.URL, nil)
+	if err != nil {
+		tracing.End(span, err)
+		return nil, BlobInfo{}, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err == nil && resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		err = fmt.Errorf("downloading %s: %s", key, resp.Status)
+		if resp.StatusCode == http.StatusNotFound {
+			err = ErrBlobNotFound
+		}
+	}
+	tracing.End(span, err)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return nil, BlobInfo{}, err
+	}
+	return resp.Body, info, nil
+}
+
+func (c cloudinaryBlobStore) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+
+	ctx, span := tracing.Start(ctx, "cloudinary.Destroy", trace.WithSpanKind(trace.SpanKindClient))
+	result, err := c.cloudinaryObject.Upload.Destroy(ctx, uploader.DestroyParams{
+		PublicID:     key,
+		ResourceType: cloudinaryResourceType,
+	})
+	if err == nil {
+		err = cloudinaryError(result.Error)
+	}
+	tracing.End(span, err)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return err
+	}
+	// The result is "not found" for missing blobs, which is fine.
+	return nil
+}
+
+func (c cloudinaryBlobStore) Stat(ctx context.Context, key string) (BlobInfo, error) {
+	if err := checkKey(key); err != nil {
+		return BlobInfo{}, err
+	}
+
+	ctx, span := tracing.Start(ctx, "cloudinary.Asset", trace.WithSpanKind(trace.SpanKindClient))
+	result, err := c.cloudinaryObject.Admin.Asset(ctx, admin.AssetParams{
+		AssetType:    cloudinaryResourceType,
+		DeliveryType: "upload",
+		PublicID:     key,
+	})
+	if err == nil {
+		err = cloudinaryError(result.Error)
+	}
+	tracing.End(span, err)
+	if err != nil {
+		if err != ErrBlobNotFound {
+			logger.FromContext(ctx).Error(err)
+		}
+		return BlobInfo{}, err
+	}
+
+	return BlobInfo{
+		Key:         key,
+		Size:        int64(result.Bytes),
+		ContentType: contentTypeByKey(key),
+		ModifiedAt:  result.CreatedAt,
+		URL:         result.SecureURL,
+	}, nil
+}
+
+func (c cloudinaryBlobStore) List(ctx context.Context, prefix string) ([]BlobInfo, error) {
+	ctx, span := tracing.Start(ctx, "cloudinary.Assets", trace.WithSpanKind(trace.SpanKindClient))
+	var (
+		blobs      []BlobInfo
+		nextCursor string
+		err        error
+	)
+	for {
+		var result *admin.AssetsResult
+		result, err = c.cloudinaryObject.Admin.Assets(ctx, admin.AssetsParams{
+			AssetType:    cloudinaryResourceType,
+			DeliveryType: "upload",
+			Prefix:       prefix,
+			MaxResults:   500,
+			NextCursor:   nextCursor,
+		})
+		if err == nil {
+			err = cloudinaryError(result.Error)
+		}
+		if err != nil {
+			break
+		}
+		for _, asset := range result.Assets {
+			blobs = append(blobs, BlobInfo{
+				Key:         asset.PublicID,
+				Size:        int64(asset.Bytes),
+				ContentType: contentTypeByKey(asset.PublicID),
+				ModifiedAt:  asset.CreatedAt,
+				URL:         asset.SecureURL,
+			})
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		nextCursor = result.NextCursor
+	}
+	tracing.End(span, err)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return nil, err
+	}
+
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].Key < blobs[j].Key })
+	return blobs, nil
+}
+
+func (c cloudinaryBlobStore) URL(key string) string {
+	file, err := c.cloudinaryObject.File(key)
+	if err != nil {
+		logger.Logger.Error(err)
+		return ""
+	}
+	url, err := file.String()
+	if err != nil {
+		logger.Logger.Error(err)
+		return ""
+	}
+	return url
+}
+
+// SignedURL returns a URL of the API, which serves the blob while the
+// signature is valid. Signed Cloudinary delivery URLs never expire, and
+// expiring ones need token based authentication.
+func (c cloudinaryBlobStore) SignedURL(_ context.Context, key string, ttl time.Duration, filename string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	return c.signer.Sign(key, time.Now().Add(ttl), filename), nil
+}
+
+// Ping checks that the Cloudinary credentials work and the API is reachable.
+func (c cloudinaryBlobStore) Ping(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "cloudinary.Ping", trace.WithSpanKind(trace.SpanKindClient))
+	_, err := c.cloudinaryObject.Admin.Ping(ctx)
+	tracing.End(span, err)
+	return err
+}
+
+func cloudinaryError(resp api.ErrorResp) error {
+	if resp.Message == "" {
+		return nil
+	}
+	if strings.Contains(strings.ToLower(resp.Message), "not found") {
+		return ErrBlobNotFound
+	}
+	return errors.New(resp.Message)
+}
@@ -0,0 +1,222 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-rest-api/internal/infra/logger"
+	"go-rest-api/internal/infra/metrics"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type localBlobStore struct {
+	root      string
+	publicUrl string
+	signer    URLSigner
+}
+
+// NewLocalBlobStore keeps blobs as files below root, creating it when
+// missing. Blobs are publicly served from publicUrl followed by their key
+// and privately by the URLs of signer.
+func NewLocalBlobStore(root string, publicUrl string, signer URLSigner) (BlobStore, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(absRoot, 0o755); err != nil {
+		return nil, err
+	}
+	return localBlobStore{root: absRoot, publicUrl: publicUrl, signer: signer}, nil
+}
+
+func (s localBlobStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (BlobInfo, error) {
+	start := time.Now()
+	info, err := s.put(key, r, size)
+	metrics.ObserveUpload("local", start, err)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return BlobInfo{}, err
+	}
+	return info, nil
+}
+
+// put writes to a temporary file next to the blob and renames it, so that
+// readers never see a partly written blob.
+func (s localBlobStore) put(key string, r io.Reader, size int64) (BlobInfo, error) {
+	location, err := s.path(key)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	if err = os.MkdirAll(filepath.Dir(location), 0o755); err != nil {
+		return BlobInfo{}, err
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(location), ".upload-*")
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	defer os.Remove(tempFile.Name())
+
+	written, err := io.Copy(tempFile, r)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	if size >= 0 && written != size {
+		return BlobInfo{}, fmt.Errorf("blob %s: wrote %d bytes, expected %d", key, written, size)
+	}
+	if err = os.Chmod(tempFile.Name(), 0o644); err != nil {
+		return BlobInfo{}, err
+	}
+	if err = os.Rename(tempFile.Name(), location); err != nil {
+		return BlobInfo{}, err
+	}
+
+	return s.stat(key)
+}
+
+func (s localBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, BlobInfo, error) {
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		return nil, BlobInfo{}, err
+	}
+	location, _ := s.path(key)
+	file, err := os.Open(location)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, BlobInfo{}, ErrBlobNotFound
+	}
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return nil, BlobInfo{}, err
+	}
+	return file, info, nil
+}
+
+func (s localBlobStore) Delete(ctx context.Context, key string) error {
+	location, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(location)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logger.FromContext(ctx).Error(err)
+		return err
+	}
+	return nil
+}
+
+func (s localBlobStore) Stat(ctx context.Context, key string) (BlobInfo, error) {
+	info, err := s.stat(key)
+	if err != nil && !errors.Is(err, ErrBlobNotFound) && !errors.Is(err, ErrInvalidBlobKey) {
+		logger.FromContext(ctx).Error(err)
+	}
+	return info, err
+}
+
+func (s localBlobStore) stat(key string) (BlobInfo, error) {
+	location, err := s.path(key)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	fileInfo, err := os.Stat(location)
+	if errors.Is(err, fs.ErrNotExist) {
+		return BlobInfo{}, ErrBlobNotFound
+	}
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	if !fileInfo.Mode().IsRegular() {
+		return BlobInfo{}, ErrBlobNotFound
+	}
+	return s.blobInfo(key, fileInfo), nil
+}
+
+func (s localBlobStore) List(ctx context.Context, prefix string) ([]BlobInfo, error) {
+	var blobs []BlobInfo
+	err := filepath.WalkDir(s.root, func(location string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(s.root, location)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relative)
+		if entry.IsDir() {
+			// Skip directories that cannot contain keys with the prefix.
+			if key != "." && !strings.HasPrefix(key+"/", prefix) && !strings.HasPrefix(prefix, key+"/") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".upload-") || !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		fileInfo, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		blobs = append(blobs, s.blobInfo(key, fileInfo))
+		return nil
+	})
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return nil, err
+	}
+
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].Key < blobs[j].Key })
+	return blobs, nil
+}
+
+func (s localBlobStore) URL(key string) string {
+	return joinURL(s.publicUrl, key)
+}
+
+// SignedURL returns a URL of the API, which serves the blob while the
+// signature is valid.
+func (s localBlobStore) SignedURL(_ context.Context, key string, ttl time.Duration, filename string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	return s.signer.Sign(key, time.Now().Add(ttl), filename), nil
+}
+
+// Ping checks that the storage directory is still there.
+func (s localBlobStore) Ping(_ context.Context) error {
+	fileInfo, err := os.Stat(s.root)
+	if err != nil {
+		return err
+	}
+	if !fileInfo.IsDir() {
+		return fmt.Errorf("%s is not a directory", s.root)
+	}
+	return nil
+}
+
+func (s localBlobStore) path(key string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+func (s localBlobStore) blobInfo(key string, fileInfo fs.FileInfo) BlobInfo {
+	return BlobInfo{
+		Key:         key,
+		Size:        fileInfo.Size(),
+		ContentType: contentTypeByKey(key),
+		ModifiedAt:  fileInfo.ModTime(),
+		URL:         s.URL(key),
+	}
+}
@@ -0,0 +1,210 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"go-rest-api/config"
+	"go-rest-api/internal/infra/logger"
+	"go-rest-api/internal/infra/metrics"
+	"go-rest-api/internal/infra/tracing"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrBucketNotFound = errors.New("bucket does not exist")
+
+type s3BlobStore struct {
+	client    *minio.Client
+	bucket    string
+	publicUrl string
+}
+
+// NewS3BlobStore keeps blobs in a bucket of an S3 compatible service such
+// as AWS S3 or MinIO. The bucket must exist.
+func NewS3BlobStore(cfg config.Configuration) (BlobStore, error) {
+	client, err := minio.New(cfg.S3Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.S3AccessKey, cfg.S3SecretKey, ""),
+		Secure: cfg.S3UseSsl,
+		Region: cfg.S3Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s3BlobStore{client: client, bucket: cfg.S3Bucket, publicUrl: cfg.StoragePublicUrl}, nil
+}
+
+func (s s3BlobStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (BlobInfo, error) {
+	if err := checkKey(key); err != nil {
+		return BlobInfo{}, err
+	}
+	if contentType == "" {
+		contentType = contentTypeByKey(key)
+	}
+
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "s3.PutObject", trace.WithSpanKind(trace.SpanKindClient))
+	uploadInfo, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
+	tracing.End(span, err)
+	metrics.ObserveUpload("s3", start, err)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return BlobInfo{}, err
+	}
+
+	return BlobInfo{
+		Key:         key,
+		Size:        uploadInfo.Size,
+		ContentType: contentType,
+		ModifiedAt:  uploadInfo.LastModified,
+		URL:         s.URL(key),
+	}, nil
+}
+
+func (s s3BlobStore) Get(ctx context.Context, key string) (io.ReadCloser, BlobInfo, error) {
+	if err := checkKey(key); err != nil {
+		return nil, BlobInfo{}, err
+	}
+
+	ctx, span := tracing.Start(ctx, "s3.GetObject", trace.WithSpanKind(trace.SpanKindClient))
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	var objectInfo minio.ObjectInfo
+	if err == nil {
+		// GetObject is lazy, Stat makes the request and reports missing keys.
+		objectInfo, err = object.Stat()
+	}
+	err = s.mapError(err)
+	tracing.End(span, err)
+	if err != nil {
+		if object != nil {
+			object.Close()
+		}
+		if err != ErrBlobNotFound {
+			logger.FromContext(ctx).Error(err)
+		}
+		return nil, BlobInfo{}, err
+	}
+
+	return object, s.blobInfo(objectInfo), nil
+}
+
+func (s s3BlobStore) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+
+	ctx, span := tracing.Start(ctx, "s3.RemoveObject", trace.WithSpanKind(trace.SpanKindClient))
+	// S3 answers deletes of missing keys with success.
+	err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+	tracing.End(span, err)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return err
+	}
+	return nil
+}
+
+func (s s3BlobStore) Stat(ctx context.Context, key string) (BlobInfo, error) {
+	if err := checkKey(key); err != nil {
+		return BlobInfo{}, err
+	}
+
+	ctx, span := tracing.Start(ctx, "s3.StatObject", trace.WithSpanKind(trace.SpanKindClient))
+	objectInfo, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	err = s.mapError(err)
+	tracing.End(span, err)
+	if err != nil {
+		if err != ErrBlobNotFound {
+			logger.FromContext(ctx).Error(err)
+		}
+		return BlobInfo{}, err
+	}
+	return s.blobInfo(objectInfo), nil
+}
+
+func (s s3BlobStore) List(ctx context.Context, prefix string) ([]BlobInfo, error) {
+	ctx, span := tracing.Start(ctx, "s3.ListObjects", trace.WithSpanKind(trace.SpanKindClient))
+	var (
+		blobs []BlobInfo
+		err   error
+	)
+	// Objects are listed in key order.
+	for objectInfo := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if objectInfo.Err != nil {
+			err = objectInfo.Err
+			break
+		}
+		blobs = append(blobs, s.blobInfo(objectInfo))
+	}
+	tracing.End(span, err)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return nil, err
+	}
+	return blobs, nil
+}
+
+func (s s3BlobStore) URL(key string) string {
+	return joinURL(s.publicUrl, key)
+}
+
+// SignedURL returns a presigned GET URL of the object, valid for ttl.
+func (s s3BlobStore) SignedURL(ctx context.Context, key string, ttl time.Duration, filename string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	var params url.Values
+	if filename != "" {
+		params = url.Values{"response-content-disposition": {
+			mime.FormatMediaType("attachment", map[string]string{"filename": filename}),
+		}}
+	}
+	signedUrl, err := s.client.PresignedGetObject(ctx, s.bucket, key, ttl, params)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return "", err
+	}
+	return signedUrl.String(), nil
+}
+
+// Ping checks that the credentials work and the bucket exists.
+func (s s3BlobStore) Ping(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "s3.BucketExists", trace.WithSpanKind(trace.SpanKindClient))
+	exists, err := s.client.BucketExists(ctx, s.bucket)
+	if err == nil && !exists {
+		err = ErrBucketNotFound
+	}
+	tracing.End(span, err)
+	return err
+}
+
+func (s s3BlobStore) mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	response := minio.ToErrorResponse(err)
+	if response.Code == "NoSuchKey" || response.StatusCode == http.StatusNotFound && response.Code != "NoSuchBucket" {
+		return ErrBlobNotFound
+	}
+	return err
+}
+
+func (s s3BlobStore) blobInfo(objectInfo minio.ObjectInfo) BlobInfo {
+	contentType := objectInfo.ContentType
+	if contentType == "" {
+		contentType = contentTypeByKey(objectInfo.Key)
+	}
+	return BlobInfo{
+		Key:         objectInfo.Key,
+		Size:        objectInfo.Size,
+		ContentType: contentType,
+		ModifiedAt:  objectInfo.LastModified,
+		URL:         s.URL(objectInfo.Key),
+	}
+}
@@ -0,0 +1,76 @@
+package filesystem
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// SignedPath is where the API serves blobs by the URLs of URLSigner.
+const SignedPath = "/files/signed"
+
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrSignedURLExpired = errors.New("signed URL has expired")
+)
+
+// URLSigner signs URLs under which the API serves blobs itself, for the
+// backends that cannot sign their own.
+type URLSigner struct {
+	secret  []byte
+	baseUrl string
+}
+
+func NewURLSigner(secret string, baseUrl string) URLSigner {
+	return URLSigner{secret: []byte(secret), baseUrl: baseUrl}
+}
+
+// Sign returns the URL of the blob stored under key, valid until expires.
+// The blob is downloaded as filename when it is not empty.
+func (s URLSigner) Sign(key string, expires time.Time, filename string) string {
+	query := url.Values{"expires": {strconv.FormatInt(expires.Unix(), 10)}}
+	if filename != "" {
+		query.Set("filename", filename)
+	}
+	query.Set("signature", s.signature(key, query))
+	return joinURL(s.baseUrl, key) + "?" + query.Encode()
+}
+
+// Verify checks the query of a signed URL of the blob stored under key.
+func (s URLSigner) Verify(key string, query url.Values, now time.Time) error {
+	signature, err := hex.DecodeString(query.Get("signature"))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	signed := url.Values{}
+	for _, name := range []string{"expires", "filename"} {
+		if value, ok := query[name]; ok {
+			signed[name] = value
+		}
+	}
+	expected, _ := hex.DecodeString(s.signature(key, signed))
+	if !hmac.Equal(signature, expected) {
+		return ErrInvalidSignature
+	}
+
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !now.Before(time.Unix(expires, 0)) {
+		return ErrSignedURLExpired
+	}
+	return nil
+}
+
+// signature is the hex encoded HMAC-SHA256 of the key and the signed
+// parameters, which Encode sorts by name.
+func (s URLSigner) signature(key string, query url.Values) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "?" + query.Encode()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,103 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOk       = "ok"
+	StatusFailing  = "failing"
+	StatusReady    = "ready"
+	StatusNotReady = "not_ready"
+)
+
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// Check is one dependency the server needs in order to serve traffic.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+type CheckResult struct {
+	Status   string
+	Duration time.Duration
+	Error    string
+}
+
+type Report struct {
+	Status string
+	Checks map[string]CheckResult
+}
+
+type Health struct {
+	checks       []Check
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+func New(timeout time.Duration, checks ...Check) *Health {
+	return &Health{
+		checks:  checks,
+		timeout: timeout,
+	}
+}
+
+// SetShuttingDown makes every following readiness report fail, so load
+// balancers stop routing to this instance while it drains.
+func (h *Health) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// Ready runs all checks concurrently, each bounded by the health timeout.
+func (h *Health) Ready(ctx context.Context) Report {
+	report := Report{
+		Status: StatusReady,
+		Checks: make(map[string]CheckResult, len(h.checks)),
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, check := range h.checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			result := h.run(ctx, check)
+			mu.Lock()
+			report.Checks[check.Name] = result
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status != StatusOk {
+			report.Status = StatusNotReady
+		}
+	}
+	if h.shuttingDown.Load() {
+		report.Status = StatusNotReady
+		report.Checks["shutdown"] = CheckResult{Status: StatusFailing, Error: ErrShuttingDown.Error()}
+	}
+	return report
+}
+
+func (h *Health) run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Run(ctx)
+	result := CheckResult{Status: StatusOk, Duration: time.Since(start)}
+	if err != nil {
+		result.Status = StatusFailing
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-rest-api/internal/app"
+	"go-rest-api/internal/domain"
+	"go-rest-api/internal/infra/http/resources"
+	"go-rest-api/internal/infra/logger"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type AttachmentController struct {
+	attachmentService app.AttachmentService
+}
+
+func NewAttachmentController(attachmentService app.AttachmentService) AttachmentController {
+	return AttachmentController{attachmentService: attachmentService}
+}
+
+func (c AttachmentController) ListAttachments() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		project := GetPathValueFromCtx[domain.Project](r.Context())
+
+		attachments, err := c.attachmentService.FindByProjectId(r.Context(), project.Id)
+		if err != nil {
+			InternalServerError(w, err)
+			return
+		}
+		Success(w, resources.AttachmentsDto{}.DomainToDto(attachments))
+	}
+}
+
+func (c AttachmentController) UploadAttachment() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := r.Context().Value(UserKey).(domain.User)
+		project := GetPathValueFromCtx[domain.Project](r.Context())
+
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType != "multipart/form-data" {
+			UnsupportedMediaType(w, fmt.Errorf("%w: send the file as multipart/form-data", app.ErrUnsupportedMediaType))
+			return
+		}
+		file, err := formFile(r, "file")
+		if err != nil {
+			logger.FromContext(r.Context()).Error(err)
+			BadRequest(w, err)
+			return
+		}
+
+		attachment, err := c.attachmentService.Upload(r.Context(), domain.Attachment{
+			ProjectId:  project.Id,
+			UploaderId: user.Id,
+			Filename:   file.FileName(),
+		}, file)
+		switch {
+		case errors.Is(err, app.ErrFileTooLarge):
+			RequestEntityTooLarge(w, err)
+		case errors.Is(err, domain.ErrQuotaExceeded):
+			InsufficientStorage(w, err)
+		case errors.Is(err, domain.ErrProjectArchived):
+			Conflict(w, err)
+		case errors.Is(err, app.ErrInvalidUpload):
+			BadRequest(w, err)
+		case err != nil:
+			InternalServerError(w, err)
+		default:
+			Created(w, resources.AttachmentDto{}.DomainToDto(attachment))
+		}
+	}
+}
+
+// StorageUsage reports the storage used by the current user and their
+// projects against the quotas.
+func (c AttachmentController) StorageUsage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := r.Context().Value(UserKey).(domain.User)
+
+		usage, err := c.attachmentService.Usage(r.Context(), user.Id)
+		if err != nil {
+			InternalServerError(w, err)
+			return
+		}
+		Success(w, resources.StorageUsageDto{}.DomainToDto(usage))
+	}
+}
+
+// DownloadAttachment streams the content of an attachment, which browsers
+// save under its file name instead of displaying it.
+func (c AttachmentController) DownloadAttachment() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		attachment, ok := c.attachment(w, r)
+		if !ok {
+			return
+		}
+
+		content, err := c.attachmentService.Open(r.Context(), attachment)
+		if err != nil {
+			scanError(w, err)
+			return
+		}
+		defer content.Close()
+
+		etag := `"` + attachment.Checksum + `"`
+		w.Header().Set("Cache-Control", "private, no-cache")
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", attachment.ContentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+		w.Header().Set("Content-Security-Policy", "sandbox")
+		w.WriteHeader(http.StatusOK)
+		if _, err = io.Copy(w, content); err != nil {
+			logger.FromContext(r.Context()).Error(err)
+		}
+	}
+}
+
+// SignedUrl issues a short-lived download URL of an attachment, for links
+// and clients that cannot send a token.
+func (c AttachmentController) SignedUrl() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		attachment, ok := c.attachment(w, r)
+		if !ok {
+			return
+		}
+
+		signedUrl, expires, err := c.attachmentService.SignedURL(r.Context(), attachment)
+		if err != nil {
+			scanError(w, err)
+			return
+		}
+		Success(w, resources.SignedUrlDto{Url: signedUrl, ExpiresAt: expires})
+	}
+}
+
+func (c AttachmentController) DeleteAttachment() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		attachment, ok := c.attachment(w, r)
+		if !ok {
+			return
+		}
+
+		err := c.attachmentService.Delete(r.Context(), attachment)
+		if errors.Is(err, domain.ErrProjectArchived) {
+			Conflict(w, err)
+			return
+		}
+		if err != nil {
+			InternalServerError(w, err)
+			return
+		}
+		Ok(w)
+	}
+}
+
+// scanError answers why an attachment cannot be downloaded: 409 while it
+// waits for the malware scan and 403 once it is quarantined.
+func scanError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, app.ErrScanPending):
+		w.Header().Set("Retry-After", "30")
+		Conflict(w, err)
+	case errors.Is(err, app.ErrInfected):
+		Forbidden(w, err)
+	default:
+		InternalServerError(w, err)
+	}
+}
+
+// attachment finds the attachment in the path, answering 404 when it does
+// not belong to the project in the path.
+func (c AttachmentController) attachment(w http.ResponseWriter, r *http.Request) (domain.Attachment, bool) {
+	project := GetPathValueFromCtx[domain.Project](r.Context())
+	notFound := errors.New("attachment not found")
+
+	attachmentId, err := strconv.ParseUint(chi.URLParam(r, "attachmentId"), 10, 64)
+	if err != nil {
+		NotFound(w, notFound)
+		return domain.Attachment{}, false
+	}
+	attachment, err := c.attachmentService.FindById(r.Context(), attachmentId)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && attachment.ProjectId != project.Id {
+		NotFound(w, notFound)
+		return domain.Attachment{}, false
+	}
+	if err != nil {
+		InternalServerError(w, err)
+		return domain.Attachment{}, false
+	}
+	return attachment, true
+}
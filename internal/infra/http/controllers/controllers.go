@@ -3,9 +3,14 @@ package controllers
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"go-rest-api/internal/domain"
+	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 )
 
 type ctxKey struct {
@@ -18,6 +23,8 @@ var (
 	UserKey    = ctxKey{"user"}
 	SessionKey = ctxKey{"session"}
 	ProjectKey = ctxKey{"project"}
+
+	RequestIdKey = ctxKey{"request_id"}
 )
 
 func GetPathValueInCtx[T any](ctx context.Context, value T) context.Context {
@@ -43,6 +50,36 @@ func ResolveCtxKeyFromPathType(value any) CtxStrKey {
 	}
 }
 
+// formFile returns the file sent in field of a multipart form. Parts are
+// streamed as they arrive instead of parsing the whole form.
+func formFile(r *http.Request, field string) (*multipart.Part, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("the %s field is missing", field)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == field {
+			return part, nil
+		}
+	}
+}
+
+// SetNextLink advertises the next page of a listing in the Link header,
+// keeping every query parameter of the current request except the cursor.
+func SetNextLink(w http.ResponseWriter, r *http.Request, cursor string) {
+	query := r.URL.Query()
+	query.Set("cursor", cursor)
+	next := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+	w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+}
+
 func Ok(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -68,13 +105,66 @@ func Created(w http.ResponseWriter, body interface{}) {
 	}
 }
 
+func ServiceUnavailable(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+// BadRequest answers 400, or 413 when err comes from reading a body past
+// the limit set by the body limit middleware.
 func BadRequest(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		RequestEntityTooLarge(w, fmt.Errorf("request body is larger than %d bytes", maxBytesErr.Limit))
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusBadRequest)
 
 	encodeErrorData(w, err)
 }
 
+func RequestEntityTooLarge(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+
+	encodeErrorData(w, err)
+}
+
+func InsufficientStorage(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInsufficientStorage)
+
+	encodeErrorData(w, err)
+}
+
+func UnsupportedMediaType(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnsupportedMediaType)
+
+	encodeErrorData(w, err)
+}
+
+func Conflict(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+
+	encodeErrorData(w, err)
+}
+
+func TooManyRequests(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+
+	encodeErrorData(w, err)
+}
+
 func InternalServerError(w http.ResponseWriter, err error) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusInternalServerError)
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"go-rest-api/internal/app"
+	"go-rest-api/internal/infra/filesystem"
+	"go-rest-api/internal/infra/logger"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type FileController struct {
+	blobStore         filesystem.BlobStore
+	signer            filesystem.URLSigner
+	attachmentService app.AttachmentService
+}
+
+func NewFileController(blobStore filesystem.BlobStore, signer filesystem.URLSigner, attachmentService app.AttachmentService) FileController {
+	return FileController{blobStore: blobStore, signer: signer, attachmentService: attachmentService}
+}
+
+// ServeAvatar streams an avatar from the blob store, for backends that do
+// not serve files themselves. Avatar keys change with their content, so
+// the response may be cached forever.
+func (c FileController) ServeAvatar() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := strconv.ParseUint(chi.URLParam(r, "userId"), 10, 64)
+		if err != nil {
+			NotFound(w, errors.New("file not found"))
+			return
+		}
+		key := "avatars/" + strconv.FormatUint(userId, 10) + "/" + chi.URLParam(r, "name")
+
+		blob, info, err := c.blobStore.Get(r.Context(), key)
+		if errors.Is(err, filesystem.ErrBlobNotFound) || errors.Is(err, filesystem.ErrInvalidBlobKey) {
+			NotFound(w, errors.New("file not found"))
+			return
+		}
+		if err != nil {
+			InternalServerError(w, err)
+			return
+		}
+		defer blob.Close()
+
+		w.Header().Set("Content-Type", info.ContentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		w.WriteHeader(http.StatusOK)
+		if _, err = io.Copy(w, blob); err != nil {
+			logger.FromContext(r.Context()).Error(err)
+		}
+	}
+}
+
+// ServeSignedAttachment streams an attachment by a URL signed by the API.
+// The signature only proves the URL was issued, so the attachment is looked
+// up again: deleting it revokes its URLs, and one quarantined after signing
+// is refused like an authenticated download. Attachments are always
+// downloaded rather than displayed, as they were uploaded by users and are
+// served from the API's origin.
+func (c FileController) ServeSignedAttachment() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, err := strconv.ParseUint(chi.URLParam(r, "projectId"), 10, 64)
+		if err != nil {
+			NotFound(w, errors.New("file not found"))
+			return
+		}
+		key := "attachments/" + strconv.FormatUint(projectId, 10) + "/" + chi.URLParam(r, "name")
+		query := r.URL.Query()
+		err = c.signer.Verify(key, query, time.Now())
+		if err != nil {
+			Forbidden(w, err)
+			return
+		}
+
+		attachment, err := c.attachmentService.FindByKey(r.Context(), key)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && attachment.ProjectId != projectId) {
+			NotFound(w, errors.New("file not found"))
+			return
+		}
+		if err != nil {
+			InternalServerError(w, err)
+			return
+		}
+
+		blob, err := c.attachmentService.Open(r.Context(), attachment)
+		if errors.Is(err, filesystem.ErrBlobNotFound) {
+			NotFound(w, errors.New("file not found"))
+			return
+		}
+		if err != nil {
+			scanError(w, err)
+			return
+		}
+		defer blob.Close()
+
+		w.Header().Set("Content-Type", attachment.ContentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+		w.Header().Set("Content-Security-Policy", "sandbox")
+		w.Header().Set("Cache-Control", "private, no-store")
+		w.WriteHeader(http.StatusOK)
+		if _, err = io.Copy(w, blob); err != nil {
+			logger.FromContext(r.Context()).Error(err)
+		}
+	}
+}
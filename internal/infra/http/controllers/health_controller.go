@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"go-rest-api/internal/infra/health"
+	"go-rest-api/internal/infra/http/resources"
+	"net/http"
+)
+
+type HealthController struct {
+	health *health.Health
+}
+
+func NewHealthController(health *health.Health) HealthController {
+	return HealthController{
+		health: health,
+	}
+}
+
+// Live answers as long as the process can handle requests at all.
+func (c HealthController) Live() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		Success(w, resources.LivenessDto{Status: health.StatusOk})
+	}
+}
+
+// Ready reports every dependency check and answers 503 when one fails or
+// the server is draining.
+func (c HealthController) Ready() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := c.health.Ready(r.Context())
+		reportDto := resources.ReadinessDto{}.DomainToDto(report)
+		if report.Status != health.StatusReady {
+			ServiceUnavailable(w, reportDto)
+			return
+		}
+		Success(w, reportDto)
+	}
+}
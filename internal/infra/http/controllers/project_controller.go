@@ -36,7 +36,7 @@ func (c ProjectController) FindProjectById() http.HandlerFunc {
 			return
 		}
 
-		project, err := c.projectService.FindById(numericProjectId)
+		project, err := c.projectService.FindById(r.Context(), numericProjectId)
 		if err != nil {
 			InternalServerError(w, err)
 			return
@@ -50,27 +50,21 @@ func (c ProjectController) FindProjectById() http.HandlerFunc {
 func (c ProjectController) GetMyProjects() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		user := r.Context().Value(UserKey).(domain.User)
-		var numericPage, numericLimit int64
-		page := r.URL.Query().Get("page")
-		limit := r.URL.Query().Get("limit")
-		if page == "" || limit == "" {
-			numericPage = 1
-			numericLimit = 1
-		}
-
-		numericPage, pErr := strconv.ParseInt(page, 10, 32)
-		numericLimit, lErr := strconv.ParseInt(limit, 10, 32)
-		if pErr != nil || lErr != nil {
-			BadRequest(w, errors.New("invalid page or limit"))
+		query, err := requests.BindQuery(r, requests.ListProjectsRequest{}, domain.ProjectQuery{})
+		if err != nil {
+			BadRequest(w, err)
 			return
 		}
 
-		projects, err := c.projectService.FindByCreatorId(user.Id, int32(numericPage), int32(numericLimit))
+		projects, err := c.projectService.FindByCreatorId(r.Context(), user.Id, query)
 		if err != nil {
 			InternalServerError(w, err)
 			return
 		}
 
+		if projects.NextCursor != nil {
+			SetNextLink(w, r, projects.NextCursor.Encode())
+		}
 		Success(w, resources.ProjectsDto{}.DomainToDto(projects))
 	}
 }
@@ -86,7 +80,7 @@ func (c ProjectController) CreateProject() http.HandlerFunc {
 
 		projectBody.CreatorId = user.Id
 
-		createdProject, err := c.projectService.Save(projectBody)
+		createdProject, err := c.projectService.Save(r.Context(), projectBody)
 		if err != nil {
 			InternalServerError(w, err)
 			return
@@ -119,7 +113,11 @@ func (c ProjectController) UpdateProjecTitleAndDescription() http.HandlerFunc {
 
 		projectBody.Id = numericProjectId
 
-		updatedProject, err := c.projectService.Update(projectBody)
+		updatedProject, err := c.projectService.Update(r.Context(), projectBody)
+		if errors.Is(err, domain.ErrProjectArchived) {
+			Conflict(w, err)
+			return
+		}
 		if err != nil {
 			InternalServerError(w, err)
 			return
@@ -144,7 +142,11 @@ func (c ProjectController) DeleteProjectById() http.HandlerFunc {
 			return
 		}
 
-		err = c.projectService.Delete(numericProjectId)
+		err = c.projectService.Delete(r.Context(), numericProjectId)
+		if errors.Is(err, domain.ErrProjectArchived) {
+			Conflict(w, err)
+			return
+		}
 		if err != nil {
 			InternalServerError(w, err)
 			return
@@ -153,3 +155,29 @@ func (c ProjectController) DeleteProjectById() http.HandlerFunc {
 		Ok(w)
 	}
 }
+
+func (c ProjectController) ArchiveProject() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		project := GetPathValueFromCtx[domain.Project](r.Context())
+
+		archivedProject, err := c.projectService.Archive(r.Context(), project.Id)
+		if err != nil {
+			InternalServerError(w, err)
+			return
+		}
+		Success(w, resources.ProjectDto{}.DomainToDto(archivedProject))
+	}
+}
+
+func (c ProjectController) UnarchiveProject() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		project := GetPathValueFromCtx[domain.Project](r.Context())
+
+		unarchivedProject, err := c.projectService.Unarchive(r.Context(), project.Id)
+		if err != nil {
+			InternalServerError(w, err)
+			return
+		}
+		Success(w, resources.ProjectDto{}.DomainToDto(unarchivedProject))
+	}
+}
@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"errors"
+	"go-rest-api/internal/app"
+	"go-rest-api/internal/domain"
+	"go-rest-api/internal/infra/http/requests"
+	"go-rest-api/internal/infra/http/resources"
+	"net/http"
+)
+
+type SearchController struct {
+	searchService app.SearchService
+}
+
+func NewSearchController(searchService app.SearchService) SearchController {
+	return SearchController{
+		searchService: searchService,
+	}
+}
+
+func (c SearchController) Search() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := r.Context().Value(UserKey).(domain.User)
+		query, err := requests.BindQuery(r, requests.SearchRequest{}, domain.SearchQuery{})
+		if err != nil {
+			BadRequest(w, err)
+			return
+		}
+
+		results, err := c.searchService.Search(r.Context(), user.Id, query)
+		if err != nil {
+			if errors.Is(err, app.ErrEmptySearchQuery) {
+				BadRequest(w, err)
+				return
+			}
+			InternalServerError(w, err)
+			return
+		}
+
+		Success(w, resources.SearchResultsDto{}.DomainToDto(results))
+	}
+}
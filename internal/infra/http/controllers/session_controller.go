@@ -26,7 +26,7 @@ func (c SessionController) Register() http.HandlerFunc {
 			return
 		}
 
-		user, token, err := c.sessionServ.Register(user)
+		user, token, err := c.sessionServ.Register(r.Context(), user)
 		if err != nil {
 			BadRequest(w, err)
 			return
@@ -44,7 +44,7 @@ func (c SessionController) Login() http.HandlerFunc {
 			BadRequest(w, errors.New("invalid request body"))
 			return
 		}
-		user, token, err := c.sessionServ.Login(domainUser)
+		user, token, err := c.sessionServ.Login(r.Context(), domainUser)
 		if err != nil {
 			InternalServerError(w, err)
 			return
@@ -57,7 +57,7 @@ func (c SessionController) Login() http.HandlerFunc {
 func (c SessionController) Logout() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		sess := r.Context().Value(SessionKey).(domain.Session)
-		err := c.sessionServ.Logout(sess)
+		err := c.sessionServ.Logout(r.Context(), sess)
 		if err != nil {
 			InternalServerError(w, err)
 		}
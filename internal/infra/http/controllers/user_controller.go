@@ -1,13 +1,24 @@
 package controllers
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"go-rest-api/internal/app"
 	"go-rest-api/internal/domain"
 	"go-rest-api/internal/infra/http/requests"
 	"go-rest-api/internal/infra/http/resources"
 	"go-rest-api/internal/infra/logger"
+	"io"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -35,7 +46,7 @@ func (c UserController) Save() http.HandlerFunc {
 			return
 		}
 
-		user, err = c.userService.Save(user)
+		user, err = c.userService.Save(r.Context(), user)
 		if err != nil {
 			InternalServerError(w, err)
 			return
@@ -48,23 +59,95 @@ func (c UserController) Save() http.HandlerFunc {
 func (c UserController) UpdateUserAvatar() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		user := r.Context().Value(UserKey).(domain.User)
-		userWithAvatarString, err := requests.Bind(r, requests.UpdateAvatarRequest{}, domain.User{})
+		image, err := avatarFromRequest(r)
+		if errors.Is(err, app.ErrUnsupportedMediaType) {
+			UnsupportedMediaType(w, err)
+			return
+		}
 		if err != nil {
-			logger.Logger.Error(err)
+			logger.FromContext(r.Context()).Error(err)
 			BadRequest(w, err)
 			return
 		}
 
-		user.Avatar = userWithAvatarString.Avatar
+		newUser, err := c.userService.UpdateUserAvatar(r.Context(), user, image)
+		switch {
+		case errors.Is(err, app.ErrUnsupportedMediaType):
+			UnsupportedMediaType(w, err)
+		case errors.Is(err, app.ErrFileTooLarge), errors.Is(err, app.ErrImageTooLarge):
+			RequestEntityTooLarge(w, err)
+		case errors.Is(err, app.ErrInvalidUpload), errors.Is(err, app.ErrInvalidImage):
+			BadRequest(w, err)
+		case err != nil:
+			InternalServerError(w, err)
+		default:
+			Success(w, resources.UserDto{}.DomainToDto(newUser))
+		}
+	}
+}
 
-		newUser, err := c.userService.UpdateUserAvatar(user)
+func (c UserController) RemoveAvatar() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := r.Context().Value(UserKey).(domain.User)
+
+		user, err := c.userService.RemoveAvatar(r.Context(), user)
+		if err != nil {
+			InternalServerError(w, err)
+			return
+		}
+		Success(w, resources.UserDto{}.DomainToDto(user))
+	}
+}
+
+// DefaultAvatar serves the generated avatar of a user. It never changes,
+// so clients revalidate it with the ETag.
+func (c UserController) DefaultAvatar() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := strconv.ParseUint(chi.URLParam(r, "userId"), 10, 64)
+		if err != nil {
+			NotFound(w, errors.New("user not found"))
+			return
+		}
+		size, err := requests.BindQuery(r, requests.DefaultAvatarRequest{}, 0)
 		if err != nil {
-			logger.Logger.Error(err)
 			BadRequest(w, err)
 			return
 		}
-		Success(w, resources.UserDto{}.DomainToDto(newUser))
+
+		avatar, err := c.userService.DefaultAvatar(r.Context(), userId, size)
+		if errors.Is(err, sql.ErrNoRows) {
+			NotFound(w, errors.New("user not found"))
+			return
+		}
+		if err != nil {
+			InternalServerError(w, err)
+			return
+		}
+
+		sum := sha256.Sum256(avatar.Data)
+		w.Header().Set("Content-Type", avatar.ContentType)
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+		// ServeContent answers If-None-Match with 304 Not Modified.
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(avatar.Data))
+	}
+}
+
+// avatarFromRequest returns the file sent in the "avatar" field of a
+// multipart form. Older clients send it base64 encoded in a JSON body.
+func avatarFromRequest(r *http.Request) (io.Reader, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "multipart/form-data":
+		return formFile(r, "avatar")
+	case "application/json", "":
+		userWithAvatarString, err := requests.Bind(r, requests.UpdateAvatarRequest{}, domain.User{})
+		if err != nil {
+			return nil, err
+		}
+		return base64.NewDecoder(base64.StdEncoding, strings.NewReader(*userWithAvatarString.Avatar)), nil
 	}
+	return nil, fmt.Errorf("%w: send the avatar as multipart/form-data instead of %s", app.ErrUnsupportedMediaType, mediaType)
 }
 
 func (c UserController) ConfirmUserEmailByEmailConfirmationToken() http.HandlerFunc {
@@ -74,15 +157,15 @@ func (c UserController) ConfirmUserEmailByEmailConfirmationToken() http.HandlerF
 
 		if confirmToken == "" {
 			err := errors.New("invalid confirm token")
-			logger.Logger.Error(err)
+			logger.FromContext(r.Context()).Error(err)
 			BadRequest(w, err)
 		}
 
 		user.EmailConfirmationToken = confirmToken
 
-		err := c.userService.ConfirmUserEmail(user)
+		err := c.userService.ConfirmUserEmail(r.Context(), user)
 		if err != nil {
-			logger.Logger.Error(err)
+			logger.FromContext(r.Context()).Error(err)
 			BadRequest(w, err)
 		}
 		Ok(w)
@@ -93,7 +176,7 @@ func (c UserController) Delete() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		user := r.Context().Value(UserKey).(domain.User)
 
-		err := c.userService.Delete(user.Id)
+		err := c.userService.Delete(r.Context(), user.Id)
 		if err != nil {
 			InternalServerError(w, err)
 			return
@@ -0,0 +1,379 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go-rest-api/config"
+	"go-rest-api/internal/infra/filesystem"
+	"go-rest-api/internal/infra/scanning"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// createProject creates a project owned by the user of token and returns
+// its path.
+func createProject(t *testing.T, h *Harness, token, title string) string {
+	t.Helper()
+	resp := h.Do(http.MethodPost, "/api/v1/project", map[string]string{"title": title}, WithToken(token))
+	AssertStatus(t, resp, http.StatusOK)
+	var project struct {
+		Id uint64 `json:"id"`
+	}
+	resp.JSON(t, &project)
+	return Path("/api/v1/project/%d", project.Id)
+}
+
+type attachmentResponse struct {
+	Id          uint64 `json:"id"`
+	Filename    string `json:"filename"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+	ScanStatus  string `json:"scan_status"`
+	Url         string `json:"url"`
+}
+
+func TestAttachments(t *testing.T) {
+	h := newHarness(t)
+	alice := h.Register("Alice", "alice@example.com", "correct horse")
+	bob := h.Register("Bob", "bob@example.com", "correct horse")
+	projectPath := createProject(t, h, alice.Token, "Alice project")
+	attachmentsPath := projectPath + "/attachments"
+
+	content := []byte("<html><script>alert(1)</script></html>")
+	body, contentType := Multipart(t, "file", `../../notes "final".html`, content)
+	upload := WithHeader("Content-Type", contentType)
+
+	AssertStatus(t, h.Do(http.MethodPost, attachmentsPath, body, WithToken(bob.Token), upload), http.StatusForbidden)
+	resp := h.Do(http.MethodPost, attachmentsPath, body, WithToken(alice.Token), upload)
+	AssertStatus(t, resp, http.StatusCreated)
+	h.AssertGolden("attachment_upload", resp)
+	var attachment attachmentResponse
+	resp.JSON(t, &attachment)
+	if attachment.Filename != `notes "final".html` || attachment.Size != int64(len(content)) {
+		t.Errorf("stored %+v, want the base name and %d bytes", attachment, len(content))
+	}
+
+	resp = h.Do(http.MethodGet, attachmentsPath, nil, WithToken(bob.Token))
+	AssertStatus(t, resp, http.StatusOK)
+	h.AssertGolden("attachment_list", resp)
+
+	// Anyone signed in may download, but never gets a page rendered in the
+	// API's origin.
+	resp = h.Do(http.MethodGet, attachment.Url, nil, WithToken(bob.Token))
+	AssertStatus(t, resp, http.StatusOK)
+	if !bytes.Equal(resp.Body, content) {
+		t.Errorf("downloaded %q, want %q", resp.Body, content)
+	}
+	for name, want := range map[string]string{
+		"Content-Type":            attachment.ContentType,
+		"Content-Disposition":     `attachment; filename="notes \"final\".html"`,
+		"Content-Security-Policy": "sandbox",
+	} {
+		if got := resp.Header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	etag := resp.Header.Get("ETag")
+	AssertStatus(t, h.Do(http.MethodGet, attachment.Url, nil, WithToken(bob.Token), WithHeader("If-None-Match", etag)), http.StatusNotModified)
+	AssertStatus(t, h.Do(http.MethodGet, attachment.Url, nil), http.StatusUnauthorized)
+
+	// An attachment is only found under its own project.
+	otherPath := createProject(t, h, bob.Token, "Bob project")
+	AssertStatus(t, h.Do(http.MethodGet, strings.Replace(attachment.Url, projectPath, otherPath, 1), nil, WithToken(bob.Token)), http.StatusNotFound)
+	AssertStatus(t, h.Do(http.MethodGet, attachmentsPath+"/abc", nil, WithToken(alice.Token)), http.StatusNotFound)
+
+	AssertStatus(t, h.Do(http.MethodDelete, attachment.Url, nil, WithToken(bob.Token)), http.StatusForbidden)
+	AssertStatus(t, h.Do(http.MethodDelete, attachment.Url, nil, WithToken(alice.Token)), http.StatusOK)
+	AssertStatus(t, h.Do(http.MethodGet, attachment.Url, nil, WithToken(alice.Token)), http.StatusNotFound)
+	if blobs, err := h.Blobs.List(context.Background(), "attachments/"); err != nil || len(blobs) != 0 {
+		t.Errorf("files left after deleting the attachment: %+v, %v", blobs, err)
+	}
+}
+
+func TestAttachmentUploadRejected(t *testing.T) {
+	h := newHarness(t, WithConfig(func(cfg *config.Configuration) {
+		cfg.AttachmentMaxSize = 64
+	}))
+	alice := h.Register("Alice", "alice@example.com", "correct horse")
+	attachmentsPath := createProject(t, h, alice.Token, "Alice project") + "/attachments"
+
+	post := func(body []byte, contentType string) Response {
+		return h.Do(http.MethodPost, attachmentsPath, body, WithToken(alice.Token), WithHeader("Content-Type", contentType))
+	}
+	AssertStatus(t, post(Multipart(t, "file", "big.bin", bytes.Repeat([]byte("x"), 65))), http.StatusRequestEntityTooLarge)
+	AssertStatus(t, post(Multipart(t, "file", "empty.txt", nil)), http.StatusBadRequest)
+	AssertStatus(t, post(Multipart(t, "document", "notes.txt", []byte("notes"))), http.StatusBadRequest)
+	AssertStatus(t, post([]byte(`{"file": "notes"}`), "application/json"), http.StatusUnsupportedMediaType)
+
+	blobs, err := h.Blobs.List(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 0 {
+		t.Errorf("rejected uploads were stored: %+v", blobs)
+	}
+}
+
+func TestProjectDeleteRemovesAttachments(t *testing.T) {
+	h := newHarness(t)
+	alice := h.Register("Alice", "alice@example.com", "correct horse")
+	projectPath := createProject(t, h, alice.Token, "Alice project")
+	for _, name := range []string{"a.txt", "b.txt"} {
+		body, contentType := Multipart(t, "file", name, []byte(name))
+		resp := h.Do(http.MethodPost, projectPath+"/attachments", body, WithToken(alice.Token), WithHeader("Content-Type", contentType))
+		AssertStatus(t, resp, http.StatusCreated)
+	}
+
+	AssertStatus(t, h.Do(http.MethodDelete, projectPath, nil, WithToken(alice.Token)), http.StatusOK)
+	blobs, err := h.Blobs.List(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 0 {
+		t.Errorf("files left after deleting the project: %+v", blobs)
+	}
+}
+
+func TestDeleteOrphanedAttachments(t *testing.T) {
+	h := newHarness(t)
+	alice := h.Register("Alice", "alice@example.com", "correct horse")
+	projectPath := createProject(t, h, alice.Token, "Alice project")
+	body, contentType := Multipart(t, "file", "kept.txt", []byte("kept"))
+	AssertStatus(t, h.Do(http.MethodPost, projectPath+"/attachments", body, WithToken(alice.Token), WithHeader("Content-Type", contentType)), http.StatusCreated)
+
+	ctx := context.Background()
+	orphan := "attachments/1/00000000-0000-0000-0000-000000000000"
+	if _, err := h.Blobs.Put(ctx, orphan, bytes.NewReader([]byte("orphan")), 6, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := h.Container.AttachmentService.DeleteOrphanedAttachments(ctx, time.Hour, false)
+	if err != nil || len(found) != 0 {
+		t.Fatalf("deleted %v, %v; want nothing, every file is younger than an hour", found, err)
+	}
+	found, err = h.Container.AttachmentService.DeleteOrphanedAttachments(ctx, 0, false)
+	if err != nil || len(found) != 1 || found[0] != orphan {
+		t.Fatalf("deleted %v, %v; want %s", found, err, orphan)
+	}
+	blobs, err := h.Blobs.List(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 1 {
+		t.Errorf("%d files are left, want the attachment of alice", len(blobs))
+	}
+}
+
+func TestStorageQuota(t *testing.T) {
+	h := newHarness(t, WithConfig(func(cfg *config.Configuration) {
+		cfg.StorageUserQuota = 20
+		cfg.StorageProjectQuota = 12
+	}))
+	alice := h.Register("Alice", "alice@example.com", "correct horse")
+	alpha := createProject(t, h, alice.Token, "alpha")
+	bravo := createProject(t, h, alice.Token, "bravo")
+
+	upload := func(projectPath string, size int) Response {
+		body, contentType := Multipart(t, "file", "data.bin", bytes.Repeat([]byte("x"), size))
+		return h.Do(http.MethodPost, projectPath+"/attachments", body, WithToken(alice.Token), WithHeader("Content-Type", contentType))
+	}
+	resp := upload(alpha, 10)
+	AssertStatus(t, resp, http.StatusCreated)
+	var attachment attachmentResponse
+	resp.JSON(t, &attachment)
+
+	// Larger than a whole quota, it could never be stored.
+	AssertStatus(t, upload(bravo, 13), http.StatusRequestEntityTooLarge)
+	// It fits the quotas, but not what is left of alpha's.
+	AssertStatus(t, upload(alpha, 3), http.StatusInsufficientStorage)
+	AssertStatus(t, upload(bravo, 8), http.StatusCreated)
+	// And now not what is left of alice's.
+	AssertStatus(t, upload(bravo, 3), http.StatusInsufficientStorage)
+
+	resp = h.Do(http.MethodGet, "/api/v1/user/me/usage", nil, WithToken(alice.Token))
+	AssertStatus(t, resp, http.StatusOK)
+	h.AssertGolden("storage_usage", resp)
+
+	blobs, err := h.Blobs.List(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 2 {
+		t.Errorf("stored %d files, want only the 2 accepted ones", len(blobs))
+	}
+
+	// Deleting frees the space again.
+	AssertStatus(t, h.Do(http.MethodDelete, attachment.Url, nil, WithToken(alice.Token)), http.StatusOK)
+	AssertStatus(t, upload(bravo, 3), http.StatusCreated)
+	AssertStatus(t, h.Do(http.MethodGet, "/api/v1/user/me/usage", nil), http.StatusUnauthorized)
+}
+
+func TestStorageUsageUnlimited(t *testing.T) {
+	h := newHarness(t, WithConfig(func(cfg *config.Configuration) {
+		cfg.StorageUserQuota = 0
+		cfg.StorageProjectQuota = 0
+	}))
+	alice := h.Register("Alice", "alice@example.com", "correct horse")
+	createProject(t, h, alice.Token, "alpha")
+
+	var usage struct {
+		Limit    *int64 `json:"limit"`
+		Projects []struct {
+			Limit *int64 `json:"limit"`
+		} `json:"projects"`
+	}
+	h.Do(http.MethodGet, "/api/v1/user/me/usage", nil, WithToken(alice.Token)).JSON(t, &usage)
+	if usage.Limit != nil || len(usage.Projects) != 1 || usage.Projects[0].Limit != nil {
+		t.Errorf("usage = %+v, want no limits", usage)
+	}
+}
+
+func TestAttachmentSignedUrl(t *testing.T) {
+	h := newHarness(t)
+	alice := h.Register("Alice", "alice@example.com", "correct horse")
+	bob := h.Register("Bob", "bob@example.com", "correct horse")
+	projectPath := createProject(t, h, alice.Token, "Alice project")
+
+	content := []byte("%PDF-1.7")
+	body, contentType := Multipart(t, "file", "spec.pdf", content)
+	resp := h.Do(http.MethodPost, projectPath+"/attachments", body, WithToken(alice.Token), WithHeader("Content-Type", contentType))
+	AssertStatus(t, resp, http.StatusCreated)
+	var attachment attachmentResponse
+	resp.JSON(t, &attachment)
+
+	AssertStatus(t, h.Do(http.MethodGet, attachment.Url+"/signed-url", nil), http.StatusUnauthorized)
+	resp = h.Do(http.MethodGet, attachment.Url+"/signed-url", nil, WithToken(bob.Token))
+	AssertStatus(t, resp, http.StatusOK)
+	var signed struct {
+		Url       string    `json:"url"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	resp.JSON(t, &signed)
+	if !strings.HasPrefix(signed.Url, filesystem.SignedPath+"/attachments/") || signed.ExpiresAt.Before(time.Now()) {
+		t.Fatalf("signed URL = %+v", signed)
+	}
+
+	// The URL works without a token.
+	resp = h.Do(http.MethodGet, signed.Url, nil)
+	AssertStatus(t, resp, http.StatusOK)
+	if !bytes.Equal(resp.Body, content) {
+		t.Errorf("downloaded %q, want %q", resp.Body, content)
+	}
+	for name, want := range map[string]string{
+		"Content-Disposition":     "attachment; filename=spec.pdf",
+		"Content-Security-Policy": "sandbox",
+	} {
+		if got := resp.Header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	parsed, err := url.Parse(signed.Url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := strings.TrimPrefix(parsed.Path, filesystem.SignedPath+"/")
+	query := parsed.Query()
+	query.Set("filename", "spec.html")
+	AssertStatus(t, h.Do(http.MethodGet, parsed.Path+"?"+query.Encode(), nil), http.StatusForbidden)
+	expired := filesystem.NewURLSigner(h.Config.SigningKey(), filesystem.SignedPath).Sign(key, time.Now().Add(-time.Second), "spec.pdf")
+	AssertStatus(t, h.Do(http.MethodGet, expired, nil), http.StatusForbidden)
+
+	// Deleting the attachment revokes its URLs.
+	AssertStatus(t, h.Do(http.MethodDelete, attachment.Url, nil, WithToken(alice.Token)), http.StatusOK)
+	AssertStatus(t, h.Do(http.MethodGet, signed.Url, nil), http.StatusNotFound)
+}
+
+// fakeScanner finds malware in files containing "EICAR" and fails while
+// down is set.
+type fakeScanner struct {
+	down atomic.Bool
+}
+
+func (s *fakeScanner) Scan(_ context.Context, r io.Reader) (scanning.Result, error) {
+	if s.down.Load() {
+		return scanning.Result{}, errors.New("scanner unavailable")
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return scanning.Result{}, err
+	}
+	if bytes.Contains(content, []byte("EICAR")) {
+		return scanning.Result{Infected: true, Signature: "Eicar-Test-Signature"}, nil
+	}
+	return scanning.Result{}, nil
+}
+
+func (s *fakeScanner) Ping(_ context.Context) error {
+	if s.down.Load() {
+		return errors.New("scanner unavailable")
+	}
+	return nil
+}
+
+func TestAttachmentMalwareScan(t *testing.T) {
+	scanner := &fakeScanner{}
+	h := newHarness(t, WithScanner(scanner))
+	alice := h.Register("Alice", "alice@example.com", "correct horse")
+	attachmentsPath := createProject(t, h, alice.Token, "Alice project") + "/attachments"
+
+	upload := func(name, content string) attachmentResponse {
+		t.Helper()
+		body, contentType := Multipart(t, "file", name, []byte(content))
+		resp := h.Do(http.MethodPost, attachmentsPath, body, WithToken(alice.Token), WithHeader("Content-Type", contentType))
+		AssertStatus(t, resp, http.StatusCreated)
+		var attachment attachmentResponse
+		resp.JSON(t, &attachment)
+		return attachment
+	}
+
+	clean := upload("notes.txt", "notes")
+	infected := upload("eicar.com", "X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*")
+	scanner.down.Store(true)
+	pending := upload("later.txt", "EICAR later")
+	for attachment, want := range map[*attachmentResponse]string{&clean: "clean", &infected: "infected", &pending: "pending"} {
+		if attachment.ScanStatus != want {
+			t.Errorf("%s is %s, want %s", attachment.Filename, attachment.ScanStatus, want)
+		}
+	}
+
+	AssertStatus(t, h.Do(http.MethodGet, clean.Url, nil, WithToken(alice.Token)), http.StatusOK)
+	AssertStatus(t, h.Do(http.MethodGet, infected.Url, nil, WithToken(alice.Token)), http.StatusForbidden)
+	AssertStatus(t, h.Do(http.MethodGet, infected.Url+"/signed-url", nil, WithToken(alice.Token)), http.StatusForbidden)
+	resp := h.Do(http.MethodGet, pending.Url, nil, WithToken(alice.Token))
+	AssertStatus(t, resp, http.StatusConflict)
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("pending download without Retry-After")
+	}
+	AssertStatus(t, h.Do(http.MethodGet, pending.Url+"/signed-url", nil, WithToken(alice.Token)), http.StatusConflict)
+
+	// URLs signed before a scan are checked against its result.
+	stored, err := h.Container.AttachmentService.FindById(context.Background(), pending.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedUrl := filesystem.NewURLSigner(h.Config.SigningKey(), filesystem.SignedPath).Sign(stored.Key, time.Now().Add(time.Minute), stored.Filename)
+	AssertStatus(t, h.Do(http.MethodGet, signedUrl, nil), http.StatusConflict)
+
+	// Pending attachments are scanned again once the scanner is back.
+	scanned, err := h.Container.AttachmentService.ScanPending(context.Background())
+	if err != nil || len(scanned) != 1 || scanned[0].ScanStatus != "pending" {
+		t.Fatalf("scanning while down = %+v, %v", scanned, err)
+	}
+	scanner.down.Store(false)
+	scanned, err = h.Container.AttachmentService.ScanPending(context.Background())
+	if err != nil || len(scanned) != 1 || scanned[0].ScanStatus != "infected" {
+		t.Fatalf("scanning = %+v, %v", scanned, err)
+	}
+	AssertStatus(t, h.Do(http.MethodGet, pending.Url, nil, WithToken(alice.Token)), http.StatusForbidden)
+	AssertStatus(t, h.Do(http.MethodGet, signedUrl, nil), http.StatusForbidden)
+
+	// Quarantined attachments can still be deleted.
+	AssertStatus(t, h.Do(http.MethodDelete, infected.Url, nil, WithToken(alice.Token)), http.StatusOK)
+}
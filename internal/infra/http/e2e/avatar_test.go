@@ -0,0 +1,329 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"go-rest-api/config"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+const avatarPath = "/api/v1/user/me/update/avatar"
+
+func pngImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 16), G: uint8(y * 16), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// noisyPng is an image that PNG cannot compress, so it stays about four
+// bytes per pixel.
+func noisyPng(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	rand.New(rand.NewSource(1)).Read(img.Pix)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestAvatarUpload(t *testing.T) {
+	h := newHarness(t)
+	alice := h.Register("Alice", "alice@example.com", "correct horse")
+	image := pngImage(t, 16, 16)
+
+	// The file name and type claimed by the client are ignored.
+	body, contentType := Multipart(t, "avatar", "me.gif", image)
+	resp := h.Do(http.MethodPut, avatarPath, body, WithToken(alice.Token), WithHeader("Content-Type", contentType))
+	AssertStatus(t, resp, http.StatusOK)
+	h.AssertGolden("user_avatar", resp)
+
+	var user struct {
+		Avatar  string            `json:"avatar"`
+		Avatars map[string]string `json:"avatars"`
+	}
+	resp.JSON(t, &user)
+	if width, height := servedImage(t, h, user.Avatar, "image/png"); width != 16 || height != 16 {
+		t.Errorf("avatar is %dx%d, want 16x16", width, height)
+	}
+	for size, url := range map[int]string{32: user.Avatars["32"], 64: user.Avatars["64"], 256: user.Avatars["256"]} {
+		if width, height := servedImage(t, h, url, "image/png"); width != size || height != size {
+			t.Errorf("%dpx thumbnail is %dx%d", size, width, height)
+		}
+	}
+	AssertStatus(t, h.Do(http.MethodGet, "/files/avatars/1/missing.png", nil), http.StatusNotFound)
+
+	AssertStatus(t, h.Do(http.MethodPut, avatarPath, body, WithHeader("Content-Type", contentType)), http.StatusUnauthorized)
+}
+
+func TestAvatarUploadOrientation(t *testing.T) {
+	h := newHarness(t)
+	alice := h.Register("Alice", "alice@example.com", "correct horse")
+
+	// Stored 40x20 with red on the left and blue on the right, displayed
+	// turned a quarter clockwise: 20x40 with red on top.
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 20; y++ {
+			if x < 20 {
+				img.Set(x, y, color.RGBA{R: 255, A: 255})
+			} else {
+				img.Set(x, y, color.RGBA{B: 255, A: 255})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	// An APP1 segment holding a big endian TIFF with orientation 6.
+	exif := []byte("\xff\xe1\x00\x22Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00\x06\x00\x00\x00\x00\x00\x00")
+	photo := append(append([]byte{0xff, 0xd8}, exif...), buf.Bytes()[2:]...)
+
+	body, contentType := Multipart(t, "avatar", "photo.jpg", photo)
+	resp := h.Do(http.MethodPut, avatarPath, body, WithToken(alice.Token), WithHeader("Content-Type", contentType))
+	AssertStatus(t, resp, http.StatusOK)
+	var user struct {
+		Avatar  string            `json:"avatar"`
+		Avatars map[string]string `json:"avatars"`
+	}
+	resp.JSON(t, &user)
+
+	original := h.Do(http.MethodGet, user.Avatar, nil)
+	if bytes.Contains(original.Body, []byte("Exif")) {
+		t.Error("the stored avatar kept its EXIF data")
+	}
+	if width, height := servedImage(t, h, user.Avatar, "image/jpeg"); width != 20 || height != 40 {
+		t.Errorf("avatar is %dx%d, want 20x40", width, height)
+	}
+
+	resp = h.Do(http.MethodGet, user.Avatars["64"], nil)
+	AssertStatus(t, resp, http.StatusOK)
+	thumbnail, err := jpeg.Decode(bytes.NewReader(resp.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	top, bottom := color.RGBAModel.Convert(thumbnail.At(32, 4)).(color.RGBA), color.RGBAModel.Convert(thumbnail.At(32, 60)).(color.RGBA)
+	if top.R < 200 || top.B > 50 || bottom.B < 200 || bottom.R > 50 {
+		t.Errorf("thumbnail is not upright: top %v, bottom %v", top, bottom)
+	}
+}
+
+// servedImage downloads the image at url and returns its dimensions.
+func servedImage(t *testing.T, h *Harness, url, contentType string) (int, int) {
+	t.Helper()
+	resp := h.Do(http.MethodGet, url, nil)
+	AssertStatus(t, resp, http.StatusOK)
+	if resp.Header.Get("Content-Type") != contentType {
+		t.Errorf("%s is served as %s, want %s", url, resp.Header.Get("Content-Type"), contentType)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(resp.Body))
+	if err != nil {
+		t.Fatalf("%s: %v", url, err)
+	}
+	return config.Width, config.Height
+}
+
+func TestAvatarUploadBase64(t *testing.T) {
+	h := newHarness(t)
+	alice := h.Register("Alice", "alice@example.com", "correct horse")
+	image := pngImage(t, 16, 16)
+
+	resp := h.Do(http.MethodPut, avatarPath, map[string]string{"avatar": base64.StdEncoding.EncodeToString(image)}, WithToken(alice.Token))
+	AssertStatus(t, resp, http.StatusOK)
+	h.AssertGolden("user_avatar", resp)
+
+	resp = h.Do(http.MethodPut, avatarPath, map[string]string{"avatar": "not base64!"}, WithToken(alice.Token))
+	AssertStatus(t, resp, http.StatusBadRequest)
+}
+
+func TestAvatarUploadRejected(t *testing.T) {
+	h := newHarness(t, WithConfig(func(cfg *config.Configuration) {
+		cfg.AvatarMaxSize = 4096
+		cfg.AvatarMaxDimension = 32
+	}))
+	alice := h.Register("Alice", "alice@example.com", "correct horse")
+
+	tests := []struct {
+		name    string
+		content []byte
+		field   string
+		status  int
+		golden  string
+	}{
+		{"text", []byte("just some text"), "avatar", http.StatusUnsupportedMediaType, "avatar_unsupported_type"},
+		{"pdf", []byte("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n"), "avatar", http.StatusUnsupportedMediaType, ""},
+		{"too many bytes", append(pngImage(t, 16, 16), make([]byte, 4096)...), "avatar", http.StatusRequestEntityTooLarge, "avatar_too_large"},
+		{"too many pixels", pngImage(t, 64, 16), "avatar", http.StatusRequestEntityTooLarge, "avatar_too_many_pixels"},
+		{"corrupt", append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...), "avatar", http.StatusBadRequest, ""},
+		{"other field", pngImage(t, 16, 16), "picture", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, contentType := Multipart(t, tt.field, "file", tt.content)
+			resp := h.Do(http.MethodPut, avatarPath, body, WithToken(alice.Token), WithHeader("Content-Type", contentType))
+			AssertStatus(t, resp, tt.status)
+			if tt.golden != "" {
+				h.AssertGolden(tt.golden, resp)
+			}
+		})
+	}
+
+	resp := h.Do(http.MethodPut, avatarPath, pngImage(t, 16, 16), WithToken(alice.Token), WithHeader("Content-Type", "image/png"))
+	AssertStatus(t, resp, http.StatusUnsupportedMediaType)
+
+	blobs, err := h.Blobs.List(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 0 {
+		t.Errorf("rejected uploads were stored: %+v", blobs)
+	}
+}
+
+func TestDefaultAvatar(t *testing.T) {
+	h := newHarness(t)
+	alice := h.Register("Alice", "alice@example.com", "correct horse")
+	h.Register("Bob", "bob@example.com", "correct horse")
+
+	var user struct {
+		Avatar  string            `json:"avatar"`
+		Avatars map[string]string `json:"avatars"`
+	}
+	h.Do(http.MethodGet, "/api/v1/user/me", nil, WithToken(alice.Token)).JSON(t, &user)
+	if user.Avatar != "/api/v1/users/1/avatar.png" || user.Avatars["32"] != "/api/v1/users/1/avatar.png?size=32" {
+		t.Fatalf("avatars = %s %v, want the generated ones", user.Avatar, user.Avatars)
+	}
+
+	// Generated avatars are public, so they work in image tags.
+	if width, height := servedImage(t, h, user.Avatar, "image/png"); width != 256 || height != 256 {
+		t.Errorf("avatar is %dx%d, want 256x256", width, height)
+	}
+	for size, url := range map[int]string{32: user.Avatars["32"], 64: user.Avatars["64"], 256: user.Avatars["256"]} {
+		if width, height := servedImage(t, h, url, "image/png"); width != size || height != size {
+			t.Errorf("%dpx avatar is %dx%d", size, width, height)
+		}
+	}
+
+	first := h.Do(http.MethodGet, user.Avatar, nil)
+	etag := first.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("the avatar has no ETag")
+	}
+	if again := h.Do(http.MethodGet, user.Avatar, nil); !bytes.Equal(again.Body, first.Body) || again.Header.Get("ETag") != etag {
+		t.Error("the generated avatar changed between requests")
+	}
+	resp := h.Do(http.MethodGet, user.Avatar, nil, WithHeader("If-None-Match", etag))
+	AssertStatus(t, resp, http.StatusNotModified)
+	if bob := h.Do(http.MethodGet, "/api/v1/users/2/avatar.png", nil); bytes.Equal(bob.Body, first.Body) {
+		t.Error("two users got the same avatar")
+	}
+
+	AssertStatus(t, h.Do(http.MethodGet, "/api/v1/users/1/avatar.png?size=100", nil), http.StatusBadRequest)
+	AssertStatus(t, h.Do(http.MethodGet, "/api/v1/users/99/avatar.png", nil), http.StatusNotFound)
+	AssertStatus(t, h.Do(http.MethodGet, "/api/v1/users/alice/avatar.png", nil), http.StatusNotFound)
+}
+
+func TestAvatarReplaceAndRemove(t *testing.T) {
+	h := newHarness(t)
+	alice := h.Register("Alice", "alice@example.com", "correct horse")
+	upload := func(content []byte) {
+		t.Helper()
+		body, contentType := Multipart(t, "avatar", "me.png", content)
+		AssertStatus(t, h.Do(http.MethodPut, avatarPath, body, WithToken(alice.Token), WithHeader("Content-Type", contentType)), http.StatusOK)
+	}
+	storedKeys := func() []string {
+		t.Helper()
+		blobs, err := h.Blobs.List(context.Background(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var keys []string
+		for _, blob := range blobs {
+			keys = append(keys, blob.Key)
+		}
+		return keys
+	}
+
+	upload(pngImage(t, 16, 16))
+	first := storedKeys()
+	if len(first) != 4 {
+		t.Fatalf("stored %v, want the avatar and 3 thumbnails", first)
+	}
+
+	second := pngImage(t, 8, 8)
+	upload(second)
+	replaced := storedKeys()
+	if len(replaced) != 4 || slices.Contains(replaced, first[0]) {
+		t.Fatalf("stored %v after replacing %v, want only the new files", replaced, first)
+	}
+	// Uploading the same image again keeps its files.
+	upload(second)
+	if again := storedKeys(); !slices.Equal(again, replaced) {
+		t.Fatalf("stored %v after uploading the same avatar, want %v", again, replaced)
+	}
+
+	resp := h.Do(http.MethodDelete, "/api/v1/user/me/avatar", nil, WithToken(alice.Token))
+	AssertStatus(t, resp, http.StatusOK)
+	h.AssertGolden("user_me", resp)
+	if keys := storedKeys(); len(keys) != 0 {
+		t.Errorf("stored %v after removing the avatar", keys)
+	}
+	AssertStatus(t, h.Do(http.MethodDelete, "/api/v1/user/me/avatar", nil, WithToken(alice.Token)), http.StatusOK)
+	AssertStatus(t, h.Do(http.MethodDelete, "/api/v1/user/me/avatar", nil), http.StatusUnauthorized)
+}
+
+func TestDeleteOrphanedAvatars(t *testing.T) {
+	h := newHarness(t)
+	alice := h.Register("Alice", "alice@example.com", "correct horse")
+	body, contentType := Multipart(t, "avatar", "me.png", pngImage(t, 16, 16))
+	AssertStatus(t, h.Do(http.MethodPut, avatarPath, body, WithToken(alice.Token), WithHeader("Content-Type", contentType)), http.StatusOK)
+
+	ctx := context.Background()
+	orphans := []string{"avatars/1/0123456789abcdef.png", "avatars/42/0123456789abcdef.png", "avatars/stray.txt"}
+	for _, key := range orphans {
+		if _, err := h.Blobs.Put(ctx, key, bytes.NewReader([]byte("orphan")), 6, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found, err := h.Container.UserService.DeleteOrphanedAvatars(ctx, time.Hour, false)
+	if err != nil || len(found) != 0 {
+		t.Fatalf("deleted %v, %v; want nothing, every file is younger than an hour", found, err)
+	}
+	found, err = h.Container.UserService.DeleteOrphanedAvatars(ctx, 0, true)
+	if err != nil || !slices.Equal(found, orphans) {
+		t.Fatalf("found %v, %v; want %v", found, err, orphans)
+	}
+	found, err = h.Container.UserService.DeleteOrphanedAvatars(ctx, 0, false)
+	if err != nil || !slices.Equal(found, orphans) {
+		t.Fatalf("deleted %v, %v; want %v", found, err, orphans)
+	}
+
+	blobs, err := h.Blobs.List(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 4 {
+		t.Errorf("%d files are left, want the avatar of alice and its thumbnails", len(blobs))
+	}
+}
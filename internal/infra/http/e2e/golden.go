@@ -0,0 +1,96 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+const masked = "<masked>"
+
+// AssertGolden compares the JSON body of resp with testdata/<name>.golden.json.
+// Run the tests with UPDATE_GOLDEN=1 to rewrite the file instead.
+//
+// Values that change between runs are masked: tokens always, and on the
+// Postgres backend also timestamps, cursors and search snippets and ranks,
+// which depend on the database clock and text search configuration.
+func (h *Harness) AssertGolden(name string, resp Response) {
+	h.t.Helper()
+
+	var body any
+	if err := json.Unmarshal(resp.Body, &body); err != nil {
+		h.t.Fatalf("decoding %s: %v", resp.Body, err)
+	}
+	keys := map[string]bool{"token": true}
+	if h.Backend == BackendPostgres {
+		for _, key := range []string{"created_at", "duration_ms", "next_cursor", "title_snippet", "description_snippet", "rank"} {
+			keys[key] = true
+		}
+	}
+	body = mask(body, keys)
+
+	got, err := encode(body)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+
+	file := filepath.Join("testdata", name+".golden.json")
+	if os.Getenv("UPDATE_GOLDEN") != "" {
+		if h.Backend == BackendPostgres {
+			h.t.Fatal("golden files are written from the memory backend, unset TEST_DATABASE_URL")
+		}
+		if err = os.WriteFile(file, got, 0o644); err != nil {
+			h.t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(file)
+	if err != nil {
+		h.t.Fatalf("%v; run with UPDATE_GOLDEN=1 to create it", err)
+	}
+	if h.Backend == BackendPostgres {
+		var wantBody any
+		if err = json.Unmarshal(want, &wantBody); err != nil {
+			h.t.Fatal(err)
+		}
+		if want, err = encode(mask(wantBody, keys)); err != nil {
+			h.t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(got, want) {
+		h.t.Errorf("%s differs from the golden file\ngot:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+func mask(value any, keys map[string]bool) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if keys[key] && item != nil {
+				v[key] = masked
+			} else {
+				v[key] = mask(item, keys)
+			}
+		}
+	case []any:
+		for i := range v {
+			v[i] = mask(v[i], keys)
+		}
+	}
+	return value
+}
+
+// encode writes indented JSON without escaping HTML, so that search
+// highlights stay readable.
+func encode(value any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,317 @@
+// Package e2e drives the real router, middlewares, controllers and
+// services through net/http/httptest. Repositories are in memory by
+// default, or live in a throwaway Postgres schema when TEST_DATABASE_URL is
+// set. Files go to a local blob store in a temporary directory and the
+// in-memory clock is fixed.
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-rest-api/config"
+	"go-rest-api/config/container"
+	"go-rest-api/internal/infra/database/repositories/memory"
+	"go-rest-api/internal/infra/filesystem"
+	"go-rest-api/internal/infra/health"
+	apphttp "go-rest-api/internal/infra/http"
+	"go-rest-api/internal/infra/idempotency"
+	"go-rest-api/internal/infra/ratelimit"
+	"go-rest-api/internal/infra/scanning"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	BackendMemory   = "memory"
+	BackendPostgres = "postgres"
+)
+
+// Epoch is the time the fixed clock starts at.
+var Epoch = time.Date(2024, time.January, 2, 3, 4, 5, 0, time.UTC)
+
+// Harness serves requests through the router built from a container with
+// test dependencies. Every harness starts with empty repositories.
+type Harness struct {
+	t         testing.TB
+	Backend   string
+	Config    config.Configuration
+	Container container.Container
+	Handler   http.Handler
+	Blobs     filesystem.BlobStore
+	Clock     *Clock
+
+	mu     sync.Mutex
+	routes map[string]bool
+}
+
+type Option func(*options)
+
+type options struct {
+	configure []func(*config.Configuration)
+	scanner   scanning.Scanner
+}
+
+// WithConfig changes the configuration before the container is built.
+func WithConfig(configure func(cfg *config.Configuration)) Option {
+	return func(o *options) {
+		o.configure = append(o.configure, configure)
+	}
+}
+
+// WithScanner replaces the scanner, which reports every file clean by
+// default.
+func WithScanner(scanner scanning.Scanner) Option {
+	return func(o *options) {
+		o.scanner = scanner
+	}
+}
+
+func New(t testing.TB, opts ...Option) *Harness {
+	t.Helper()
+
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cfg, err := config.Defaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.JwtSecret = "e2e-secret-that-is-at-least-32-bytes-long"
+	cfg.RateLimitAuthRequests = 1000
+	cfg.RateLimitApiRequests = 1000
+	cfg.RateLimitIpRequests = 1000
+	for _, configure := range o.configure {
+		configure(&cfg)
+	}
+
+	h := &Harness{
+		t:      t,
+		Config: cfg,
+		Clock:  NewClock(Epoch),
+		routes: map[string]bool{},
+	}
+
+	var deps container.Dependencies
+	if databaseUrl := os.Getenv("TEST_DATABASE_URL"); databaseUrl != "" {
+		h.Backend = BackendPostgres
+		deps = postgresDependencies(t, databaseUrl, cfg)
+	} else {
+		h.Backend = BackendMemory
+		db := memory.NewDB()
+		db.Now = h.Clock.Now
+		deps = container.Dependencies{
+			UserRepository:       memory.NewUserRepository(db),
+			SessionRepository:    memory.NewSessionRepository(db),
+			ProjectRepository:    memory.NewProjectRepository(db),
+			AttachmentRepository: memory.NewAttachmentRepository(db),
+			SearchRepository:     memory.NewSearchRepository(db),
+			IdempotencyStore:     idempotency.NewMemoryStore(cfg.ServerWriteTimeout),
+			HealthChecks: []health.Check{
+				{Name: "database", Run: func(context.Context) error { return nil }},
+			},
+		}
+	}
+	h.Blobs, err = filesystem.NewLocalBlobStore(t.TempDir(), cfg.StoragePublicUrl, filesystem.NewURLSigner(cfg.SigningKey(), filesystem.SignedPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deps.BlobStore = h.Blobs
+	deps.Scanner = o.scanner
+	if deps.Scanner == nil {
+		deps.Scanner = scanning.NewNoopScanner()
+	}
+	if cfg.RateLimitStore != "none" {
+		deps.RateLimitStore = ratelimit.NewMemoryStore()
+	}
+
+	h.Container = container.NewWithDependencies(cfg, deps)
+	h.Handler = apphttp.CreateRouter(h.Container)
+	return h
+}
+
+// Response is a recorded response.
+type Response struct {
+	Code   int
+	Header http.Header
+	Body   []byte
+}
+
+// JSON decodes the body into v, failing the test if it is not JSON.
+func (r Response) JSON(t testing.TB, v any) {
+	t.Helper()
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		t.Fatalf("decoding %s: %v", r.Body, err)
+	}
+}
+
+type RequestOption func(*http.Request)
+
+// WithToken authenticates the request with a bearer token.
+func WithToken(token string) RequestOption {
+	return WithHeader("Authorization", "Bearer "+token)
+}
+
+func WithHeader(name, value string) RequestOption {
+	return func(r *http.Request) {
+		r.Header.Set(name, value)
+	}
+}
+
+// WithRemoteAddr sends the request from addr instead of 192.0.2.1.
+func WithRemoteAddr(addr string) RequestOption {
+	return func(r *http.Request) {
+		r.RemoteAddr = addr
+	}
+}
+
+// Do serves a request. A non-nil body is sent as JSON unless it is
+// already a string or []byte.
+func (h *Harness) Do(method, target string, body any, opts ...RequestOption) Response {
+	h.t.Helper()
+
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = bytes.NewBufferString(b)
+	case []byte:
+		reader = bytes.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			h.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, target, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	// A route context set up front is reused by chi, which leaves the
+	// matched pattern readable after the request.
+	rctx := chi.NewRouteContext()
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	rec := httptest.NewRecorder()
+	h.Handler.ServeHTTP(rec, req)
+
+	if pattern := rctx.RoutePattern(); pattern != "" {
+		h.mu.Lock()
+		h.routes[method+" "+pattern] = true
+		h.mu.Unlock()
+	}
+
+	return Response{
+		Code:   rec.Code,
+		Header: rec.Header(),
+		Body:   rec.Body.Bytes(),
+	}
+}
+
+// Multipart encodes content as the file field of a multipart form. It
+// returns the body and the Content-Type header to send it with.
+func Multipart(t testing.TB, field, filename string, content []byte) ([]byte, string) {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile(field, filename)
+	if err == nil {
+		_, err = part.Write(content)
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body.Bytes(), writer.FormDataContentType()
+}
+
+// Routes lists the "METHOD pattern" of every route served so far.
+func (h *Harness) Routes() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	routes := make([]string, 0, len(h.routes))
+	for route := range h.routes {
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// Session is a registered or logged in user.
+type Session struct {
+	UserId uint64
+	Token  string
+}
+
+type sessionResponse struct {
+	Token string `json:"token"`
+	User  struct {
+		Id uint64 `json:"id"`
+	} `json:"user"`
+}
+
+// Register signs up a user and returns their session.
+func (h *Harness) Register(name, email, password string) Session {
+	h.t.Helper()
+	resp := h.Do(http.MethodPost, "/api/v1/auth/register", map[string]string{
+		"name":     name,
+		"email":    email,
+		"password": password,
+	})
+	return h.session(resp)
+}
+
+// Login opens a new session for a registered user.
+func (h *Harness) Login(email, password string) Session {
+	h.t.Helper()
+	resp := h.Do(http.MethodPost, "/api/v1/auth/login", map[string]string{
+		"email":    email,
+		"password": password,
+	})
+	return h.session(resp)
+}
+
+func (h *Harness) session(resp Response) Session {
+	h.t.Helper()
+	if resp.Code != http.StatusOK {
+		h.t.Fatalf("status = %d, want 200: %s", resp.Code, resp.Body)
+	}
+	var body sessionResponse
+	resp.JSON(h.t, &body)
+	if body.Token == "" {
+		h.t.Fatalf("no token in %s", resp.Body)
+	}
+	return Session{UserId: body.User.Id, Token: body.Token}
+}
+
+// AssertStatus fails the test unless resp has status code.
+func AssertStatus(t testing.TB, resp Response, code int) {
+	t.Helper()
+	if resp.Code != code {
+		t.Fatalf("status = %d, want %d: %s", resp.Code, code, resp.Body)
+	}
+}
+
+// Path formats a request target, for readability at call sites.
+func Path(format string, args ...any) string {
+	return fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,88 @@
+package e2e
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"go-rest-api/config"
+	"go-rest-api/config/container"
+	"go-rest-api/internal/infra/database/repositories"
+	"go-rest-api/internal/infra/health"
+	"go-rest-api/internal/infra/idempotency"
+	"go-rest-api/migrations"
+	"net/url"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	_ "github.com/lib/pq"
+)
+
+// postgresDependencies migrates a new schema in the database at
+// databaseUrl and returns repositories using it. The schema is dropped
+// when the test ends.
+func postgresDependencies(t testing.TB, databaseUrl string, cfg config.Configuration) container.Dependencies {
+	t.Helper()
+
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		t.Fatal(err)
+	}
+	schema := "e2e_" + hex.EncodeToString(suffix)
+
+	admin, err := sql.Open("postgres", databaseUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	if _, err = admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`); err != nil {
+			t.Errorf("dropping schema %s: %v", schema, err)
+		}
+	})
+
+	schemaUrl, err := url.Parse(databaseUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := schemaUrl.Query()
+	query.Set("search_path", schema)
+	schemaUrl.RawQuery = query.Encode()
+
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.NewWithSourceInstance("iofs", src, schemaUrl.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatal(err)
+	}
+	m.Close()
+
+	db, err := sql.Open("postgres", schemaUrl.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return container.Dependencies{
+		UserRepository:       repositories.NewUserRepository(db),
+		SessionRepository:    repositories.NewSessionRepository(db),
+		ProjectRepository:    repositories.NewProjectRepository(db),
+		AttachmentRepository: repositories.NewAttachmentRepository(db),
+		SearchRepository:     repositories.NewSearchRepository(db, cfg.SearchLanguage),
+		IdempotencyStore:     idempotency.NewPostgresStore(db, cfg.ServerWriteTimeout),
+		HealthChecks: []health.Check{
+			{Name: "database", Run: func(ctx context.Context) error { return db.PingContext(ctx) }},
+		},
+	}
+}
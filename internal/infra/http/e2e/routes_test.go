@@ -0,0 +1,560 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"go-rest-api/config"
+	apphttp "go-rest-api/internal/infra/http"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var (
+	harnessesMu sync.Mutex
+	harnesses   []*Harness
+)
+
+// newHarness builds a harness whose served routes count towards the
+// coverage check in TestMain.
+func newHarness(t *testing.T, opts ...Option) *Harness {
+	h := New(t, opts...)
+	harnessesMu.Lock()
+	harnesses = append(harnesses, h)
+	harnessesMu.Unlock()
+	return h
+}
+
+// TestMain fails the run when a registered route is not exercised by any
+// test. The check is skipped when only some tests run.
+func TestMain(m *testing.M) {
+	flag.Parse()
+	code := m.Run()
+	if code == 0 && flag.Lookup("test.run").Value.String() == "" {
+		if missing := uncoveredRoutes(); len(missing) > 0 {
+			fmt.Fprintf(os.Stderr, "routes without an end-to-end test:\n  %s\n", strings.Join(missing, "\n  "))
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+func uncoveredRoutes() []string {
+	served := map[string]bool{}
+	harnessesMu.Lock()
+	for _, h := range harnesses {
+		for _, route := range h.Routes() {
+			served[route] = true
+		}
+	}
+	if len(harnesses) == 0 {
+		harnessesMu.Unlock()
+		return nil
+	}
+	// Every harness serves the same routes.
+	router := harnesses[0].Handler
+	harnessesMu.Unlock()
+
+	var missing []string
+	_ = chi.Walk(router.(chi.Routes), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		route = strings.ReplaceAll(route, "/*/", "/")
+		if len(route) > 1 {
+			route = strings.TrimSuffix(route, "/")
+		}
+		// Catch-all handlers are registered for every method; one is enough.
+		if strings.HasSuffix(route, "/*") && method != http.MethodGet {
+			return nil
+		}
+		if !served[method+" "+route] {
+			missing = append(missing, method+" "+route)
+		}
+		return nil
+	})
+	sort.Strings(missing)
+	return missing
+}
+
+func TestHealth(t *testing.T) {
+	h := newHarness(t)
+
+	resp := h.Do(http.MethodGet, "/healthz", nil)
+	AssertStatus(t, resp, http.StatusOK)
+	h.AssertGolden("healthz", resp)
+
+	resp = h.Do(http.MethodGet, "/readyz", nil)
+	AssertStatus(t, resp, http.StatusOK)
+	h.AssertGolden("readyz", resp)
+}
+
+func TestMetrics(t *testing.T) {
+	h := newHarness(t)
+	h.Do(http.MethodGet, "/api/ping", nil)
+
+	// Metrics are only served by the admin listener.
+	AssertStatus(t, h.Do(http.MethodGet, "/metrics", nil), http.StatusNotFound)
+	rec := httptest.NewRecorder()
+	apphttp.CreateAdminRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if !strings.Contains(rec.Body.String(), "tasks_http_requests_total") {
+		t.Errorf("metrics do not include tasks_http_requests_total:\n%s", rec.Body)
+	}
+}
+
+func TestApiDocs(t *testing.T) {
+	h := newHarness(t)
+
+	resp := h.Do(http.MethodGet, "/api/openapi.json", nil)
+	AssertStatus(t, resp, http.StatusOK)
+	h.AssertGolden("openapi", resp)
+
+	resp = h.Do(http.MethodGet, "/api/docs", nil)
+	AssertStatus(t, resp, http.StatusOK)
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/html") {
+		t.Errorf("Content-Type = %q, want text/html", contentType)
+	}
+	// The page works offline: it loads nothing but the API document.
+	if bytes.Contains(resp.Body, []byte("src=")) || bytes.Contains(resp.Body, []byte("href=")) {
+		t.Error("the docs page loads external resources")
+	}
+	if policy := resp.Header.Get("Content-Security-Policy"); !strings.Contains(policy, "script-src 'sha256-") || !strings.Contains(policy, "style-src 'sha256-") {
+		t.Errorf("Content-Security-Policy = %q, want the inline script and style allowed by hash", policy)
+	}
+}
+
+func TestPing(t *testing.T) {
+	h := newHarness(t)
+
+	AssertStatus(t, h.Do(http.MethodGet, "/api/ping", nil), http.StatusOK)
+
+	resp := h.Do(http.MethodGet, "/api/ping/unknown", nil)
+	AssertStatus(t, resp, http.StatusNotFound)
+	h.AssertGolden("ping_not_found", resp)
+}
+
+func TestAuth(t *testing.T) {
+	h := newHarness(t)
+
+	resp := h.Do(http.MethodPost, "/api/v1/auth/register", map[string]string{
+		"name":     "Alice",
+		"email":    "alice@example.com",
+		"password": "correct horse",
+	})
+	AssertStatus(t, resp, http.StatusOK)
+	h.AssertGolden("register", resp)
+
+	resp = h.Do(http.MethodPost, "/api/v1/auth/register", map[string]string{"email": "bob@example.com"})
+	AssertStatus(t, resp, http.StatusBadRequest)
+
+	resp = h.Do(http.MethodPost, "/api/v1/auth/login", map[string]string{
+		"email":    "alice@example.com",
+		"password": "wrong",
+	})
+	if resp.Code < 400 {
+		t.Fatalf("login with a wrong password: status = %d", resp.Code)
+	}
+
+	resp = h.Do(http.MethodPost, "/api/v1/auth/login", map[string]string{
+		"email":    "alice@example.com",
+		"password": "correct horse",
+	})
+	AssertStatus(t, resp, http.StatusOK)
+	h.AssertGolden("login", resp)
+	var session sessionResponse
+	resp.JSON(t, &session)
+
+	AssertStatus(t, h.Do(http.MethodDelete, "/api/v1/auth/logout", nil, WithToken(session.Token)), http.StatusOK)
+	AssertStatus(t, h.Do(http.MethodGet, "/api/v1/user/me", nil, WithToken(session.Token)), http.StatusUnauthorized)
+	AssertStatus(t, h.Do(http.MethodDelete, "/api/v1/auth/logout", nil), http.StatusUnauthorized)
+}
+
+func TestUser(t *testing.T) {
+	h := newHarness(t)
+	alice := h.Register("Alice", "alice@example.com", "correct horse")
+
+	resp := h.Do(http.MethodGet, "/api/v1/user/me", nil, WithToken(alice.Token))
+	AssertStatus(t, resp, http.StatusOK)
+	h.AssertGolden("user_me", resp)
+
+	AssertStatus(t, h.Do(http.MethodGet, "/api/v1/user/me", nil), http.StatusUnauthorized)
+}
+
+func TestMyProjects(t *testing.T) {
+	h := newHarness(t)
+	alice := h.Register("Alice", "alice@example.com", "correct horse")
+	bob := h.Register("Bob", "bob@example.com", "battery staple")
+
+	for i := 1; i <= 3; i++ {
+		AssertStatus(t, h.Do(http.MethodPost, "/api/v1/project", map[string]string{
+			"title":       fmt.Sprintf("Alice project %d", i),
+			"description": "Owned by Alice",
+		}, WithToken(alice.Token)), http.StatusOK)
+	}
+	AssertStatus(t, h.Do(http.MethodPost, "/api/v1/project", map[string]string{
+		"title": "Bob project",
+	}, WithToken(bob.Token)), http.StatusOK)
+
+	resp := h.Do(http.MethodGet, "/api/v1/user/me/projects?limit=2&with_total=true", nil, WithToken(alice.Token))
+	AssertStatus(t, resp, http.StatusOK)
+	h.AssertGolden("my_projects_page_1", resp)
+
+	var page struct {
+		NextCursor *string `json:"next_cursor"`
+	}
+	resp.JSON(t, &page)
+	if page.NextCursor == nil {
+		t.Fatal("first page has no next_cursor")
+	}
+	if link := resp.Header.Get("Link"); !strings.Contains(link, `rel="next"`) {
+		t.Errorf("Link = %q, want a next link", link)
+	}
+
+	resp = h.Do(http.MethodGet, "/api/v1/user/me/projects?limit=2&cursor="+*page.NextCursor, nil, WithToken(alice.Token))
+	AssertStatus(t, resp, http.StatusOK)
+	h.AssertGolden("my_projects_page_2", resp)
+
+	AssertStatus(t, h.Do(http.MethodGet, "/api/v1/user/me/projects?sort=owner", nil, WithToken(alice.Token)), http.StatusBadRequest)
+}
+
+func TestProjects(t *testing.T) {
+	h := newHarness(t)
+	alice := h.Register("Alice", "alice@example.com", "correct horse")
+	bob := h.Register("Bob", "bob@example.com", "battery staple")
+
+	resp := h.Do(http.MethodPost, "/api/v1/project", map[string]string{
+		"title":       "Garden",
+		"description": "Plant tomatoes",
+	}, WithToken(alice.Token))
+	AssertStatus(t, resp, http.StatusOK)
+	h.AssertGolden("project_create", resp)
+	var project struct {
+		Id uint64 `json:"id"`
+	}
+	resp.JSON(t, &project)
+	projectPath := Path("/api/v1/project/%d", project.Id)
+
+	AssertStatus(t, h.Do(http.MethodPost, "/api/v1/project", map[string]string{}, WithToken(alice.Token)), http.StatusBadRequest)
+
+	resp = h.Do(http.MethodGet, projectPath, nil, WithToken(bob.Token))
+	AssertStatus(t, resp, http.StatusOK)
+	h.AssertGolden("project_get", resp)
+
+	AssertStatus(t, h.Do(http.MethodGet, "/api/v1/project/abc", nil, WithToken(alice.Token)), http.StatusBadRequest)
+
+	update := map[string]string{"title": "Vegetable garden", "description": "Plant tomatoes and beans"}
+	AssertStatus(t, h.Do(http.MethodPut, projectPath, update, WithToken(bob.Token)), http.StatusForbidden)
+
+	resp = h.Do(http.MethodPut, projectPath, update, WithToken(alice.Token))
+	AssertStatus(t, resp, http.StatusOK)
+	h.AssertGolden("project_update", resp)
+
+	AssertStatus(t, h.Do(http.MethodDelete, projectPath, nil, WithToken(bob.Token)), http.StatusForbidden)
+	AssertStatus(t, h.Do(http.MethodDelete, projectPath, nil, WithToken(alice.Token)), http.StatusOK)
+
+	// A missing project is reported by the service as an internal error.
+	AssertStatus(t, h.Do(http.MethodGet, projectPath, nil, WithToken(alice.Token)), http.StatusInternalServerError)
+	AssertStatus(t, h.Do(http.MethodGet, projectPath, nil), http.StatusUnauthorized)
+}
+
+func TestProjectArchive(t *testing.T) {
+	h := newHarness(t)
+	alice := h.Register("Alice", "alice@example.com", "correct horse")
+	bob := h.Register("Bob", "bob@example.com", "battery staple")
+	projectPath := createProject(t, h, alice.Token, "Garden")
+	createProject(t, h, alice.Token, "Taxes")
+	body, contentType := Multipart(t, "file", "plan.txt", []byte("tomatoes"))
+	upload := WithHeader("Content-Type", contentType)
+	resp := h.Do(http.MethodPost, projectPath+"/attachments", body, WithToken(alice.Token), upload)
+	AssertStatus(t, resp, http.StatusCreated)
+	var attachment attachmentResponse
+	resp.JSON(t, &attachment)
+
+	AssertStatus(t, h.Do(http.MethodPost, projectPath+"/archive", nil, WithToken(bob.Token)), http.StatusForbidden)
+	resp = h.Do(http.MethodPost, projectPath+"/archive", nil, WithToken(alice.Token))
+	AssertStatus(t, resp, http.StatusOK)
+	h.AssertGolden("project_archive", resp)
+
+	titles := func(query string) []string {
+		t.Helper()
+		resp := h.Do(http.MethodGet, "/api/v1/user/me/projects?sort=title"+query, nil, WithToken(alice.Token))
+		AssertStatus(t, resp, http.StatusOK)
+		var page struct {
+			Projects []struct {
+				Title string `json:"title"`
+			} `json:"projects"`
+		}
+		resp.JSON(t, &page)
+		var titles []string
+		for _, p := range page.Projects {
+			titles = append(titles, p.Title)
+		}
+		return titles
+	}
+	for query, want := range map[string]string{
+		"":                  "[Taxes]",
+		"&archived=exclude": "[Taxes]",
+		"&archived=include": "[Garden Taxes]",
+		"&archived=only":    "[Garden]",
+	} {
+		if got := fmt.Sprint(titles(query)); got != want {
+			t.Errorf("projects%s = %s, want %s", query, got, want)
+		}
+	}
+	AssertStatus(t, h.Do(http.MethodGet, "/api/v1/user/me/projects?archived=all", nil, WithToken(alice.Token)), http.StatusBadRequest)
+
+	// Archived projects stay readable but refuse changes.
+	AssertStatus(t, h.Do(http.MethodGet, projectPath, nil, WithToken(bob.Token)), http.StatusOK)
+	AssertStatus(t, h.Do(http.MethodGet, attachment.Url, nil, WithToken(bob.Token)), http.StatusOK)
+	update := map[string]string{"title": "Vegetable garden"}
+	resp = h.Do(http.MethodPut, projectPath, update, WithToken(alice.Token))
+	AssertStatus(t, resp, http.StatusConflict)
+	h.AssertGolden("project_archived_update", resp)
+	AssertStatus(t, h.Do(http.MethodPost, projectPath+"/attachments", body, WithToken(alice.Token), upload), http.StatusConflict)
+	AssertStatus(t, h.Do(http.MethodDelete, attachment.Url, nil, WithToken(alice.Token)), http.StatusConflict)
+
+	AssertStatus(t, h.Do(http.MethodPost, projectPath+"/unarchive", nil, WithToken(bob.Token)), http.StatusForbidden)
+	resp = h.Do(http.MethodPost, projectPath+"/unarchive", nil, WithToken(alice.Token))
+	AssertStatus(t, resp, http.StatusOK)
+	var project struct {
+		ArchivedAt *string `json:"archived_at"`
+	}
+	resp.JSON(t, &project)
+	if project.ArchivedAt != nil {
+		t.Errorf("unarchived project has archived_at %s", *project.ArchivedAt)
+	}
+	AssertStatus(t, h.Do(http.MethodPut, projectPath, update, WithToken(alice.Token)), http.StatusOK)
+	if got := fmt.Sprint(titles("")); got != "[Taxes Vegetable garden]" {
+		t.Errorf("projects after unarchiving = %s", got)
+	}
+
+	// Archived projects are hidden from search unless asked for.
+	AssertStatus(t, h.Do(http.MethodPost, projectPath+"/archive", nil, WithToken(alice.Token)), http.StatusOK)
+	for query, want := range map[string]int{"": 0, "&archived=include": 1, "&archived=only": 1} {
+		resp = h.Do(http.MethodGet, "/api/v1/search?q=vegetable"+query, nil, WithToken(alice.Token))
+		AssertStatus(t, resp, http.StatusOK)
+		var results struct {
+			Total int `json:"total"`
+		}
+		resp.JSON(t, &results)
+		if results.Total != want {
+			t.Errorf("search%s found %d projects, want %d", query, results.Total, want)
+		}
+	}
+
+	// Deleting is a change too, the project has to be unarchived first.
+	AssertStatus(t, h.Do(http.MethodDelete, projectPath, nil, WithToken(alice.Token)), http.StatusConflict)
+	AssertStatus(t, h.Do(http.MethodPost, projectPath+"/unarchive", nil, WithToken(alice.Token)), http.StatusOK)
+	AssertStatus(t, h.Do(http.MethodDelete, projectPath, nil, WithToken(alice.Token)), http.StatusOK)
+	AssertStatus(t, h.Do(http.MethodPost, projectPath+"/archive", nil, WithToken(alice.Token)), http.StatusNotFound)
+}
+
+func TestSearch(t *testing.T) {
+	h := newHarness(t)
+	alice := h.Register("Alice", "alice@example.com", "correct horse")
+
+	for _, project := range []map[string]string{
+		{"title": "Garden", "description": "Plant tomatoes"},
+		{"title": "Tomato sauce", "description": "Cook the garden tomatoes"},
+		{"title": "Taxes", "description": "File before April"},
+	} {
+		AssertStatus(t, h.Do(http.MethodPost, "/api/v1/project", project, WithToken(alice.Token)), http.StatusOK)
+	}
+
+	resp := h.Do(http.MethodGet, "/api/v1/search?q=tomato", nil, WithToken(alice.Token))
+	AssertStatus(t, resp, http.StatusOK)
+	h.AssertGolden("search", resp)
+
+	AssertStatus(t, h.Do(http.MethodGet, "/api/v1/search", nil, WithToken(alice.Token)), http.StatusBadRequest)
+}
+
+func TestRateLimit(t *testing.T) {
+	h := newHarness(t, WithConfig(func(cfg *config.Configuration) {
+		cfg.RateLimitAuthRequests = 2
+	}))
+
+	login := map[string]string{"email": "nobody@example.com", "password": "secret"}
+	for i := 0; i < 2; i++ {
+		resp := h.Do(http.MethodPost, "/api/v1/auth/login", login)
+		if resp.Code == http.StatusTooManyRequests {
+			t.Fatalf("request %d was rate limited", i+1)
+		}
+	}
+
+	resp := h.Do(http.MethodPost, "/api/v1/auth/login", login)
+	AssertStatus(t, resp, http.StatusTooManyRequests)
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("429 response has no Retry-After")
+	}
+	h.AssertGolden("rate_limited", resp)
+}
+
+func TestHstsBehindProxy(t *testing.T) {
+	h := newHarness(t, WithConfig(func(cfg *config.Configuration) {
+		cfg.TrustedProxies = []string{"10.0.0.0/8"}
+	}))
+
+	hsts := func(remoteAddr, forwardedProto string) bool {
+		t.Helper()
+		resp := h.Do(http.MethodGet, "/api/ping", nil, WithRemoteAddr(remoteAddr), WithHeader("X-Forwarded-Proto", forwardedProto))
+		AssertStatus(t, resp, http.StatusOK)
+		return resp.Header.Get("Strict-Transport-Security") != ""
+	}
+
+	if !hsts("10.0.0.2:4000", "https") {
+		t.Error("no HSTS for HTTPS forwarded by a trusted proxy")
+	}
+	// The nearest proxy appends the protocol it received.
+	if hsts("10.0.0.2:4000", "https, http") {
+		t.Error("HSTS for plain HTTP after a client sent X-Forwarded-Proto")
+	}
+	if hsts("192.0.2.7:4000", "https") {
+		t.Error("HSTS for X-Forwarded-Proto sent by an untrusted peer")
+	}
+}
+
+func TestRateLimitBehindProxy(t *testing.T) {
+	h := newHarness(t, WithConfig(func(cfg *config.Configuration) {
+		cfg.RateLimitAuthRequests = 1
+		cfg.TrustedProxies = []string{"10.0.0.0/8"}
+	}))
+
+	login := map[string]string{"email": "nobody@example.com", "password": "secret"}
+	from := func(remoteAddr, forwardedFor string) int {
+		t.Helper()
+		return h.Do(http.MethodPost, "/api/v1/auth/login", login,
+			WithRemoteAddr(remoteAddr), WithHeader("X-Forwarded-For", forwardedFor)).Code
+	}
+
+	// Clients behind the proxy are limited one by one.
+	if code := from("10.0.0.2:4000", "203.0.113.1"); code == http.StatusTooManyRequests {
+		t.Fatal("first request was rate limited")
+	}
+	if code := from("10.0.0.2:4000", "203.0.113.2, 10.0.0.3"); code == http.StatusTooManyRequests {
+		t.Error("another client behind the proxies was rate limited")
+	}
+	if code := from("10.0.0.2:4000", "203.0.113.1"); code != http.StatusTooManyRequests {
+		t.Errorf("second request of a client = %d, want 429", code)
+	}
+	// Only the hops added by trusted proxies are believed.
+	if code := from("10.0.0.2:4000", "198.51.100.9, 203.0.113.1"); code != http.StatusTooManyRequests {
+		t.Errorf("client prepending an address = %d, want 429", code)
+	}
+	if code := from("192.0.2.7:4000", "203.0.113.5"); code == http.StatusTooManyRequests {
+		t.Fatal("first request of an untrusted peer was rate limited")
+	}
+	if code := from("192.0.2.7:4000", "203.0.113.6"); code != http.StatusTooManyRequests {
+		t.Errorf("untrusted peer forging X-Forwarded-For = %d, want 429", code)
+	}
+}
+
+func TestRateLimitBeforeAuthentication(t *testing.T) {
+	h := newHarness(t, WithConfig(func(cfg *config.Configuration) {
+		cfg.RateLimitIpRequests = 2
+	}))
+
+	for i := 0; i < 2; i++ {
+		AssertStatus(t, h.Do(http.MethodGet, "/api/v1/user/me", nil, WithToken("invalid")), http.StatusUnauthorized)
+	}
+	AssertStatus(t, h.Do(http.MethodGet, "/api/v1/project/1", nil, WithToken("invalid")), http.StatusTooManyRequests)
+	AssertStatus(t, h.Do(http.MethodGet, "/api/v1/search?q=garden", nil, WithRemoteAddr("192.0.2.8:4000"), WithToken("invalid")), http.StatusUnauthorized)
+}
+
+func TestIdempotency(t *testing.T) {
+	h := newHarness(t)
+	alice := h.Register("Alice", "alice@example.com", "correct horse")
+
+	body := map[string]string{"title": "Garden"}
+	key := WithHeader("Idempotency-Key", "create-garden")
+	first := h.Do(http.MethodPost, "/api/v1/project", body, WithToken(alice.Token), key)
+	AssertStatus(t, first, http.StatusOK)
+
+	replay := h.Do(http.MethodPost, "/api/v1/project", body, WithToken(alice.Token), key)
+	AssertStatus(t, replay, http.StatusOK)
+	if replay.Header.Get("Idempotent-Replayed") != "true" {
+		t.Error("retry was not replayed")
+	}
+	if string(replay.Body) != string(first.Body) {
+		t.Errorf("replayed body = %s, want %s", replay.Body, first.Body)
+	}
+
+	resp := h.Do(http.MethodPost, "/api/v1/project", map[string]string{"title": "Taxes"}, WithToken(alice.Token), key)
+	AssertStatus(t, resp, http.StatusConflict)
+	h.AssertGolden("idempotency_conflict", resp)
+
+	// The query string is part of the request.
+	resp = h.Do(http.MethodPost, "/api/v1/project?draft=true", body, WithToken(alice.Token), key)
+	AssertStatus(t, resp, http.StatusConflict)
+
+	resp = h.Do(http.MethodGet, "/api/v1/user/me/projects", nil, WithToken(alice.Token))
+	var projects struct {
+		Projects []any `json:"projects"`
+	}
+	resp.JSON(t, &projects)
+	if len(projects.Projects) != 1 {
+		t.Errorf("%d projects were created, want 1", len(projects.Projects))
+	}
+}
+
+func TestIdempotentUpload(t *testing.T) {
+	h := newHarness(t)
+	alice := h.Register("Alice", "alice@example.com", "correct horse")
+	attachmentsPath := createProject(t, h, alice.Token, "Garden") + "/attachments"
+
+	// Bodies this large are spooled to a file to be fingerprinted.
+	content := bytes.Repeat([]byte("0123456789abcdef"), 1<<14)
+	body, contentType := Multipart(t, "file", "large.txt", content)
+	upload := func(body []byte) Response {
+		return h.Do(http.MethodPost, attachmentsPath, body, WithToken(alice.Token),
+			WithHeader("Content-Type", contentType), WithHeader("Idempotency-Key", "upload-large"))
+	}
+	first := upload(body)
+	AssertStatus(t, first, http.StatusCreated)
+	replay := upload(body)
+	AssertStatus(t, replay, http.StatusCreated)
+	if replay.Header.Get("Idempotent-Replayed") != "true" || string(replay.Body) != string(first.Body) {
+		t.Errorf("retry was not replayed: %s", replay.Body)
+	}
+
+	changed := bytes.Clone(body)
+	changed[len(changed)/2] ^= 1
+	AssertStatus(t, upload(changed), http.StatusConflict)
+
+	blobs, err := h.Blobs.List(context.Background(), "attachments/")
+	if err != nil || len(blobs) != 1 {
+		t.Errorf("stored %+v, %v, want one file", blobs, err)
+	}
+}
+
+func TestBodyLimits(t *testing.T) {
+	h := newHarness(t)
+	alice := h.Register("Alice", "alice@example.com", "correct horse")
+	large := int(h.Config.RequestBodyLimit) + 1<<20
+
+	resp := h.Do(http.MethodPost, "/api/v1/project", map[string]string{
+		"title": strings.Repeat("x", large),
+	}, WithToken(alice.Token))
+	AssertStatus(t, resp, http.StatusRequestEntityTooLarge)
+
+	// Routes with a larger limit accept bodies over the default one.
+	attachmentsPath := createProject(t, h, alice.Token, "Garden") + "/attachments"
+	body, contentType := Multipart(t, "file", "large.bin", bytes.Repeat([]byte("x"), large))
+	resp = h.Do(http.MethodPost, attachmentsPath, body, WithToken(alice.Token), WithHeader("Content-Type", contentType))
+	AssertStatus(t, resp, http.StatusCreated)
+
+	avatar := noisyPng(t, 700, 700)
+	if len(avatar) <= int(h.Config.RequestBodyLimit) {
+		t.Fatalf("avatar of %d bytes is within the default limit", len(avatar))
+	}
+	body, contentType = Multipart(t, "avatar", "me.png", avatar)
+	resp = h.Do(http.MethodPut, avatarPath, body, WithToken(alice.Token), WithHeader("Content-Type", contentType))
+	AssertStatus(t, resp, http.StatusOK)
+}
@@ -0,0 +1,78 @@
+package middlewares
+
+import (
+	"context"
+	"go-rest-api/internal/infra/logger"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type requestInfoKey struct{}
+
+// requestInfo collects what inner middlewares learn about a request, so the
+// access log written on the way out can report it.
+type requestInfo struct {
+	userId uint64
+}
+
+func AccessLogMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		hfn := func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			info := &requestInfo{}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ctx := context.WithValue(r.Context(), requestInfoKey{}, info)
+
+			defer func() {
+				status := ww.Status()
+				if status == 0 {
+					status = http.StatusOK
+				}
+				fields := []interface{}{
+					"method", r.Method,
+					"path", r.URL.Path,
+					"route", routePattern(r),
+					"status", status,
+					"bytes", ww.BytesWritten(),
+					"latency", time.Since(start),
+					"remote_addr", r.RemoteAddr,
+				}
+				if info.userId != 0 {
+					fields = append(fields, "user_id", info.userId)
+				}
+
+				log := logger.FromContext(ctx)
+				switch {
+				case status >= http.StatusInternalServerError:
+					log.Errorw("http request", fields...)
+				case status >= http.StatusBadRequest:
+					log.Warnw("http request", fields...)
+				default:
+					log.Infow("http request", fields...)
+				}
+			}()
+
+			next.ServeHTTP(ww, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(hfn)
+	}
+}
+
+// routePattern reports the matched chi pattern, e.g. /api/v1/project/{projectId},
+// which keeps ids out of log aggregation keys.
+func routePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return ""
+	}
+	return rctx.RoutePattern()
+}
+
+func setRequestUserId(ctx context.Context, userId uint64) {
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		info.userId = userId
+	}
+}
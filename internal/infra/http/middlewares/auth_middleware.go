@@ -10,6 +10,7 @@ import (
 	"go-rest-api/internal/app"
 	"go-rest-api/internal/domain"
 	"go-rest-api/internal/infra/http/controllers"
+	"go-rest-api/internal/infra/logger"
 	"net/http"
 )
 
@@ -41,13 +42,13 @@ func AuthMiddleware(ja *jwtauth.JWTAuth, sessionServ app.SessionService, userSer
 				UserId: userId,
 				UUID:   userUuid,
 			}
-			err = sessionServ.Check(sess)
+			err = sessionServ.Check(ctx, sess)
 			if err != nil {
 				controllers.Unauthorized(w, err)
 				return
 			}
 
-			user, err := userServ.FindById(sess.UserId)
+			user, err := userServ.FindById(ctx, sess.UserId)
 			if err != nil {
 				if errors.Is(err, sql.ErrNoRows) {
 					err = errors.New("token is unauthorized")
@@ -55,8 +56,14 @@ func AuthMiddleware(ja *jwtauth.JWTAuth, sessionServ app.SessionService, userSer
 				controllers.Unauthorized(w, err)
 				return
 			}
+			if user.Disabled() {
+				controllers.Unauthorized(w, app.ErrUserDisabled)
+				return
+			}
 			ctx = context.WithValue(ctx, controllers.UserKey, user)
 			ctx = context.WithValue(ctx, controllers.SessionKey, sess)
+			ctx = logger.WithContext(ctx, logger.FromContext(ctx).With("user_id", user.Id))
+			setRequestUserId(ctx, user.Id)
 
 			next.ServeHTTP(w, r.WithContext(ctx))
 		}
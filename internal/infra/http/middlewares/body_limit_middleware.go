@@ -0,0 +1,78 @@
+package middlewares
+
+import (
+	"fmt"
+	"go-rest-api/internal/infra/http/controllers"
+	"io"
+	"net/http"
+)
+
+// limitedBody remembers the body it limits, so a route-level limit can
+// replace the router-wide one instead of being capped by it.
+type limitedBody struct {
+	io.ReadCloser
+	original io.ReadCloser
+	// tooLarge fails the first read of a body declared larger than the
+	// router-wide limit, unless a route-level limit replaced it first.
+	tooLarge error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.tooLarge != nil {
+		return 0, b.tooLarge
+	}
+	return b.ReadCloser.Read(p)
+}
+
+// BodyLimitMiddleware rejects requests whose body is larger than limit
+// bytes. Declared lengths are refused up front with 413; chunked bodies are
+// cut off while reading and controllers.BadRequest answers 413 as well.
+// It replaces the limit of DefaultBodyLimitMiddleware.
+func BodyLimitMiddleware(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		hfn := func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				controllers.RequestEntityTooLarge(w, fmt.Errorf("request body is larger than %d bytes", limit))
+				return
+			}
+			limitBody(w, r, limit)
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(hfn)
+	}
+}
+
+// DefaultBodyLimitMiddleware limits bodies router-wide. Routes are matched
+// after it runs, so it cannot refuse declared lengths up front without
+// defeating the larger limit of a route: reading such a body fails at once
+// instead, and controllers.BadRequest answers 413.
+func DefaultBodyLimitMiddleware(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		hfn := func(w http.ResponseWriter, r *http.Request) {
+			body := limitBody(w, r, limit)
+			if body != nil && r.ContentLength > limit {
+				body.tooLarge = &http.MaxBytesError{Limit: limit}
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(hfn)
+	}
+}
+
+// limitBody caps the body of r at limit bytes, replacing an earlier limit,
+// and returns the wrapper or nil when there is no body.
+func limitBody(w http.ResponseWriter, r *http.Request, limit int64) *limitedBody {
+	body := r.Body
+	if lb, ok := body.(*limitedBody); ok {
+		body = lb.original
+	}
+	if body == nil || body == http.NoBody {
+		return nil
+	}
+	lb := &limitedBody{
+		ReadCloser: http.MaxBytesReader(w, body, limit),
+		original:   body,
+	}
+	r.Body = lb
+	return lb
+}
@@ -0,0 +1,233 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-rest-api/internal/domain"
+	"go-rest-api/internal/infra/http/controllers"
+	"go-rest-api/internal/infra/idempotency"
+	"go-rest-api/internal/infra/logger"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	IdempotencyKeyHeader       = "Idempotency-Key"
+	IdempotentReplayedHeader   = "Idempotent-Replayed"
+	maxIdempotencyKeyLength    = 255
+	idempotencyInFlightWait    = 5 * time.Second
+	idempotencyInFlightRecheck = 100 * time.Millisecond
+	// Larger bodies are spooled to a temporary file while hashing them.
+	idempotencyMemoryBody = 64 << 10
+)
+
+// replayedHeaders are the response headers stored and replayed with the
+// body.
+var replayedHeaders = []string{"Content-Type", "Location", "Link"}
+
+var (
+	errInvalidIdempotencyKey = fmt.Errorf("%s must be 1 to %d visible ASCII characters", IdempotencyKeyHeader, maxIdempotencyKeyLength)
+	errIdempotencyKeyReused  = fmt.Errorf("%s was already used for a different request", IdempotencyKeyHeader)
+	errIdempotencyInFlight   = fmt.Errorf("a request with this %s is still in progress", IdempotencyKeyHeader)
+)
+
+// IdempotencyMiddleware makes mutating requests of authenticated users
+// safe to retry. The first request sent with an Idempotency-Key header runs
+// and its response is stored for ttl; retries with the same key and body
+// get that response back instead of running again. Reusing a key for a
+// different request answers 409, as does a retry arriving while the first
+// request is still running for longer than a few seconds. Failed (5xx)
+// requests are not stored, so they can be retried. It must run after
+// AuthMiddleware: keys are scoped per user and ignored without one.
+func IdempotencyMiddleware(store idempotency.Store, ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		hfn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			key := r.Header.Get(IdempotencyKeyHeader)
+			user, ok := ctx.Value(controllers.UserKey).(domain.User)
+			if key == "" || !ok || !isMutating(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !validIdempotencyKey(key) {
+				controllers.BadRequest(w, errInvalidIdempotencyKey)
+				return
+			}
+
+			body, bodySum, err := spoolBody(r.Body)
+			if err != nil {
+				controllers.BadRequest(w, err)
+				return
+			}
+			defer body.Close()
+			r.Body = body
+
+			scope := fmt.Sprintf("user:%d", user.Id)
+			fingerprint := requestFingerprint(r, bodySum)
+
+			deadline := time.Now().Add(idempotencyInFlightWait)
+			for {
+				record, acquired, err := store.Acquire(ctx, scope, key, fingerprint, time.Now(), ttl)
+				if err != nil {
+					logger.FromContext(ctx).Errorf("idempotency store: %s", err)
+					controllers.InternalServerError(w, errors.New("unable to check the idempotency key"))
+					return
+				}
+				if acquired {
+					break
+				}
+				if record.Fingerprint != fingerprint {
+					controllers.Conflict(w, errIdempotencyKeyReused)
+					return
+				}
+				if record.Completed {
+					replay(w, record.Response)
+					return
+				}
+				if time.Now().After(deadline) {
+					w.Header().Set("Retry-After", "1")
+					controllers.Conflict(w, errIdempotencyInFlight)
+					return
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(idempotencyInFlightRecheck):
+				}
+			}
+
+			// Storing the outcome must not depend on the client staying.
+			storeCtx := context.WithoutCancel(ctx)
+			completed := false
+			defer func() {
+				if !completed {
+					_ = store.Release(storeCtx, scope, key)
+				}
+			}()
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			var buf bytes.Buffer
+			ww.Tee(&buf)
+
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if status >= http.StatusInternalServerError {
+				return
+			}
+
+			response := idempotency.Response{
+				StatusCode: status,
+				Header:     http.Header{},
+				Body:       buf.Bytes(),
+			}
+			for _, name := range replayedHeaders {
+				if values := w.Header().Values(name); len(values) > 0 {
+					response.Header[name] = values
+				}
+			}
+			err = store.Complete(storeCtx, scope, key, response)
+			if err != nil {
+				logger.FromContext(ctx).Errorf("idempotency store: %s", err)
+				return
+			}
+			completed = true
+		}
+		return http.HandlerFunc(hfn)
+	}
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < '!' || key[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// spooledBody replays a request body from a temporary file, which Close
+// removes.
+type spooledBody struct {
+	*os.File
+}
+
+func (b spooledBody) Close() error {
+	b.File.Close()
+	return os.Remove(b.Name())
+}
+
+// spoolBody reads body to hash it, keeping a copy to replay in memory or,
+// past idempotencyMemoryBody bytes, in a temporary file, so that uploads
+// are never held in memory whole.
+func spoolBody(body io.Reader) (io.ReadCloser, []byte, error) {
+	h := sha256.New()
+	var buf bytes.Buffer
+	_, err := io.CopyN(io.MultiWriter(h, &buf), body, idempotencyMemoryBody+1)
+	if errors.Is(err, io.EOF) {
+		return io.NopCloser(&buf), h.Sum(nil), nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.CreateTemp("", "idempotent-body-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	spooled := spooledBody{file}
+	if _, err = file.Write(buf.Bytes()); err == nil {
+		_, err = io.Copy(io.MultiWriter(h, file), body)
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		spooled.Close()
+		return nil, nil, err
+	}
+	return spooled, h.Sum(nil), nil
+}
+
+// requestFingerprint identifies a request by its method, path, query and
+// the SHA-256 of its body.
+func requestFingerprint(r *http.Request, bodySum []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "?" + r.URL.RawQuery + "\n"))
+	h.Write(bodySum)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func replay(w http.ResponseWriter, response idempotency.Response) {
+	for name, values := range response.Header {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(response.StatusCode)
+	_, _ = w.Write(response.Body)
+}
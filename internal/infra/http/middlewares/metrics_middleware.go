@@ -0,0 +1,36 @@
+package middlewares
+
+import (
+	"go-rest-api/internal/infra/metrics"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// MetricsMiddleware counts requests and measures their latency per chi
+// route pattern. Requests that match no route share one label value.
+func MetricsMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		hfn := func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r)
+
+			route := routePattern(r)
+			if route == "" {
+				route = "unmatched"
+			}
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			labels := []string{r.Method, route, strconv.Itoa(status)}
+			metrics.HttpRequests.WithLabelValues(labels...).Inc()
+			metrics.HttpRequestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+		}
+		return http.HandlerFunc(hfn)
+	}
+}
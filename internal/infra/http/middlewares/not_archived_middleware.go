@@ -0,0 +1,23 @@
+package middlewares
+
+import (
+	"go-rest-api/internal/domain"
+	"go-rest-api/internal/infra/http/controllers"
+	"net/http"
+)
+
+// NotArchivedMiddleware refuses changes to the archived project in the
+// path with 409 Conflict.
+func NotArchivedMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		hfn := func(w http.ResponseWriter, r *http.Request) {
+			project := controllers.GetPathValueFromCtx[domain.Project](r.Context())
+			if project.IsArchived() {
+				controllers.Conflict(w, domain.ErrProjectArchived)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(hfn)
+	}
+}
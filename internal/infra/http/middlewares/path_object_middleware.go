@@ -1,6 +1,7 @@
 package middlewares
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -13,7 +14,7 @@ import (
 )
 
 type FindableT[T any] interface {
-	FindById(uint64) (T, error)
+	FindById(context.Context, uint64) (T, error)
 }
 
 func PathObjectMiddleware[domainType any](service FindableT[domainType]) func(http.Handler) http.Handler {
@@ -26,7 +27,7 @@ func PathObjectMiddleware[domainType any](service FindableT[domainType]) func(ht
 				controllers.BadRequest(w, err)
 				return
 			}
-			object, err := service.FindById(objectId)
+			object, err := service.FindById(r.Context(), objectId)
 			if err != nil {
 				if errors.Is(err, sql.ErrNoRows) {
 					err = fmt.Errorf("record not found")
@@ -0,0 +1,69 @@
+package middlewares
+
+import (
+	"errors"
+	"fmt"
+	"go-rest-api/internal/domain"
+	"go-rest-api/internal/infra/http/controllers"
+	"go-rest-api/internal/infra/logger"
+	"go-rest-api/internal/infra/metrics"
+	"go-rest-api/internal/infra/ratelimit"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var errRateLimited = errors.New("too many requests, retry later")
+
+// RateLimitMiddleware limits the requests of each client to the routes of
+// group. Clients are told apart by user id once AuthMiddleware has run and
+// by remote IP otherwise, which TrustedProxyMiddleware resolves behind load
+// balancers. Every response carries RateLimit-* headers;
+// rejected ones answer 429 with Retry-After. The request is let through if
+// the store fails, so a store outage does not take the API down.
+func RateLimitMiddleware(store ratelimit.Store, group string, limit ratelimit.Limit) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		hfn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			key := group + ":" + rateLimitClient(r)
+
+			result, err := store.Take(ctx, key, limit, time.Now())
+			if err != nil {
+				logger.FromContext(ctx).Errorf("rate limit store: %s", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", ceilSeconds(result.Reset))
+
+			if !result.Allowed {
+				metrics.RateLimitedRequests.WithLabelValues(group).Inc()
+				w.Header().Set("Retry-After", ceilSeconds(result.RetryAfter))
+				controllers.TooManyRequests(w, errRateLimited)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(hfn)
+	}
+}
+
+func rateLimitClient(r *http.Request) string {
+	if user, ok := r.Context().Value(controllers.UserKey).(domain.User); ok {
+		return fmt.Sprintf("user:%d", user.Id)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,36 @@
+package middlewares
+
+import (
+	"errors"
+	"go-rest-api/internal/infra/http/controllers"
+	"go-rest-api/internal/infra/logger"
+	"net/http"
+	"runtime/debug"
+)
+
+// RecoverMiddleware turns a panic in a handler into a JSON 500 response and
+// logs it with the stack trace.
+func RecoverMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		hfn := func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				logger.FromContext(r.Context()).Errorw("panic while handling request",
+					"panic", rec,
+					"stack", string(debug.Stack()),
+				)
+				controllers.InternalServerError(w, errors.New("internal server error"))
+			}()
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(hfn)
+	}
+}
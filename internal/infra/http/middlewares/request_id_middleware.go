@@ -0,0 +1,41 @@
+package middlewares
+
+import (
+	"context"
+	"go-rest-api/internal/infra/http/controllers"
+	"go-rest-api/internal/infra/logger"
+	"net/http"
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+const RequestIdHeader = "X-Request-ID"
+
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestIdMiddleware keeps the X-Request-ID sent by the client or a proxy,
+// or generates one, echoes it in the response and attaches a logger carrying
+// it to the request context.
+func RequestIdMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		hfn := func(w http.ResponseWriter, r *http.Request) {
+			requestId := r.Header.Get(RequestIdHeader)
+			if !requestIdPattern.MatchString(requestId) {
+				requestId = uuid.NewString()
+			}
+			w.Header().Set(RequestIdHeader, requestId)
+
+			ctx := context.WithValue(r.Context(), controllers.RequestIdKey, requestId)
+			ctx = logger.WithContext(ctx, logger.FromContext(ctx).With("request_id", requestId))
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(hfn)
+	}
+}
+
+func RequestIdFromContext(ctx context.Context) string {
+	requestId, _ := ctx.Value(controllers.RequestIdKey).(string)
+	return requestId
+}
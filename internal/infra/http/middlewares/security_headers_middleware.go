@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SecurityHeadersMiddleware sets headers that keep browsers from sniffing
+// content types or framing responses. HSTS is only sent over HTTPS, either
+// terminated here or at a trusted proxy that sets X-Forwarded-Proto, so it
+// has to run after TrustedProxyMiddleware.
+func SecurityHeadersMiddleware(hstsMaxAge time.Duration) func(http.Handler) http.Handler {
+	hsts := fmt.Sprintf("max-age=%d; includeSubDomains", int64(hstsMaxAge.Seconds()))
+	return func(next http.Handler) http.Handler {
+		hfn := func(w http.ResponseWriter, r *http.Request) {
+			header := w.Header()
+			header.Set("X-Content-Type-Options", "nosniff")
+			header.Set("X-Frame-Options", "DENY")
+			header.Set("Referrer-Policy", "no-referrer")
+			if hstsMaxAge > 0 && (r.TLS != nil || forwardedProto(r) == "https") {
+				header.Set("Strict-Transport-Security", hsts)
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(hfn)
+	}
+}
@@ -0,0 +1,70 @@
+package middlewares
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// forwardedProtoKey holds the X-Forwarded-Proto of a request relayed by a
+// trusted proxy.
+type forwardedProtoKey struct{}
+
+// TrustedProxyMiddleware replaces the remote address of requests relayed
+// by one of proxies with the client address they forwarded. X-Forwarded-For
+// is read from the right, skipping the hops added by trusted proxies, so a
+// client cannot pick its address by sending the header itself. Requests
+// from other addresses keep theirs, and their X-Forwarded-Proto is ignored
+// by forwardedProto.
+func TrustedProxyMiddleware(proxies []*net.IPNet) func(http.Handler) http.Handler {
+	trusted := func(ip net.IP) bool {
+		for _, proxy := range proxies {
+			if proxy.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		hfn := func(w http.ResponseWriter, r *http.Request) {
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !trusted(ip) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+			for i := len(hops) - 1; i >= 0; i-- {
+				hop := net.ParseIP(strings.TrimSpace(hops[i]))
+				if hop == nil {
+					break
+				}
+				ip = hop
+				if !trusted(hop) {
+					break
+				}
+			}
+			r.RemoteAddr = ip.String()
+			// The nearest proxy sets or appends the last value.
+			protos := strings.Split(strings.Join(r.Header.Values("X-Forwarded-Proto"), ","), ",")
+			if proto := strings.ToLower(strings.TrimSpace(protos[len(protos)-1])); proto != "" {
+				r = r.WithContext(context.WithValue(r.Context(), forwardedProtoKey{}, proto))
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(hfn)
+	}
+}
+
+// forwardedProto returns the protocol a trusted proxy received the request
+// with, or "" when it did not come through one.
+func forwardedProto(r *http.Request) string {
+	proto, _ := r.Context().Value(forwardedProtoKey{}).(string)
+	return proto
+}
@@ -0,0 +1,138 @@
+package http
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"go-rest-api/internal/infra/http/controllers"
+	"go-rest-api/internal/infra/http/openapi"
+	"go-rest-api/internal/infra/http/requests"
+	"go-rest-api/internal/infra/http/resources"
+	"net/http"
+	"regexp"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// docsPage renders the OpenAPI document with its own inline script and
+// style, so that it works offline and loads nothing from third parties.
+//
+//go:embed docs.html
+var docsPage []byte
+
+// docsPolicy only lets the page run its own inline script and style, by
+// hash, and fetch from the API.
+var docsPolicy = "default-src 'none'; script-src " + inlineHash(docsPage, "script") +
+	"; style-src " + inlineHash(docsPage, "style") + "; connect-src 'self'; base-uri 'none'; form-action 'none'"
+
+// inlineHash returns the CSP hash source of the first inline tag element of
+// page.
+func inlineHash(page []byte, tag string) string {
+	match := regexp.MustCompile(`(?s)<` + tag + `>(.*?)</` + tag + `>`).FindSubmatch(page)
+	if match == nil {
+		return "'none'"
+	}
+	sum := sha256.Sum256(match[1])
+	return "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+}
+
+var apiInfo = openapi.Info{Title: "Tasks API", Version: "1.0.0"}
+
+// apiDocs describes every route registered in CreateRouter. TestApiDocs
+// fails when a route is added without an entry here.
+var apiDocs = []openapi.Route{
+	{Method: "GET", Path: "/api/ping", Summary: "Check that the server answers", Tag: "system", Empty: true},
+	{Method: "GET", Path: "/api/openapi.json", Summary: "OpenAPI document of this API", Tag: "system", Response: map[string]any{}},
+	{Method: "GET", Path: "/api/docs", Summary: "Interactive API documentation", Tag: "system", ContentType: "text/html"},
+	{Method: "GET", Path: "/healthz", Summary: "Liveness probe", Tag: "system", Response: resources.LivenessDto{}},
+	{Method: "GET", Path: "/readyz", Summary: "Readiness probe with dependency checks, 503 when not ready", Tag: "system",
+		Response: resources.ReadinessDto{}},
+	{Method: "GET", Path: "/files/avatars/{userId}/{name}", Summary: "Avatar image stored by the local or S3 backend", Tag: "user",
+		ContentType: "application/octet-stream"},
+	{Method: "GET", Path: "/files/signed/attachments/{projectId}/{name}",
+		Summary: "Attachment by a signed URL of the API; 403 when invalid, expired or quarantined, 409 while pending, 404 once deleted",
+		Tag:     "attachment", ContentType: "application/octet-stream"},
+
+	{Method: "POST", Path: "/api/v1/auth/register", Summary: "Register a new user", Tag: "auth",
+		Request: requests.RegisterRequest{}, Response: resources.SessionDto{}},
+	{Method: "POST", Path: "/api/v1/auth/login", Summary: "Log in with email and password", Tag: "auth",
+		Request: requests.LoginRequest{}, Response: resources.SessionDto{}},
+	{Method: "DELETE", Path: "/api/v1/auth/logout", Summary: "End the current session", Tag: "auth", Auth: true, Empty: true},
+
+	{Method: "GET", Path: "/api/v1/user/me", Summary: "Current user", Tag: "user", Auth: true,
+		Response: resources.UserDto{}},
+	{Method: "PUT", Path: "/api/v1/user/me/update/avatar",
+		Summary: "Replace the avatar of the current user with a PNG, JPEG, GIF or WebP image; 413 when too large, 415 for other types",
+		Tag:     "user", Auth: true,
+		Request: requests.UploadAvatarRequest{}, RequestType: "multipart/form-data", Response: resources.UserDto{}},
+	{Method: "DELETE", Path: "/api/v1/user/me/avatar", Summary: "Remove the avatar of the current user, who gets the generated one again",
+		Tag: "user", Auth: true, Response: resources.UserDto{}},
+	{Method: "GET", Path: "/api/v1/users/{userId}/avatar.png",
+		Summary: "Generated avatar of a user, shown until they upload one; supports If-None-Match", Tag: "user",
+		Query: requests.DefaultAvatarRequest{}, ContentType: "image/png"},
+	{Method: "GET", Path: "/api/v1/user/me/projects", Summary: "Projects of the current user; archived ones only with archived=include or only", Tag: "project", Auth: true,
+		Query: requests.ListProjectsRequest{}, Response: resources.ProjectsDto{}},
+	{Method: "GET", Path: "/api/v1/user/me/usage",
+		Summary: "Attachment storage used by the current user and their projects; limit is null when unlimited", Tag: "user", Auth: true,
+		Response: resources.StorageUsageDto{}},
+
+	{Method: "GET", Path: "/api/v1/project/{projectId}", Summary: "Find a project", Tag: "project", Auth: true,
+		Response: resources.ProjectDto{}},
+	{Method: "POST", Path: "/api/v1/project", Summary: "Create a project", Tag: "project", Auth: true,
+		Request: requests.CreateProjectRequest{}, Response: resources.ProjectDto{}},
+	{Method: "PUT", Path: "/api/v1/project/{projectId}", Summary: "Update title and description of a project; 409 when archived", Tag: "project", Auth: true,
+		Request: requests.CreateProjectRequest{}, Response: resources.ProjectDto{}},
+	{Method: "DELETE", Path: "/api/v1/project/{projectId}", Summary: "Delete a project; 409 when archived", Tag: "project", Auth: true, Empty: true},
+	{Method: "POST", Path: "/api/v1/project/{projectId}/archive",
+		Summary: "Archive a project, hiding it from listings and refusing changes until it is unarchived", Tag: "project", Auth: true,
+		Response: resources.ProjectDto{}},
+	{Method: "POST", Path: "/api/v1/project/{projectId}/unarchive", Summary: "Restore an archived project", Tag: "project", Auth: true,
+		Response: resources.ProjectDto{}},
+	{Method: "GET", Path: "/api/v1/project/{projectId}/attachments", Summary: "Attachments of a project, oldest first",
+		Tag: "attachment", Auth: true, Response: resources.AttachmentsDto{}},
+	{Method: "POST", Path: "/api/v1/project/{projectId}/attachments",
+		Summary: "Attach a file to a project and scan it for malware; 413 when too large, 507 when over the storage quota, 400 when empty, 409 when archived",
+		Tag:     "attachment", Auth: true,
+		Request: requests.UploadAttachmentRequest{}, RequestType: "multipart/form-data", Response: resources.AttachmentDto{}},
+	{Method: "GET", Path: "/api/v1/project/{projectId}/attachments/{attachmentId}",
+		Summary: "Download an attachment; supports If-None-Match, 409 until scanned, 403 when quarantined", Tag: "attachment", Auth: true,
+		ContentType: "application/octet-stream"},
+	{Method: "GET", Path: "/api/v1/project/{projectId}/attachments/{attachmentId}/signed-url",
+		Summary: "Download URL of an attachment that needs no token until it expires or the attachment is deleted; 409 until scanned, 403 when quarantined",
+		Tag:     "attachment", Auth: true, Response: resources.SignedUrlDto{}},
+	{Method: "DELETE", Path: "/api/v1/project/{projectId}/attachments/{attachmentId}", Summary: "Delete an attachment; 409 when archived",
+		Tag: "attachment", Auth: true, Empty: true},
+
+	{Method: "GET", Path: "/api/v1/search", Summary: "Search everything the current user can access, archived projects only with archived=include or only; snippets are escaped HTML with matches in <mark>", Tag: "search", Auth: true,
+		Query: requests.SearchRequest{}, Response: resources.SearchResultsDto{}},
+}
+
+// openapiHandler builds the document from the finished router on the first
+// request, so it always reflects what is actually registered.
+func openapiHandler(router chi.Routes) http.HandlerFunc {
+	var (
+		once     sync.Once
+		document openapi.Document
+		err      error
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			document, err = openapi.Build(apiInfo, router, apiDocs)
+		})
+		if err != nil {
+			controllers.InternalServerError(w, err)
+			return
+		}
+		controllers.Success(w, document)
+	}
+}
+
+func docsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Security-Policy", docsPolicy)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(docsPage)
+	}
+}
@@ -0,0 +1,203 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var pathParamPattern = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// Route documents one operation registered on the router. Query, Request
+// and Response hold zero values of the types the handler binds and
+// returns. Routes answering without a body set Empty instead of Response.
+type Route struct {
+	Method      string
+	Path        string
+	Summary     string
+	Tag         string
+	Auth        bool
+	Query       any
+	Request     any
+	RequestType string
+	Response    any
+	Empty       bool
+	Status      int
+	ContentType string
+}
+
+// ErrorResponse is the body every error helper in controllers writes.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// Build creates the document for the documented routes that exist on the
+// router. Check reports the routes that do not line up.
+func Build(info Info, routes chi.Routes, docs []Route) (Document, error) {
+	registered, err := registeredRoutes(routes)
+	if err != nil {
+		return Document{}, err
+	}
+
+	registry := newSchemaRegistry()
+	errorSchema := registry.schemaFor(reflect.TypeOf(ErrorResponse{}))
+
+	document := Document{
+		OpenAPI: "3.1.0",
+		Info:    info,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+
+	for _, route := range docs {
+		key := routeKey(route.Method, route.Path)
+		if !registered[key] {
+			continue
+		}
+		item, ok := document.Paths[route.Path]
+		if !ok {
+			item = PathItem{}
+			document.Paths[route.Path] = item
+		}
+		item[strings.ToLower(route.Method)] = operation(registry, route, errorSchema)
+	}
+
+	document.Components.Schemas = registry.schemas
+	return document, nil
+}
+
+// Check returns one message per registered route without documentation and
+// per documented route that is not registered.
+func Check(routes chi.Routes, docs []Route) ([]string, error) {
+	registered, err := registeredRoutes(routes)
+	if err != nil {
+		return nil, err
+	}
+
+	documented := map[string]bool{}
+	var problems []string
+	for _, route := range docs {
+		key := routeKey(route.Method, route.Path)
+		documented[key] = true
+		if !registered[key] {
+			problems = append(problems, "documented route is not registered: "+key)
+		}
+		if route.Response == nil && route.ContentType == "" && !route.Empty {
+			problems = append(problems, "documented route has no response schema: "+key)
+		}
+	}
+	for key := range registered {
+		if !documented[key] {
+			problems = append(problems, "registered route is not documented: "+key)
+		}
+	}
+	sort.Strings(problems)
+	return problems, nil
+}
+
+func registeredRoutes(routes chi.Routes) (map[string]bool, error) {
+	registered := map[string]bool{}
+	err := chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		path := normalizePath(route)
+		// Catch-all handlers only exist to answer unknown paths.
+		if strings.HasSuffix(path, "/*") {
+			return nil
+		}
+		registered[routeKey(method, path)] = true
+		return nil
+	})
+	return registered, err
+}
+
+// normalizePath drops the "/*" chi adds for sub-routers mounted with
+// Route("/", ...) and the trailing slash of index routes.
+func normalizePath(route string) string {
+	path := strings.ReplaceAll(route, "/*/", "/")
+	if len(path) > 1 {
+		path = strings.TrimSuffix(path, "/")
+	}
+	return path
+}
+
+func routeKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+func operation(registry *schemaRegistry, route Route, errorSchema *Schema) *Operation {
+	op := &Operation{
+		Summary:     route.Summary,
+		OperationId: operationId(route.Method, route.Path),
+		Responses: map[string]Response{
+			"default": {
+				Description: "Error",
+				Content:     map[string]MediaType{"application/json": {Schema: errorSchema}},
+			},
+		},
+	}
+	if route.Tag != "" {
+		op.Tags = []string{route.Tag}
+	}
+	if route.Auth {
+		op.Security = []map[string][]string{{"bearerAuth": {}}}
+	}
+
+	for _, match := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+		schema := &Schema{Type: "string"}
+		if strings.HasSuffix(match[1], "Id") {
+			schema = &Schema{Type: "integer", Format: "int64", Minimum: float(1)}
+		}
+		op.Parameters = append(op.Parameters, Parameter{Name: match[1], In: "path", Required: true, Schema: schema})
+	}
+	if route.Query != nil {
+		op.Parameters = append(op.Parameters, registry.parameters(reflect.TypeOf(route.Query))...)
+	}
+
+	if route.Request != nil {
+		requestType := route.RequestType
+		if requestType == "" {
+			requestType = "application/json"
+		}
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{requestType: {Schema: registry.schemaFor(reflect.TypeOf(route.Request))}},
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	response := Response{Description: http.StatusText(status)}
+	if route.Response != nil || route.ContentType != "" {
+		contentType := route.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		schema := &Schema{Type: "string"}
+		if route.Response != nil {
+			schema = registry.schemaFor(reflect.TypeOf(route.Response))
+		}
+		response.Content = map[string]MediaType{contentType: {Schema: schema}}
+	}
+	op.Responses[strconv.Itoa(status)] = response
+
+	return op
+}
+
+func operationId(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '{' || r == '}' || r == '.' || r == '-' }) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
@@ -0,0 +1,193 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	uuidType = reflect.TypeOf(uuid.UUID{})
+)
+
+// schemaRegistry turns Go types into schemas. Named structs are stored once
+// in components and referenced everywhere else.
+type schemaRegistry struct {
+	schemas map[string]*Schema
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{schemas: map[string]*Schema{}}
+}
+
+func (sr *schemaRegistry) schemaFor(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(sr.schemaFor(t.Elem()))
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32", Minimum: float(0)}
+	case reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64", Minimum: float(0)}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: sr.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: sr.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return sr.structSchema(t)
+		}
+		if _, ok := sr.schemas[t.Name()]; !ok {
+			// Reserve the name first so recursive types terminate.
+			sr.schemas[t.Name()] = &Schema{}
+			*sr.schemas[t.Name()] = *sr.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + t.Name()}
+	default:
+		return &Schema{}
+	}
+}
+
+func (sr *schemaRegistry) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		name, ok := jsonName(field)
+		if !ok {
+			continue
+		}
+		fieldSchema := sr.schemaFor(field.Type)
+		if isRequired(field) {
+			schema.Required = append(schema.Required, name)
+		}
+		applyValidation(fieldSchema, field.Tag.Get("validate"))
+		// A format tag overrides the derived one, e.g. binary for files.
+		if format := field.Tag.Get("format"); format != "" {
+			fieldSchema.Format = format
+		}
+		schema.Properties[name] = fieldSchema
+	}
+	return schema
+}
+
+// parameters describes the fields of a query request struct, which are
+// bound through their `schema` tags.
+func (sr *schemaRegistry) parameters(t reflect.Type) []Parameter {
+	var params []Parameter
+	for _, field := range reflect.VisibleFields(t) {
+		name := strings.Split(field.Tag.Get("schema"), ",")[0]
+		if !field.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		schema := sr.schemaFor(fieldType)
+		applyValidation(schema, field.Tag.Get("validate"))
+		params = append(params, Parameter{
+			Name:     name,
+			In:       "query",
+			Required: isRequired(field),
+			Schema:   schema,
+		})
+	}
+	return params
+}
+
+func jsonName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	name := strings.Split(tag, ",")[0]
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
+
+func isRequired(field reflect.StructField) bool {
+	for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+		if rule == "required" {
+			return true
+		}
+	}
+	return false
+}
+
+// applyValidation mirrors the validator rules that matter to API clients.
+func applyValidation(schema *Schema, tag string) {
+	if tag == "" || schema.Ref != "" {
+		return
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "oneof":
+			for _, option := range strings.Fields(value) {
+				schema.Enum = append(schema.Enum, option)
+			}
+		case "min", "max":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			if schema.Type == "string" {
+				length := int(n)
+				if key == "min" {
+					schema.MinLength = &length
+				} else {
+					schema.MaxLength = &length
+				}
+				continue
+			}
+			if key == "min" {
+				schema.Minimum = &n
+			} else {
+				schema.Maximum = &n
+			}
+		}
+	}
+}
+
+func nullable(schema *Schema) *Schema {
+	if schema.Ref != "" {
+		return &Schema{AnyOf: []*Schema{schema, {Type: "null"}}}
+	}
+	if t, ok := schema.Type.(string); ok {
+		schema.Type = []string{t, "null"}
+	}
+	return schema
+}
+
+func float(n float64) *float64 {
+	return &n
+}
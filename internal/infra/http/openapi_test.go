@@ -0,0 +1,59 @@
+package http
+
+import (
+	"encoding/json"
+	"go-rest-api/config/container"
+	"go-rest-api/internal/infra/http/openapi"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func testRouter() http.Handler {
+	return CreateRouter(container.Container{
+		Middleware: container.Middleware{
+			AuthMw: func(next http.Handler) http.Handler { return next },
+		},
+	})
+}
+
+func TestApiDocs(t *testing.T) {
+	problems, err := openapi.Check(testRouter().(chi.Routes), apiDocs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, problem := range problems {
+		t.Error(problem)
+	}
+}
+
+func TestOpenapiHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	testRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var document openapi.Document
+	if err := json.NewDecoder(rec.Body).Decode(&document); err != nil {
+		t.Fatal(err)
+	}
+	if document.OpenAPI != "3.1.0" {
+		t.Errorf("openapi = %q, want 3.1.0", document.OpenAPI)
+	}
+
+	operations := 0
+	for _, item := range document.Paths {
+		operations += len(item)
+	}
+	if operations != len(apiDocs) {
+		t.Errorf("document has %d operations, want %d", operations, len(apiDocs))
+	}
+	for _, name := range []string{"ProjectsDto", "CreateProjectRequest", "ErrorResponse"} {
+		if _, ok := document.Components.Schemas[name]; !ok {
+			t.Errorf("schema %s is missing", name)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+package requests
+
+// UploadAttachmentRequest documents the multipart form of an attachment
+// upload, which the controller streams instead of binding.
+type UploadAttachmentRequest struct {
+	File []byte `json:"file" format:"binary" validate:"required"`
+}
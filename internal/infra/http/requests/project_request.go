@@ -1,15 +1,64 @@
 package requests
 
-import "go-rest-api/internal/domain"
+import (
+	"errors"
+	"go-rest-api/internal/domain"
+	"time"
+)
 
 type CreateProjectRequest struct {
 	Title       string `json:"title" validate:"required"`
 	Description string `json:"description"`
 }
 
+type ListProjectsRequest struct {
+	Cursor      string     `schema:"cursor"`
+	Limit       int32      `schema:"limit" validate:"omitempty,min=1,max=100"`
+	Sort        string     `schema:"sort" validate:"omitempty,oneof=id -id title -title created_at -created_at"`
+	Title       string     `schema:"title"`
+	CreatedFrom *time.Time `schema:"created_from"`
+	CreatedTo   *time.Time `schema:"created_to"`
+	WithTotal   bool       `schema:"with_total"`
+	Archived    string     `schema:"archived" validate:"omitempty,oneof=exclude include only"`
+}
+
 func (r CreateProjectRequest) ToDomainModel() (interface{}, error) {
 	return domain.Project{
 		Title:       r.Title,
 		Description: r.Description,
 	}, nil
 }
+
+func (r ListProjectsRequest) ToDomainModel() (interface{}, error) {
+	query := domain.ProjectQuery{
+		Limit:         r.Limit,
+		Sort:          domain.ParseSort(domain.DefaultProjectsSort),
+		TitleContains: r.Title,
+		CreatedFrom:   r.CreatedFrom,
+		CreatedTo:     r.CreatedTo,
+		WithTotal:     r.WithTotal,
+		Archived:      domain.ArchivedFilter(r.Archived),
+	}
+	if query.Limit == 0 {
+		query.Limit = domain.DefaultProjectsLimit
+	}
+	if query.Archived == "" {
+		query.Archived = domain.ArchivedExclude
+	}
+	if r.Sort != "" {
+		query.Sort = domain.ParseSort(r.Sort)
+	}
+
+	if r.Cursor != "" {
+		cursor, err := domain.DecodeCursor(r.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Sort != query.Sort.String() {
+			return nil, errors.New("cursor does not match sort")
+		}
+		query.Cursor = &cursor
+	}
+
+	return query, nil
+}
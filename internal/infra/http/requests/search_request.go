@@ -0,0 +1,23 @@
+package requests
+
+import "go-rest-api/internal/domain"
+
+type SearchRequest struct {
+	Query    string `schema:"q" validate:"required,max=200"`
+	Limit    int32  `schema:"limit" validate:"omitempty,min=1,max=50"`
+	Offset   int32  `schema:"offset" validate:"omitempty,min=0"`
+	Archived string `schema:"archived" validate:"omitempty,oneof=exclude include only"`
+}
+
+func (r SearchRequest) ToDomainModel() (interface{}, error) {
+	query := domain.SearchQuery{
+		Text:     r.Query,
+		Limit:    r.Limit,
+		Offset:   r.Offset,
+		Archived: domain.ArchivedFilter(r.Archived),
+	}
+	if query.Archived == "" {
+		query.Archived = domain.ArchivedExclude
+	}
+	return query, nil
+}
@@ -13,10 +13,24 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+// UploadAvatarRequest documents the multipart form of an avatar upload,
+// which the controller streams instead of binding.
+type UploadAvatarRequest struct {
+	Avatar []byte `json:"avatar" format:"binary" validate:"required"`
+}
+
+// UpdateAvatarRequest is the older JSON form of an avatar upload, with the
+// image base64 encoded.
 type UpdateAvatarRequest struct {
 	AvatarBase64String string `json:"avatar" validate:"required"`
 }
 
+// DefaultAvatarRequest picks the size of a generated avatar, 256 pixels
+// when it is not given.
+type DefaultAvatarRequest struct {
+	Size int `schema:"size" validate:"omitempty,oneof=32 64 256"`
+}
+
 func (r RegisterRequest) ToDomainModel() (interface{}, error) {
 	return domain.User{
 		Name:     r.Name,
@@ -37,3 +51,10 @@ func (r UpdateAvatarRequest) ToDomainModel() (interface{}, error) {
 		Avatar: &r.AvatarBase64String,
 	}, nil
 }
+
+func (r DefaultAvatarRequest) ToDomainModel() (interface{}, error) {
+	if r.Size == 0 {
+		return 256, nil
+	}
+	return r.Size, nil
+}
@@ -4,32 +4,63 @@ import (
 	"encoding/json"
 	"go-rest-api/internal/infra/logger"
 	"net/http"
+	"reflect"
+	"time"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/schema"
 )
 
 var v = validator.New()
 
+var queryDecoder = newQueryDecoder()
+
 type requestType interface {
 	ToDomainModel() (interface{}, error)
 }
 
 func Bind[reqType requestType, domain interface{}](r *http.Request, req reqType, targetType domain) (domain, error) {
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(r.Context()).Error(err)
+		return targetType, err
+	}
+
+	return validateAndConvert(r, req, targetType)
+}
+
+func BindQuery[reqType requestType, domain interface{}](r *http.Request, req reqType, targetType domain) (domain, error) {
+	if err := queryDecoder.Decode(&req, r.URL.Query()); err != nil {
+		logger.FromContext(r.Context()).Error(err)
 		return targetType, err
 	}
 
+	return validateAndConvert(r, req, targetType)
+}
+
+func validateAndConvert[reqType requestType, domain interface{}](r *http.Request, req reqType, targetType domain) (domain, error) {
 	if err := v.Struct(req); err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(r.Context()).Error(err)
 		return targetType, err
 	}
 
 	d, err := req.ToDomainModel()
 	if err != nil {
-		logger.Logger.Error(err)
+		logger.FromContext(r.Context()).Error(err)
 		return targetType, err
 	}
 
 	return d.(domain), nil
 }
+
+func newQueryDecoder() *schema.Decoder {
+	decoder := schema.NewDecoder()
+	decoder.IgnoreUnknownKeys(true)
+	decoder.RegisterConverter(time.Time{}, func(s string) reflect.Value {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return reflect.Value{}
+		}
+		return reflect.ValueOf(t)
+	})
+	return decoder
+}
@@ -0,0 +1,53 @@
+package resources
+
+import (
+	"fmt"
+	"go-rest-api/internal/domain"
+	"time"
+)
+
+type AttachmentDto struct {
+	Id          uint64    `json:"id"`
+	ProjectId   uint64    `json:"project_id"`
+	UploaderId  uint64    `json:"uploader_id"`
+	Filename    string    `json:"filename"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	Checksum    string    `json:"sha256"`
+	ScanStatus  string    `json:"scan_status"`
+	Url         string    `json:"url"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// SignedUrlDto is a download URL that needs no token until it expires.
+type SignedUrlDto struct {
+	Url       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type AttachmentsDto struct {
+	Attachments []AttachmentDto `json:"attachments"`
+}
+
+func (d AttachmentDto) DomainToDto(attachment domain.Attachment) AttachmentDto {
+	return AttachmentDto{
+		Id:          attachment.Id,
+		ProjectId:   attachment.ProjectId,
+		UploaderId:  attachment.UploaderId,
+		Filename:    attachment.Filename,
+		Size:        attachment.Size,
+		ContentType: attachment.ContentType,
+		Checksum:    attachment.Checksum,
+		ScanStatus:  string(attachment.ScanStatus),
+		Url:         fmt.Sprintf("/api/v1/project/%d/attachments/%d", attachment.ProjectId, attachment.Id),
+		CreatedAt:   attachment.CreatedAt,
+	}
+}
+
+func (d AttachmentsDto) DomainToDto(attachments []domain.Attachment) AttachmentsDto {
+	result := make([]AttachmentDto, len(attachments))
+	for i := range attachments {
+		result[i] = AttachmentDto{}.DomainToDto(attachments[i])
+	}
+	return AttachmentsDto{Attachments: result}
+}
@@ -0,0 +1,33 @@
+package resources
+
+import "go-rest-api/internal/infra/health"
+
+type LivenessDto struct {
+	Status string `json:"status"`
+}
+
+type CheckDto struct {
+	Status     string  `json:"status"`
+	DurationMs float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+type ReadinessDto struct {
+	Status string              `json:"status"`
+	Checks map[string]CheckDto `json:"checks"`
+}
+
+func (d ReadinessDto) DomainToDto(report health.Report) ReadinessDto {
+	checks := make(map[string]CheckDto, len(report.Checks))
+	for name, result := range report.Checks {
+		checks[name] = CheckDto{
+			Status:     result.Status,
+			DurationMs: float64(result.Duration.Microseconds()) / 1000,
+			Error:      result.Error,
+		}
+	}
+	return ReadinessDto{
+		Status: report.Status,
+		Checks: checks,
+	}
+}
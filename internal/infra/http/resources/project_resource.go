@@ -1,19 +1,23 @@
 package resources
 
-import "go-rest-api/internal/domain"
+import (
+	"go-rest-api/internal/domain"
+	"time"
+)
 
 type ProjectDto struct {
-	Id          uint64 `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	CreatorId   uint64 `json:"creator_id"`
+	Id          uint64     `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	CreatorId   uint64     `json:"creator_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ArchivedAt  *time.Time `json:"archived_at"`
 }
 
 type ProjectsDto struct {
-	Projects    []ProjectDto `json:"projects"`
-	Total       uint64       `json:"total"`
-	CurrentPage int32        `json:"current_page"`
-	LastPage    int32        `json:"last_page"`
+	Projects   []ProjectDto `json:"projects"`
+	NextCursor *string      `json:"next_cursor"`
+	Total      *uint64      `json:"total,omitempty"`
 }
 
 func (d ProjectDto) DomainToDto(project domain.Project) ProjectDto {
@@ -22,6 +26,8 @@ func (d ProjectDto) DomainToDto(project domain.Project) ProjectDto {
 		Title:       project.Title,
 		Description: project.Description,
 		CreatorId:   project.CreatorId,
+		CreatedAt:   project.CreatedAt,
+		ArchivedAt:  project.ArchivedAt,
 	}
 }
 
@@ -32,10 +38,15 @@ func (d ProjectsDto) DomainToDto(projects domain.Projects) ProjectsDto {
 		result[i] = ProjectDto{}.DomainToDto(projects.Projects[i])
 	}
 
+	var nextCursor *string
+	if projects.NextCursor != nil {
+		encoded := projects.NextCursor.Encode()
+		nextCursor = &encoded
+	}
+
 	return ProjectsDto{
-		Projects:    result,
-		Total:       projects.Total,
-		CurrentPage: projects.CurrentPage,
-		LastPage:    projects.LastPage,
+		Projects:   result,
+		NextCursor: nextCursor,
+		Total:      projects.Total,
 	}
 }
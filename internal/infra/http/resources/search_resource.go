@@ -0,0 +1,41 @@
+package resources
+
+import "go-rest-api/internal/domain"
+
+type SearchResultDto struct {
+	Type               string  `json:"type"`
+	Id                 uint64  `json:"id"`
+	Title              string  `json:"title"`
+	TitleSnippet       string  `json:"title_snippet"`
+	DescriptionSnippet string  `json:"description_snippet"`
+	Rank               float32 `json:"rank"`
+}
+
+type SearchResultsDto struct {
+	Results []SearchResultDto `json:"results"`
+	Total   uint64            `json:"total"`
+}
+
+func (d SearchResultDto) DomainToDto(result domain.SearchResult) SearchResultDto {
+	return SearchResultDto{
+		Type:               result.Type,
+		Id:                 result.Id,
+		Title:              result.Title,
+		TitleSnippet:       result.TitleSnippet,
+		DescriptionSnippet: result.DescriptionSnippet,
+		Rank:               result.Rank,
+	}
+}
+
+func (d SearchResultsDto) DomainToDto(results domain.SearchResults) SearchResultsDto {
+	result := make([]SearchResultDto, len(results.Results))
+
+	for i := range results.Results {
+		result[i] = SearchResultDto{}.DomainToDto(results.Results[i])
+	}
+
+	return SearchResultsDto{
+		Results: result,
+		Total:   results.Total,
+	}
+}
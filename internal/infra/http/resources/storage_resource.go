@@ -0,0 +1,42 @@
+package resources
+
+import "go-rest-api/internal/domain"
+
+// StorageUsageDto reports bytes used against a limit, which is null when
+// there is none.
+type StorageUsageDto struct {
+	Used     int64                    `json:"used"`
+	Limit    *int64                   `json:"limit"`
+	Projects []ProjectStorageUsageDto `json:"projects"`
+}
+
+type ProjectStorageUsageDto struct {
+	ProjectId uint64 `json:"project_id"`
+	Title     string `json:"title"`
+	Used      int64  `json:"used"`
+	Limit     *int64 `json:"limit"`
+}
+
+func (d StorageUsageDto) DomainToDto(usage domain.StorageUsage) StorageUsageDto {
+	projects := make([]ProjectStorageUsageDto, len(usage.Projects))
+	for i, project := range usage.Projects {
+		projects[i] = ProjectStorageUsageDto{
+			ProjectId: project.ProjectId,
+			Title:     project.Title,
+			Used:      project.Used,
+			Limit:     storageLimit(project.Limit),
+		}
+	}
+	return StorageUsageDto{
+		Used:     usage.Used,
+		Limit:    storageLimit(usage.Limit),
+		Projects: projects,
+	}
+}
+
+func storageLimit(limit int64) *int64 {
+	if limit == 0 {
+		return nil
+	}
+	return &limit
+}
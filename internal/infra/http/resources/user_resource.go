@@ -1,19 +1,47 @@
 package resources
 
-import "go-rest-api/internal/domain"
+import (
+	"fmt"
+	"go-rest-api/internal/domain"
+	"strconv"
+)
 
+// UserDto is a user as clients see it. Avatars holds the URLs of the square
+// avatar thumbnails keyed by their width in pixels.
 type UserDto struct {
-	Id     uint64  `json:"id"`
-	Name   string  `json:"username"`
-	Email  string  `json:"email"`
-	Avatar *string `json:"avatar"`
+	Id      uint64            `json:"id"`
+	Name    string            `json:"username"`
+	Email   string            `json:"email"`
+	Avatar  string            `json:"avatar"`
+	Avatars map[string]string `json:"avatars"`
 }
 
 func (u UserDto) DomainToDto(user domain.User) UserDto {
+	var avatar string
+	avatars := make(map[string]string, len(domain.AvatarSizes))
+	if user.Avatar != nil {
+		avatar = *user.Avatar
+	} else {
+		// Users without an upload get their generated avatar.
+		avatar = DefaultAvatarUrl(user.Id)
+		for _, size := range domain.AvatarSizes {
+			avatars[strconv.Itoa(size)] = fmt.Sprintf("%s?size=%d", avatar, size)
+		}
+	}
+	for size, url := range user.AvatarSizes {
+		avatars[strconv.Itoa(size)] = url
+	}
+
 	return UserDto{
-		Id:     user.Id,
-		Name:   user.Name,
-		Email:  user.Email,
-		Avatar: user.Avatar,
+		Id:      user.Id,
+		Name:    user.Name,
+		Email:   user.Email,
+		Avatar:  avatar,
+		Avatars: avatars,
 	}
 }
+
+// DefaultAvatarUrl is where the generated avatar of a user is served.
+func DefaultAvatarUrl(userId uint64) string {
+	return fmt.Sprintf("/api/v1/users/%d/avatar.png", userId)
+}
@@ -6,6 +6,8 @@ import (
 	"go-rest-api/internal/domain"
 	"go-rest-api/internal/infra/http/controllers"
 	"go-rest-api/internal/infra/http/middlewares"
+	"go-rest-api/internal/infra/metrics"
+	"go-rest-api/internal/infra/ratelimit"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
@@ -16,33 +18,83 @@ import (
 func CreateRouter(con container.Container) http.Handler {
 	router := chi.NewRouter()
 
-	router.Use(middleware.RedirectSlashes, middleware.Logger, cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"https://*", "http://*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: false,
-		MaxAge:           300,
-	}))
+	router.Use(
+		middlewares.TrustedProxyMiddleware(con.Config.TrustedProxyNets()),
+		middlewares.RequestIdMiddleware(),
+		middlewares.TracingMiddleware(),
+		middlewares.AccessLogMiddleware(),
+		middlewares.MetricsMiddleware(),
+		middlewares.RecoverMiddleware(),
+		middlewares.SecurityHeadersMiddleware(con.Config.HstsMaxAge),
+		middlewares.DefaultBodyLimitMiddleware(con.Config.RequestBodyLimit),
+	)
+	router.Use(middleware.RedirectSlashes)
+	// cors treats an empty origin list as "allow all", so cross-origin
+	// requests stay disabled unless origins are configured.
+	if len(con.Config.CorsAllowedOrigins) > 0 {
+		router.Use(cors.Handler(cors.Options{
+			AllowedOrigins:   con.Config.CorsAllowedOrigins,
+			AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", middlewares.RequestIdHeader, middlewares.IdempotencyKeyHeader, "traceparent", "tracestate"},
+			ExposedHeaders:   []string{"Link", middlewares.RequestIdHeader, "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", middlewares.IdempotentReplayedHeader},
+			AllowCredentials: false,
+			MaxAge:           300,
+		}))
+	}
+
+	router.Get("/healthz", con.HealthController.Live())
+	router.Get("/readyz", con.HealthController.Ready())
+
+	// Files of the local and S3 backends, at the default STORAGE_PUBLIC_URL.
+	router.Get("/files/avatars/{userId}/{name}", con.FileController.ServeAvatar())
+	// Attachments by the signed URLs of the API, below filesystem.SignedPath.
+	router.Get("/files/signed/attachments/{projectId}/{name}", con.FileController.ServeSignedAttachment())
 
 	router.Route("/api", func(apiRouter chi.Router) {
+		apiRouter.Get("/openapi.json", openapiHandler(router))
+		apiRouter.Get("/docs", docsHandler())
 		apiRouter.Route("/ping", func(apiRouter chi.Router) {
 			apiRouter.Get("/", pingHandler())
 			apiRouter.Handle("/*", notFoundJson())
 		})
 		apiRouter.Route("/v1", func(apiRouter chi.Router) {
+			authLimitMw := rateLimit(con, "auth", ratelimit.Limit{
+				Requests: con.Config.RateLimitAuthRequests,
+				Period:   con.Config.RateLimitAuthPeriod,
+			})
+			// Runs after AuthMw, so authenticated clients are limited by user.
+			apiLimitMw := rateLimit(con, "api", ratelimit.Limit{
+				Requests: con.Config.RateLimitApiRequests,
+				Period:   con.Config.RateLimitApiPeriod,
+			})
+			// Runs before AuthMw, so floods of invalid tokens are limited by IP.
+			ipLimitMw := rateLimit(con, "ip", ratelimit.Limit{
+				Requests: con.Config.RateLimitIpRequests,
+				Period:   con.Config.RateLimitIpPeriod,
+			})
+
 			apiRouter.Group(func(apiRouter chi.Router) {
 				apiRouter.Route("/auth", func(apiRouter chi.Router) {
+					apiRouter.Use(authLimitMw)
 					AuthRouter(apiRouter, con.SessionController, con.AuthMw)
 				})
 				apiRouter.Route("/user", func(apiRouter chi.Router) {
-					apiRouter.Use(con.AuthMw)
+					apiRouter.Use(ipLimitMw, con.AuthMw, apiLimitMw)
 					UserRouter(apiRouter, con)
 				})
+				apiRouter.Route("/users", func(apiRouter chi.Router) {
+					apiRouter.Use(apiLimitMw)
+					// Public, so that clients can use the URL in image tags.
+					apiRouter.Get("/{userId}/avatar.png", con.UserController.DefaultAvatar())
+				})
 				apiRouter.Route("/project", func(apiRouter chi.Router) {
-					apiRouter.Use(con.AuthMw)
+					apiRouter.Use(ipLimitMw, con.AuthMw, apiLimitMw)
 					ProjectRouter(apiRouter, con)
 				})
+				apiRouter.Route("/search", func(apiRouter chi.Router) {
+					apiRouter.Use(ipLimitMw, con.AuthMw, apiLimitMw)
+					apiRouter.Get("/", con.SearchController.Search())
+				})
 			})
 		})
 	})
@@ -50,6 +102,14 @@ func CreateRouter(con container.Container) http.Handler {
 	return router
 }
 
+// CreateAdminRouter serves operational endpoints that API clients must not
+// reach, on the separate listener of http.AdminServer.
+func CreateAdminRouter() http.Handler {
+	router := chi.NewRouter()
+	router.Get("/metrics", metrics.Handler().ServeHTTP)
+	return router
+}
+
 func AuthRouter(r chi.Router, sc controllers.SessionController, amw func(http.Handler) http.Handler) {
 	r.Route("/", func(apiRouter chi.Router) {
 		apiRouter.Post(
@@ -73,14 +133,24 @@ func UserRouter(r chi.Router, con container.Container) {
 			"/me",
 			con.UserController.FindMe(),
 		)
-		apiRouter.Put(
+		// The idempotency middleware reads the whole body, so it has to run
+		// after the larger avatar limit replaced the default one.
+		apiRouter.With(middlewares.BodyLimitMiddleware(con.Config.AvatarBodyLimit), idempotent(con)).Put(
 			"/me/update/avatar",
 			con.UserController.UpdateUserAvatar(),
 		)
+		apiRouter.With(idempotent(con)).Delete(
+			"/me/avatar",
+			con.UserController.RemoveAvatar(),
+		)
 		apiRouter.Get(
 			"/me/projects",
 			con.ProjectController.GetMyProjects(),
 		)
+		apiRouter.Get(
+			"/me/usage",
+			con.AttachmentController.StorageUsage(),
+		)
 		// apiRouter.Get(
 		// 	"/email/confirm/{token}",
 		// 	uc.ConfirmUserEmailByEmailConfirmationToken(),
@@ -91,26 +161,85 @@ func UserRouter(r chi.Router, con container.Container) {
 func ProjectRouter(r chi.Router, con container.Container) {
 	pathObjMw := middlewares.PathObjectMiddleware(con.ProjectService)
 	isOwnerMw := middlewares.IsOwnerMiddleware[domain.Project]()
+	notArchivedMw := middlewares.NotArchivedMiddleware()
+	idempotentMw := idempotent(con)
 	r.Route("/", func(apiRouter chi.Router) {
 		apiRouter.Get(
 			"/{projectId}",
 			con.ProjectController.FindProjectById(),
 		)
-		apiRouter.Post(
+		apiRouter.With(idempotentMw).Post(
 			"/",
 			con.ProjectController.CreateProject(),
 		)
-		apiRouter.With(pathObjMw).With(isOwnerMw).Put(
+		apiRouter.With(pathObjMw).With(isOwnerMw, notArchivedMw, idempotentMw).Put(
 			"/{projectId}",
 			con.ProjectController.UpdateProjecTitleAndDescription(),
 		)
-		apiRouter.With(pathObjMw).With(isOwnerMw).Delete(
+		apiRouter.With(pathObjMw).With(isOwnerMw, notArchivedMw, idempotentMw).Delete(
 			"/{projectId}",
 			con.ProjectController.DeleteProjectById(),
 		)
+		apiRouter.With(pathObjMw).With(isOwnerMw, idempotentMw).Post(
+			"/{projectId}/archive",
+			con.ProjectController.ArchiveProject(),
+		)
+		apiRouter.With(pathObjMw).With(isOwnerMw, idempotentMw).Post(
+			"/{projectId}/unarchive",
+			con.ProjectController.UnarchiveProject(),
+		)
+		apiRouter.With(pathObjMw).Route("/{projectId}/attachments", func(apiRouter chi.Router) {
+			AttachmentRouter(apiRouter, con, isOwnerMw, notArchivedMw, idempotentMw)
+		})
 	})
 }
 
+// AttachmentRouter serves the attachments of the project in the path, which
+// anyone signed in may read and only its owner may change while the project
+// is not archived.
+func AttachmentRouter(r chi.Router, con container.Container, isOwnerMw, notArchivedMw, idempotentMw func(http.Handler) http.Handler) {
+	r.Get(
+		"/",
+		con.AttachmentController.ListAttachments(),
+	)
+	// The idempotency middleware reads the whole body, so it has to run
+	// after the larger attachment limit replaced the default one.
+	r.With(isOwnerMw, notArchivedMw, middlewares.BodyLimitMiddleware(con.Config.AttachmentBodyLimit), idempotentMw).Post(
+		"/",
+		con.AttachmentController.UploadAttachment(),
+	)
+	r.Get(
+		"/{attachmentId}",
+		con.AttachmentController.DownloadAttachment(),
+	)
+	r.Get(
+		"/{attachmentId}/signed-url",
+		con.AttachmentController.SignedUrl(),
+	)
+	r.With(isOwnerMw, notArchivedMw, idempotentMw).Delete(
+		"/{attachmentId}",
+		con.AttachmentController.DeleteAttachment(),
+	)
+}
+
+// rateLimit limits a route group, or lets everything through when no rate
+// limit store is configured.
+func rateLimit(con container.Container, group string, limit ratelimit.Limit) func(http.Handler) http.Handler {
+	if con.RateLimitStore == nil {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return middlewares.RateLimitMiddleware(con.RateLimitStore, group, limit)
+}
+
+// idempotent honours Idempotency-Key headers on the routes it wraps, or
+// does nothing when no idempotency store is configured.
+func idempotent(con container.Container) func(http.Handler) http.Handler {
+	if con.IdempotencyStore == nil {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return middlewares.IdempotencyMiddleware(con.IdempotencyStore, con.Config.IdempotencyKeyTtl)
+}
+
 func notFoundJson() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		controllers.NotFound(w, errors.New("resource Not Found"))
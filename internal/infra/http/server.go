@@ -2,27 +2,91 @@ package http
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"go-rest-api/config"
+	"go-rest-api/internal/infra/health"
+	"net"
 	"net/http"
 	"time"
 )
 
-func Server(ctx context.Context, router http.Handler) error {
+// Server serves router until ctx is cancelled, over TLS when a certificate
+// is configured. Before shutting down it marks the instance not ready and
+// waits for the drain delay, so load balancers polling /readyz stop sending
+// new requests first.
+func Server(ctx context.Context, cfg config.Configuration, router http.Handler, readiness *health.Health) error {
 	srv := &http.Server{
-		Handler: router,
-		Addr:    fmt.Sprintf(":%d", 8080),
+		Handler:           router,
+		Addr:              fmt.Sprintf(":%d", cfg.ServerPort),
+		ReadTimeout:       cfg.ServerReadTimeout,
+		ReadHeaderTimeout: cfg.ServerReadHeaderTimeout,
+		WriteTimeout:      cfg.ServerWriteTimeout,
+		IdleTimeout:       cfg.ServerIdleTimeout,
+		MaxHeaderBytes:    cfg.ServerMaxHeaderBytes,
 	}
 
+	if cfg.TlsCertFile != "" {
+		reloader, err := newCertReloader(cfg.TlsCertFile, cfg.TlsKeyFile)
+		if err != nil {
+			return fmt.Errorf("error loading TLS certificate: %w", err)
+		}
+		go reloader.watch(ctx)
+		srv.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: reloader.GetCertificate,
+		}
+	}
+
+	listenAndServe := srv.ListenAndServe
+	if srv.TLSConfig != nil {
+		listenAndServe = func() error { return srv.ListenAndServeTLS("", "") }
+	}
+	return serve(ctx, srv, listenAndServe, cfg.ServerShutdownTimeout, func() {
+		readiness.SetShuttingDown()
+		time.Sleep(cfg.ShutdownDrainDelay)
+	})
+}
+
+// ListenAdmin binds the admin address, so that a port in use fails the
+// start of the server instead of leaving it running without metrics.
+func ListenAdmin(cfg config.Configuration) (net.Listener, error) {
+	listener, err := net.Listen("tcp", cfg.MetricsAddress)
+	if err != nil {
+		return nil, fmt.Errorf("error listening on the admin address: %w", err)
+	}
+	return listener, nil
+}
+
+// AdminServer serves operational endpoints such as /metrics on a listener
+// of ListenAdmin, which is not exposed to API clients.
+func AdminServer(ctx context.Context, cfg config.Configuration, listener net.Listener, handler http.Handler) error {
+	srv := &http.Server{
+		Handler:           handler,
+		ReadTimeout:       cfg.ServerReadTimeout,
+		ReadHeaderTimeout: cfg.ServerReadHeaderTimeout,
+		WriteTimeout:      cfg.ServerWriteTimeout,
+		IdleTimeout:       cfg.ServerIdleTimeout,
+		MaxHeaderBytes:    cfg.ServerMaxHeaderBytes,
+	}
+	return serve(ctx, srv, func() error { return srv.Serve(listener) }, cfg.ServerShutdownTimeout, nil)
+}
+
+func serve(ctx context.Context, srv *http.Server, run func() error, shutdownTimeout time.Duration, beforeShutdown func()) error {
 	errServeCh := make(chan error)
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		err := run()
+		if err != nil && err != http.ErrServerClosed {
 			errServeCh <- err
 		}
 	}()
 
 	select {
 	case <-ctx.Done():
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+		if beforeShutdown != nil {
+			beforeShutdown()
+		}
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 		defer cancel()
 		if err := srv.Shutdown(shutdownCtx); err != nil {
 			return fmt.Errorf("error during server shutdown: %w", err)
@@ -0,0 +1,64 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"go-rest-api/internal/infra/logger"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+)
+
+// certReloader serves the certificate loaded from disk and loads it again
+// on SIGHUP, so renewed certificates are picked up without a restart.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	reloader := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+func (cr *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return err
+	}
+	cr.cert.Store(&cert)
+	return nil
+}
+
+func (cr *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return cr.cert.Load(), nil
+}
+
+// watch reloads the certificate on every SIGHUP until ctx is done. A failed
+// reload keeps the previous certificate in use.
+func (cr *certReloader) watch(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := cr.reload(); err != nil {
+				logger.Logger.Errorf("Unable to reload TLS certificate: %s", err)
+				continue
+			}
+			logger.Logger.Info("TLS certificate reloaded")
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Record is what is stored under an idempotency key: the fingerprint of
+// the first request and, once it finished, its response.
+type Record struct {
+	Fingerprint string
+	Completed   bool
+	Response    Response
+}
+
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Store keeps idempotency keys per scope, usually a user.
+type Store interface {
+	// Acquire claims key for a request with fingerprint, valid until
+	// now+ttl. When the key is already taken acquired is false and record
+	// holds what is stored under it.
+	Acquire(ctx context.Context, scope, key, fingerprint string, now time.Time, ttl time.Duration) (record Record, acquired bool, err error)
+	// Complete stores the response of the request that acquired key.
+	Complete(ctx context.Context, scope, key string, response Response) error
+	// Release forgets key, so the request can be retried.
+	Release(ctx context.Context, scope, key string) error
+}
@@ -0,0 +1,85 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryRecord struct {
+	Record
+	lockedAt  time.Time
+	expiresAt time.Time
+}
+
+type memoryStore struct {
+	mu          sync.Mutex
+	records     map[string]*memoryRecord
+	lockTimeout time.Duration
+	lastSweep   time.Time
+}
+
+// NewMemoryStore keeps keys in process memory with the semantics of the
+// Postgres store. It suits tests and single replica deployments.
+func NewMemoryStore(lockTimeout time.Duration) Store {
+	return &memoryStore{
+		records:     make(map[string]*memoryRecord),
+		lockTimeout: lockTimeout,
+	}
+}
+
+func (s *memoryStore) Acquire(_ context.Context, scope, key, fingerprint string, now time.Time, ttl time.Duration) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) > sweepInterval {
+		s.sweep(now)
+	}
+
+	id := scope + "\x00" + key
+	record, ok := s.records[id]
+	if ok && now.After(record.expiresAt) {
+		ok = false
+	}
+	abandoned := ok && !record.Completed && now.Sub(record.lockedAt) > s.lockTimeout
+	if ok && !abandoned {
+		return record.Record, false, nil
+	}
+
+	s.records[id] = &memoryRecord{
+		Record:    Record{Fingerprint: fingerprint},
+		lockedAt:  now,
+		expiresAt: now.Add(ttl),
+	}
+	return Record{}, true, nil
+}
+
+// sweep drops expired keys, which Acquire otherwise only replaces when
+// they are sent again.
+func (s *memoryStore) sweep(now time.Time) {
+	for id, record := range s.records {
+		if now.After(record.expiresAt) {
+			delete(s.records, id)
+		}
+	}
+	s.lastSweep = now
+}
+
+func (s *memoryStore) Complete(_ context.Context, scope, key string, response Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[scope+"\x00"+key]; ok {
+		record.Completed = true
+		record.Response = response
+	}
+	return nil
+}
+
+func (s *memoryStore) Release(_ context.Context, scope, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, scope+"\x00"+key)
+	return nil
+}
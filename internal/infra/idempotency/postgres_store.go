@@ -0,0 +1,138 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"go-rest-api/internal/infra/logger"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+type postgresStore struct {
+	db          *sql.DB
+	lockTimeout time.Duration
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// NewPostgresStore keeps keys in the idempotency_keys table. A key whose
+// request has not completed within lockTimeout is considered abandoned,
+// e.g. by a crashed replica, and can be acquired again.
+func NewPostgresStore(db *sql.DB, lockTimeout time.Duration) Store {
+	return &postgresStore{
+		db:          db,
+		lockTimeout: lockTimeout,
+	}
+}
+
+func (s *postgresStore) Acquire(ctx context.Context, scope, key, fingerprint string, now time.Time, ttl time.Duration) (Record, bool, error) {
+	s.sweep(ctx, now)
+
+	// The row can expire and be swept between the two statements, so try
+	// once more before giving up.
+	for attempt := 0; attempt < 2; attempt++ {
+		sqlCommand := `
+			INSERT INTO idempotency_keys (scope, key, fingerprint, locked_at, expires_at)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (scope, key) DO UPDATE SET
+				fingerprint = EXCLUDED.fingerprint,
+				status_code = NULL,
+				header = NULL,
+				body = NULL,
+				locked_at = EXCLUDED.locked_at,
+				expires_at = EXCLUDED.expires_at
+			WHERE idempotency_keys.expires_at < $4
+				OR (idempotency_keys.status_code IS NULL AND idempotency_keys.locked_at < $6)
+			RETURNING key`
+		var claimed string
+		err := s.db.QueryRowContext(ctx, sqlCommand, scope, key, fingerprint, now, now.Add(ttl), now.Add(-s.lockTimeout)).Scan(&claimed)
+		if err == nil {
+			return Record{}, true, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			logger.FromContext(ctx).Error(err)
+			return Record{}, false, err
+		}
+
+		record, err := s.find(ctx, scope, key)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			logger.FromContext(ctx).Error(err)
+			return Record{}, false, err
+		}
+		return record, false, nil
+	}
+	return Record{}, false, errors.New("idempotency key changed concurrently")
+}
+
+func (s *postgresStore) find(ctx context.Context, scope, key string) (Record, error) {
+	var (
+		record     Record
+		statusCode sql.NullInt64
+		header     []byte
+	)
+	sqlCommand := `SELECT fingerprint, status_code, header, body FROM idempotency_keys WHERE scope = $1 AND key = $2`
+	err := s.db.QueryRowContext(ctx, sqlCommand, scope, key).Scan(&record.Fingerprint, &statusCode, &header, &record.Response.Body)
+	if err != nil {
+		return Record{}, err
+	}
+
+	record.Completed = statusCode.Valid
+	record.Response.StatusCode = int(statusCode.Int64)
+	if header != nil {
+		err = json.Unmarshal(header, &record.Response.Header)
+		if err != nil {
+			return Record{}, err
+		}
+	}
+	return record, nil
+}
+
+func (s *postgresStore) Complete(ctx context.Context, scope, key string, response Response) error {
+	header, err := json.Marshal(response.Header)
+	if err != nil {
+		return err
+	}
+
+	sqlCommand := `UPDATE idempotency_keys SET status_code = $3, header = $4, body = $5 WHERE scope = $1 AND key = $2`
+	_, err = s.db.ExecContext(ctx, sqlCommand, scope, key, response.StatusCode, header, response.Body)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return err
+	}
+	return nil
+}
+
+func (s *postgresStore) Release(ctx context.Context, scope, key string) error {
+	sqlCommand := `DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2`
+	_, err := s.db.ExecContext(ctx, sqlCommand, scope, key)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return err
+	}
+	return nil
+}
+
+// sweep deletes expired keys at most once per sweepInterval per replica.
+func (s *postgresStore) sweep(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < sweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	sqlCommand := `DELETE FROM idempotency_keys WHERE expires_at < $1`
+	_, err := s.db.ExecContext(ctx, sqlCommand, now)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+	}
+}
@@ -0,0 +1,118 @@
+package imaging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+const orientationTag = 0x0112
+
+func isJpeg(r io.ReadSeeker) bool {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return false
+	}
+	var soi [2]byte
+	_, err := io.ReadFull(r, soi[:])
+	return err == nil && soi == [2]byte{0xFF, 0xD8}
+}
+
+// jpegOrientation reads the EXIF orientation from the APP1 segment of a
+// JPEG whose start of image marker has been read. It returns 1, upright,
+// when the image has none.
+func jpegOrientation(r io.Reader) int {
+	br := bufio.NewReader(r)
+	for {
+		marker, err := nextMarker(br)
+		if err != nil {
+			return 1
+		}
+		switch {
+		case marker == 0xD9 || marker == 0xDA:
+			// End of image or start of scan, metadata comes before both.
+			return 1
+		case marker >= 0xD0 && marker <= 0xD7 || marker == 0x01:
+			// Markers without a payload.
+			continue
+		}
+
+		var length uint16
+		if err := binary.Read(br, binary.BigEndian, &length); err != nil || length < 2 {
+			return 1
+		}
+		payload := int(length) - 2
+		if marker != 0xE1 {
+			if _, err := br.Discard(payload); err != nil {
+				return 1
+			}
+			continue
+		}
+
+		segment := make([]byte, payload)
+		if _, err := io.ReadFull(br, segment); err != nil {
+			return 1
+		}
+		if exif, ok := bytes.CutPrefix(segment, []byte("Exif\x00\x00")); ok {
+			return tiffOrientation(exif)
+		}
+	}
+}
+
+func nextMarker(br *bufio.Reader) (byte, error) {
+	b, err := br.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b != 0xFF {
+		return 0, io.ErrUnexpectedEOF
+	}
+	// Any number of 0xFF fill bytes may precede the marker.
+	for b == 0xFF {
+		if b, err = br.ReadByte(); err != nil {
+			return 0, err
+		}
+	}
+	return b, nil
+}
+
+// tiffOrientation finds the orientation tag in the first image file
+// directory of a TIFF structure.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return 1
+	}
+
+	ifd := uint64(order.Uint32(tiff[4:]))
+	if ifd+2 > uint64(len(tiff)) {
+		return 1
+	}
+	entries := uint64(order.Uint16(tiff[ifd:]))
+	for i := uint64(0); i < entries; i++ {
+		entry := ifd + 2 + 12*i
+		if entry+12 > uint64(len(tiff)) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) != orientationTag {
+			continue
+		}
+		// The value is a SHORT stored in the first bytes of the value field.
+		if orientation := int(order.Uint16(tiff[entry+8:])); orientation >= 1 && orientation <= 8 {
+			return orientation
+		}
+		return 1
+	}
+	return 1
+}
@@ -0,0 +1,69 @@
+package imaging
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+var identiconBackground = color.RGBA{R: 0xf0, G: 0xf0, B: 0xf0, A: 0xff}
+
+// Identicon draws a size x size pixel identicon for seed: a horizontally
+// symmetric 5x5 pattern in a color picked by the seed. Equal seeds always
+// give equal images.
+func Identicon(seed []byte, size int) image.Image {
+	hash := sha256.Sum256(seed)
+	hue := float64(binary.BigEndian.Uint16(hash[:2])) / 65536 * 360
+	foreground := image.NewUniform(hslToRGB(hue, 0.55, 0.5))
+
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), image.NewUniform(identiconBackground), image.Point{}, draw.Src)
+
+	cell := size / 6
+	margin := (size - 5*cell) / 2
+	for row := 0; row < 5; row++ {
+		for col := 0; col < 3; col++ {
+			if hash[2+row*3+col]&1 == 0 {
+				continue
+			}
+			// Columns 0 and 1 are mirrored to 4 and 3.
+			for _, c := range []int{col, 4 - col} {
+				x, y := margin+c*cell, margin+row*cell
+				draw.Draw(img, image.Rect(x, y, x+cell, y+cell), foreground, image.Point{}, draw.Src)
+			}
+		}
+	}
+	return img
+}
+
+// hslToRGB converts a hue in degrees, saturation and lightness in [0, 1].
+func hslToRGB(h, s, l float64) color.RGBA {
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - c/2
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return color.RGBA{
+		R: uint8(math.Round((r + m) * 255)),
+		G: uint8(math.Round((g + m) * 255)),
+		B: uint8(math.Round((b + m) * 255)),
+		A: 0xff,
+	}
+}
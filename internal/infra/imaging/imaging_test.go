@@ -0,0 +1,105 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+func TestOrient(t *testing.T) {
+	// 3x2 pixels, upright they read 1 2 3 / 4 5 6.
+	upright := [][]uint8{{1, 2, 3}, {4, 5, 6}}
+	// How each orientation stores the upright image.
+	stored := map[int][][]uint8{
+		1: {{1, 2, 3}, {4, 5, 6}},
+		2: {{3, 2, 1}, {6, 5, 4}},
+		3: {{6, 5, 4}, {3, 2, 1}},
+		4: {{4, 5, 6}, {1, 2, 3}},
+		5: {{1, 4}, {2, 5}, {3, 6}},
+		6: {{3, 6}, {2, 5}, {1, 4}},
+		7: {{6, 3}, {5, 2}, {4, 1}},
+		8: {{4, 1}, {5, 2}, {6, 3}},
+	}
+	for orientation, rows := range stored {
+		got := orient(gray(rows), orientation)
+		if want := gray(upright); !samePixels(got, want) {
+			t.Errorf("orientation %d: got %v, want %v", orientation, pixels(got), upright)
+		}
+	}
+}
+
+func TestDecodeJpegOrientation(t *testing.T) {
+	for _, order := range []binary.AppendByteOrder{binary.BigEndian, binary.LittleEndian} {
+		img, format, err := Decode(bytes.NewReader(jpegWithOrientation(t, order, 6)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format != "jpeg" || img.Bounds().Dx() != 8 || img.Bounds().Dy() != 16 {
+			t.Errorf("%v: decoded a %dx%d %s, want an 8x16 jpeg", order, img.Bounds().Dx(), img.Bounds().Dy(), format)
+		}
+	}
+}
+
+func TestCropSquareAndResize(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 30, 10))
+	square := CropSquare(img)
+	if square.Bounds() != image.Rect(10, 0, 20, 10) {
+		t.Errorf("cropped to %v, want the centered 10x10 square", square.Bounds())
+	}
+	if resized := Resize(square, 32, 32); resized.Bounds() != image.Rect(0, 0, 32, 32) {
+		t.Errorf("resized to %v, want 32x32", resized.Bounds())
+	}
+}
+
+// jpegWithOrientation encodes a 16x8 JPEG with an EXIF orientation.
+func jpegWithOrientation(t *testing.T, order binary.AppendByteOrder, orientation uint16) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 16, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tiff := []byte("MM\x00\x2a")
+	if order == binary.LittleEndian {
+		tiff = []byte("II\x2a\x00")
+	}
+	tiff = order.AppendUint32(tiff, 8)
+	tiff = order.AppendUint16(tiff, 1)
+	tiff = order.AppendUint16(tiff, orientationTag)
+	tiff = order.AppendUint16(tiff, 3) // SHORT
+	tiff = order.AppendUint32(tiff, 1)
+	tiff = order.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := binary.BigEndian.AppendUint16([]byte{0xFF, 0xE1}, uint16(len(segment)+2))
+	return append(append(append([]byte{0xFF, 0xD8}, app1...), segment...), buf.Bytes()[2:]...)
+}
+
+func gray(rows [][]uint8) image.Image {
+	img := image.NewGray(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x, v := range row {
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	return img
+}
+
+func pixels(img image.Image) [][]uint8 {
+	bounds := img.Bounds()
+	rows := make([][]uint8, bounds.Dy())
+	for y := range rows {
+		for x := 0; x < bounds.Dx(); x++ {
+			rows[y] = append(rows[y], color.GrayModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray).Y)
+		}
+	}
+	return rows
+}
+
+func samePixels(a, b image.Image) bool {
+	return a.Bounds().Size() == b.Bounds().Size() && bytes.Equal(bytes.Join(pixels(a), nil), bytes.Join(pixels(b), nil))
+}
@@ -14,9 +14,7 @@ func Init(cfg config.Configuration) {
 
 func newLogger(cfg config.Configuration) *zap.SugaredLogger {
 	var logger *zap.Logger
-	if cfg.LoggerLevel == "dev" {
-		logger, _ = zap.NewDevelopment()
-	} else if cfg.LoggerLevel == "prodaction" {
+	if cfg.IsProduction() {
 		logger, _ = zap.NewProduction()
 	} else {
 		logger, _ = zap.NewDevelopment()
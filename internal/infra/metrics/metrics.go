@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "tasks"
+
+// Registry holds every collector of the application. A dedicated registry
+// keeps /metrics free of anything libraries register globally.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HttpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, chi route pattern and status code.",
+	}, []string{"method", "route", "status"})
+
+	HttpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, chi route pattern and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	LoginAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_login_attempts_total",
+		Help:      "Login attempts by result.",
+	}, []string{"result"})
+
+	RateLimitedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_rate_limited_requests_total",
+		Help:      "Requests rejected with 429 by rate limit group.",
+	}, []string{"group"})
+
+	StorageUploadDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_upload_duration_seconds",
+		Help:      "Duration of uploads to the storage backend by backend and result.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"backend", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HttpRequests,
+		HttpRequestDuration,
+		LoginAttempts,
+		RateLimitedRequests,
+		StorageUploadDuration,
+	)
+}
+
+// RegisterDB exposes the connection pool statistics of db. A later pool
+// of the same name replaces the earlier one, so opening the database again
+// in the same process, as tests and tools do, keeps reporting the live pool.
+func RegisterDB(db *sql.DB, name string) {
+	collector := collectors.NewDBStatsCollector(db, name)
+	err := Registry.Register(collector)
+	var registered prometheus.AlreadyRegisteredError
+	if errors.As(err, &registered) {
+		Registry.Unregister(registered.ExistingCollector)
+		err = Registry.Register(collector)
+	}
+	if err != nil {
+		panic(err)
+	}
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveUpload records how long an upload to backend took.
+func ObserveUpload(backend string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	StorageUploadDuration.WithLabelValues(backend, result).Observe(time.Since(start).Seconds())
+}
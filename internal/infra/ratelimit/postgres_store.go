@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"go-rest-api/internal/infra/logger"
+	"sync"
+	"time"
+)
+
+type postgresStore struct {
+	db *sql.DB
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// NewPostgresStore keeps buckets in the rate_limit_buckets table, so every
+// replica shares the same limits.
+func NewPostgresStore(db *sql.DB) Store {
+	return &postgresStore{
+		db: db,
+	}
+}
+
+func (s *postgresStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.sweep(ctx, now)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return Result{}, err
+	}
+	defer tx.Rollback()
+
+	b := newBucket(limit, now)
+	sqlCommand := `INSERT INTO rate_limit_buckets (key, tokens, updated_at) VALUES ($1, $2, $3) ON CONFLICT (key) DO NOTHING`
+	_, err = tx.ExecContext(ctx, sqlCommand, key, b.tokens, b.updated)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return Result{}, err
+	}
+
+	sqlCommand = `SELECT tokens, updated_at FROM rate_limit_buckets WHERE key = $1 FOR UPDATE`
+	err = tx.QueryRowContext(ctx, sqlCommand, key).Scan(&b.tokens, &b.updated)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return Result{}, err
+	}
+
+	result := b.take(limit, now)
+
+	sqlCommand = `UPDATE rate_limit_buckets SET tokens = $2, updated_at = $3 WHERE key = $1`
+	_, err = tx.ExecContext(ctx, sqlCommand, key, b.tokens, b.updated)
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return Result{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+		return Result{}, err
+	}
+	return result, nil
+}
+
+// sweep deletes idle buckets at most once per sweepInterval per replica.
+func (s *postgresStore) sweep(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < sweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	sqlCommand := `DELETE FROM rate_limit_buckets WHERE updated_at < $1`
+	_, err := s.db.ExecContext(ctx, sqlCommand, now.Add(-idleTimeout))
+	if err != nil {
+		logger.FromContext(ctx).Error(err)
+	}
+}
@@ -0,0 +1,155 @@
+package scanning
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go-rest-api/internal/infra/tracing"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// chunkSize is the size of the INSTREAM chunks, well below the default
+// StreamMaxLength of clamd.
+const chunkSize = 64 * 1024
+
+var ErrClamavReply = errors.New("unexpected reply from clamd")
+
+type clamavScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamavScanner talks to the clamd daemon at address, either
+// "tcp://host:port", "host:port" or "unix:///path/to/clamd.sock". Each
+// scan may take up to timeout.
+func NewClamavScanner(address string, timeout time.Duration) (Scanner, error) {
+	scanner := clamavScanner{network: "tcp", address: address, timeout: timeout}
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		scanner.network, scanner.address = "unix", strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "tcp://"):
+		scanner.address = strings.TrimPrefix(address, "tcp://")
+	}
+	if scanner.address == "" {
+		return nil, fmt.Errorf("invalid clamd address %q", address)
+	}
+	return scanner, nil
+}
+
+// Scan streams r to clamd with the INSTREAM command.
+func (s clamavScanner) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	ctx, span := tracing.Start(ctx, "clamav.Scan", trace.WithSpanKind(trace.SpanKindClient))
+	result, err := s.scan(ctx, r)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (s clamavScanner) scan(ctx context.Context, r io.Reader) (Result, error) {
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	defer conn.Close()
+
+	writer := bufio.NewWriterSize(conn, chunkSize+4)
+	if _, err = writer.WriteString("zINSTREAM\x00"); err != nil {
+		return Result{}, err
+	}
+	chunk := make([]byte, chunkSize)
+	for {
+		n, readErr := io.ReadFull(r, chunk)
+		if n > 0 {
+			if err = binary.Write(writer, binary.BigEndian, uint32(n)); err != nil {
+				return Result{}, err
+			}
+			if _, err = writer.Write(chunk[:n]); err != nil {
+				return Result{}, err
+			}
+		}
+		if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+			break
+		}
+		if readErr != nil {
+			return Result{}, readErr
+		}
+	}
+	// A zero length chunk ends the stream.
+	if err = binary.Write(writer, binary.BigEndian, uint32(0)); err != nil {
+		return Result{}, err
+	}
+	if err = writer.Flush(); err != nil {
+		return Result{}, err
+	}
+
+	reply, err := readReply(conn)
+	if err != nil {
+		return Result{}, err
+	}
+	// Replies are "stream: OK", "stream: <signature> FOUND" or
+	// "<message> ERROR".
+	switch {
+	case reply == "stream: OK":
+		return Result{}, nil
+	case strings.HasPrefix(reply, "stream: ") && strings.HasSuffix(reply, " FOUND"):
+		signature := strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), " FOUND")
+		return Result{Infected: true, Signature: signature}, nil
+	}
+	return Result{}, fmt.Errorf("%w: %q", ErrClamavReply, reply)
+}
+
+// Ping checks that clamd answers the PING command.
+func (s clamavScanner) Ping(ctx context.Context) error {
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err = conn.Write([]byte("zPING\x00")); err != nil {
+		return err
+	}
+	reply, err := readReply(conn)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("%w: %q", ErrClamavReply, reply)
+	}
+	return nil
+}
+
+// dial connects to clamd with a deadline covering the whole command.
+func (s clamavScanner) dial(ctx context.Context) (net.Conn, error) {
+	deadline := time.Now().Add(s.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// readReply reads a reply to a command sent with the "z" prefix, which
+// clamd ends with a NUL byte.
+func readReply(conn net.Conn) (string, error) {
+	reply, err := bufio.NewReader(io.LimitReader(conn, 4096)).ReadString(0)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrClamavReply, err)
+	}
+	return strings.TrimSuffix(reply, "\x00"), nil
+}
@@ -0,0 +1,89 @@
+package scanning_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go-rest-api/internal/infra/scanning"
+	"os"
+	"testing"
+	"time"
+)
+
+// clamavScanner runs against an in-process clamd stand-in, or against the
+// clamd at TEST_CLAMAV_ADDRESS.
+func clamavScanner(t *testing.T) scanning.Scanner {
+	t.Helper()
+	address := os.Getenv("TEST_CLAMAV_ADDRESS")
+	if address == "" {
+		_, address = newClamdStandIn(t, 1<<20)
+	}
+	scanner, err := scanning.NewClamavScanner(address, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return scanner
+}
+
+func TestClamavScan(t *testing.T) {
+	scanner := clamavScanner(t)
+	ctx := context.Background()
+
+	if err := scanner.Ping(ctx); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+
+	// Several chunks, with the test file in the middle of the last one.
+	clean := bytes.Repeat([]byte("quarterly report "), 10_000)
+	for name, test := range map[string]struct {
+		content  []byte
+		infected bool
+	}{
+		"empty":    {nil, false},
+		"clean":    {clean, false},
+		"eicar":    {eicar, true},
+		"embedded": {append(append(clean[:len(clean):len(clean)], eicar...), clean[:100]...), true},
+	} {
+		result, err := scanner.Scan(ctx, bytes.NewReader(test.content))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if result.Infected != test.infected || (result.Infected && result.Signature == "") {
+			t.Errorf("%s: result = %+v, want infected %v", name, result, test.infected)
+		}
+	}
+}
+
+func TestClamavStreamTooLong(t *testing.T) {
+	standIn, address := newClamdStandIn(t, 100)
+	scanner, err := scanning.NewClamavScanner(address, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = scanner.Scan(context.Background(), bytes.NewReader(make([]byte, 101)))
+	if !errors.Is(err, scanning.ErrClamavReply) {
+		t.Errorf("err = %v, want ErrClamavReply", err)
+	}
+	if len(standIn.scanned) != 0 {
+		t.Errorf("scanned %d streams, want none", len(standIn.scanned))
+	}
+}
+
+func TestClamavUnavailable(t *testing.T) {
+	// Nothing listens on port 1.
+	unavailable, err := scanning.NewClamavScanner("127.0.0.1:1", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err = unavailable.Scan(ctx, bytes.NewReader([]byte("report"))); err == nil {
+		t.Error("scanning without clamd succeeded")
+	}
+	if err = unavailable.Ping(ctx); err == nil {
+		t.Error("pinging without clamd succeeded")
+	}
+
+	if _, err = scanning.NewClamavScanner("unix://", time.Second); err == nil {
+		t.Error("accepted an empty address")
+	}
+}
@@ -0,0 +1,97 @@
+package scanning_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// eicar is the EICAR test file, which every virus scanner detects. It is
+// split so that scanners do not flag this source file.
+var eicar = []byte(`X5O!P%@AP[4\PZX54(P^)7CC)7}$` + `EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`)
+
+// clamdStandIn implements the PING and INSTREAM commands of clamd and
+// finds the EICAR test file. It rejects streams longer than maxStream
+// like StreamMaxLength does.
+type clamdStandIn struct {
+	maxStream int
+	mu        sync.Mutex
+	scanned   [][]byte
+}
+
+func newClamdStandIn(t *testing.T, maxStream int) (*clamdStandIn, string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	standIn := &clamdStandIn{maxStream: maxStream}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go standIn.serve(conn)
+		}
+	}()
+	return standIn, "tcp://" + listener.Addr().String()
+}
+
+func (s *clamdStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	command, err := reader.ReadString(0)
+	if err != nil {
+		return
+	}
+
+	switch strings.TrimSuffix(command, "\x00") {
+	case "zPING":
+		_, _ = conn.Write([]byte("PONG\x00"))
+	case "zINSTREAM":
+		stream, err := s.readStream(reader)
+		if err != nil {
+			_, _ = conn.Write([]byte(err.Error() + " ERROR\x00"))
+			return
+		}
+		s.mu.Lock()
+		s.scanned = append(s.scanned, stream)
+		s.mu.Unlock()
+		if bytes.Contains(stream, eicar) {
+			_, _ = conn.Write([]byte("stream: Win.Test.EICAR_HDB-1 FOUND\x00"))
+			return
+		}
+		_, _ = conn.Write([]byte("stream: OK\x00"))
+	default:
+		_, _ = conn.Write([]byte("UNKNOWN COMMAND\x00"))
+	}
+}
+
+func (s *clamdStandIn) readStream(reader io.Reader) ([]byte, error) {
+	var stream []byte
+	for {
+		var size uint32
+		if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return stream, nil
+		}
+		if len(stream)+int(size) > s.maxStream {
+			return nil, errors.New("INSTREAM size limit exceeded.")
+		}
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			return nil, err
+		}
+		stream = append(stream, chunk...)
+	}
+}
@@ -0,0 +1,50 @@
+// Package scanning checks uploaded files for malware before anyone can
+// download them.
+package scanning
+
+import (
+	"context"
+	"fmt"
+	"go-rest-api/config"
+	"io"
+)
+
+// Result is the verdict on a scanned file. Signature names the malware
+// found in an infected file.
+type Result struct {
+	Infected  bool
+	Signature string
+}
+
+// Scanner scans file contents. Errors mean the content could not be
+// scanned, never that it is infected.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+	Ping(ctx context.Context) error
+}
+
+// New creates the scanner selected by the configuration.
+func New(cfg config.Configuration) (Scanner, error) {
+	switch cfg.ScannerBackend {
+	case "none":
+		return NewNoopScanner(), nil
+	case "clamav":
+		return NewClamavScanner(cfg.ClamavAddress, cfg.ScannerTimeout)
+	}
+	return nil, fmt.Errorf("unknown scanner backend %q", cfg.ScannerBackend)
+}
+
+type noopScanner struct{}
+
+// NewNoopScanner reports every file clean without reading it.
+func NewNoopScanner() Scanner {
+	return noopScanner{}
+}
+
+func (noopScanner) Scan(_ context.Context, _ io.Reader) (Result, error) {
+	return Result{}, nil
+}
+
+func (noopScanner) Ping(_ context.Context) error {
+	return nil
+}
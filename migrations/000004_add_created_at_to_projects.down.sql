@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS projects_creator_id_title_idx;
+DROP INDEX IF EXISTS projects_creator_id_created_at_idx;
+
+ALTER TABLE projects DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,4 @@
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS created_at timestamptz not null default now();
+
+CREATE INDEX IF NOT EXISTS projects_creator_id_created_at_idx ON projects (creator_id, created_at, id);
+CREATE INDEX IF NOT EXISTS projects_creator_id_title_idx ON projects (creator_id, title, id);
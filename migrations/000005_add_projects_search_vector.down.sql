@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS projects_search_vector_idx;
+
+ALTER TABLE projects DROP COLUMN IF EXISTS search_vector;
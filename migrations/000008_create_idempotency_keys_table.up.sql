@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    scope text not null,
+    key text not null,
+    fingerprint text not null,
+    status_code int default null,
+    header jsonb default null,
+    body bytea default null,
+    locked_at timestamptz not null,
+    expires_at timestamptz not null,
+    primary key (scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
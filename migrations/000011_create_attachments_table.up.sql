@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS attachments (
+    id bigserial not null primary key,
+    project_id bigint not null,
+    uploader_id bigint not null,
+    filename text not null,
+    size bigint not null,
+    content_type text not null,
+    checksum text not null,
+    storage_key text not null unique,
+    created_at timestamptz not null default now(),
+    constraint fk_project
+        foreign key (project_id)
+        references projects(id)
+        on delete cascade,
+    constraint fk_uploader
+        foreign key (uploader_id)
+        references users(id)
+        on delete cascade
+);
+
+CREATE INDEX IF NOT EXISTS attachments_project_id_idx ON attachments (project_id, id);
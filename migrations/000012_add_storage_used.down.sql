@@ -0,0 +1,2 @@
+ALTER TABLE projects DROP COLUMN IF EXISTS storage_used;
+ALTER TABLE users DROP COLUMN IF EXISTS storage_used;
@@ -0,0 +1,9 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS storage_used bigint not null default 0;
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS storage_used bigint not null default 0;
+
+UPDATE users SET storage_used = s.total
+    FROM (SELECT uploader_id, sum(size) AS total FROM attachments GROUP BY uploader_id) s
+    WHERE users.id = s.uploader_id;
+UPDATE projects SET storage_used = s.total
+    FROM (SELECT project_id, sum(size) AS total FROM attachments GROUP BY project_id) s
+    WHERE projects.id = s.project_id;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS attachments_pending_scan_idx;
+ALTER TABLE attachments DROP COLUMN IF EXISTS scan_status;
@@ -0,0 +1,4 @@
+-- Files uploaded before scanning existed wait for "tasksctl storage scan".
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS scan_status text not null default 'pending';
+
+CREATE INDEX IF NOT EXISTS attachments_pending_scan_idx ON attachments (id) WHERE scan_status = 'pending';
@@ -0,0 +1 @@
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS archived_at timestamptz;
@@ -0,0 +1,8 @@
+// Package migrations embeds the SQL migrations into the binaries, so the
+// server and tasksctl do not depend on a migrations directory on disk.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS